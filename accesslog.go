@@ -0,0 +1,290 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	AccessLogAuto     = "auto"
+	AccessLogCombined = "combined"
+	AccessLogJSON     = "json"
+)
+
+type accessLogEntry struct {
+	Time      time.Time
+	Method    string
+	URI       string
+	Referer   string
+	UserAgent string
+}
+
+var (
+	// Combined log format of nginx/Apache, common log format is also matched:
+	// 127.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "GET /a.gif HTTP/1.0" 200 2326 "http://ref/" "Mozilla/4.08"
+	combinedLogRegexp = regexp.MustCompile(
+		`^\S+ \S+ \S+ \[([^\]]+)\] "(\S+) (\S+)(?: [^"]*)?" \d{3} \S+(?: "((?:[^"\\]|\\.)*)" "((?:[^"\\]|\\.)*)")?`)
+
+	combinedTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+	// Field names of JSON lines log, the first existing one would be used
+	jsonLogTimeFields      = []string{"time", "timestamp", "time_iso8601", "@timestamp", "msec", "ts"}
+	jsonLogMethodFields    = []string{"method", "request_method"}
+	jsonLogURIFields       = []string{"uri", "request_uri", "path", "url"}
+	jsonLogRequestFields   = []string{"request"}
+	jsonLogRefererFields   = []string{"referer", "http_referer"}
+	jsonLogUserAgentFields = []string{"user_agent", "http_user_agent", "agent"}
+)
+
+func parseCombinedLog(line string) (*accessLogEntry, error) {
+	matches := combinedLogRegexp.FindStringSubmatch(line)
+	if matches == nil {
+		return nil, errors.New("not combined log format")
+	}
+
+	logTime, err := time.Parse(combinedTimeLayout, matches[1])
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &accessLogEntry{
+		Time:   logTime,
+		Method: matches[2],
+		URI:    matches[3],
+	}
+
+	if matches[4] != "-" {
+		entry.Referer = strings.ReplaceAll(matches[4], `\"`, `"`)
+	}
+
+	if matches[5] != "-" {
+		entry.UserAgent = strings.ReplaceAll(matches[5], `\"`, `"`)
+	}
+
+	return entry, nil
+}
+
+func jsonLogField(fields map[string]interface{}, names []string) (interface{}, bool) {
+	for _, name := range names {
+		if value, exists := fields[name]; exists && value != nil {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+func jsonLogString(fields map[string]interface{}, names []string) string {
+	if value, exists := jsonLogField(fields, names); exists {
+		if text, ok := value.(string); ok && text != "-" {
+			return text
+		}
+	}
+	return ""
+}
+
+// Parse time of JSON log, RFC3339 string or unix timestamp in seconds
+func parseJSONLogTime(value interface{}) (time.Time, error) {
+	var seconds float64
+
+	switch v := value.(type) {
+	case json.Number:
+		number, err := v.Float64()
+		if err != nil {
+			return time.Time{}, err
+		}
+		seconds = number
+	case string:
+		if logTime, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return logTime, nil
+		}
+		if logTime, err := time.Parse(combinedTimeLayout, v); err == nil {
+			return logTime, nil
+		}
+		number, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid time: %s", v)
+		}
+		seconds = number
+	default:
+		return time.Time{}, errors.New("invalid time")
+	}
+
+	// Nanoseconds of timestamp exceed precision of float64, the
+	// fraction is rounded to microseconds separately
+	whole, fraction := math.Modf(seconds)
+
+	return time.Unix(int64(whole), int64(math.Round(fraction*1e6))*1e3), nil
+}
+
+func parseJSONLog(line string) (*accessLogEntry, error) {
+	var fields map[string]interface{}
+
+	decoder := json.NewDecoder(strings.NewReader(line))
+	decoder.UseNumber()
+
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, err
+	}
+
+	entry := &accessLogEntry{
+		Method:    jsonLogString(fields, jsonLogMethodFields),
+		URI:       jsonLogString(fields, jsonLogURIFields),
+		Referer:   jsonLogString(fields, jsonLogRefererFields),
+		UserAgent: jsonLogString(fields, jsonLogUserAgentFields),
+	}
+
+	// Request line (etc: "GET /path HTTP/1.1")
+	if len(entry.Method) == 0 || len(entry.URI) == 0 {
+		parts := strings.Fields(jsonLogString(fields, jsonLogRequestFields))
+		if len(parts) >= 2 {
+			entry.Method, entry.URI = parts[0], parts[1]
+		}
+	}
+
+	if len(entry.Method) == 0 || len(entry.URI) == 0 {
+		return nil, errors.New("method or URI not found")
+	}
+
+	if value, exists := jsonLogField(fields, jsonLogTimeFields); exists {
+		logTime, err := parseJSONLogTime(value)
+		if err != nil {
+			return nil, err
+		}
+		entry.Time = logTime
+	}
+
+	return entry, nil
+}
+
+// Load access log as benchmark items which request the target,
+// item Offset is the request time relative to the first request
+// @param path: access log file path
+// @param format: log format (etc: auto, combined, json)
+// @param target: target URL which request URIs are joined to
+func LoadAccessLog(path string, format string, target string) ([]*BenchmarkItem, error) {
+	return loadAccessLog(path, format, target, log)
+}
+
+// Load access log, skipped lines are written to logger
+func loadAccessLog(path string, format string, target string, logger *Log) ([]*BenchmarkItem, error) {
+	if len(target) == 0 {
+		return nil, errors.New("testing target URL has not set")
+	}
+
+	switch format {
+	case "", AccessLogAuto, AccessLogCombined, AccessLogJSON:
+	default:
+		return nil, fmt.Errorf("unknown access log format: %s", format)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var (
+		items   []*BenchmarkItem
+		times   []time.Time
+		start   time.Time
+		skipped = 0
+		lineNo  = 0
+	)
+
+	target = strings.TrimRight(target, "/")
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	for scanner.Scan() {
+		lineNo++
+
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var entry *accessLogEntry
+
+		if format == AccessLogJSON || ((format == "" || format == AccessLogAuto) && line[0] == '{') {
+			entry, err = parseJSONLog(string(line))
+		} else {
+			entry, err = parseCombinedLog(string(line))
+		}
+
+		if err != nil || ParseMethod(entry.Method) == MethodNone {
+			skipped++
+			logger.Debugf("Skip line %d of access log: %s", lineNo, string(line))
+			continue
+		}
+
+		uri := entry.URI
+		if HasScheme(uri) {
+			// Absolute URI of proxy request, only keep the path
+			if index := strings.Index(uri[strings.Index(uri, "://")+3:], "/"); index >= 0 {
+				uri = uri[strings.Index(uri, "://")+3+index:]
+			} else {
+				uri = "/"
+			}
+		}
+		if !strings.HasPrefix(uri, "/") {
+			uri = "/" + uri
+		}
+
+		headers := make(map[string]string)
+		if len(entry.UserAgent) > 0 {
+			headers["User-Agent"] = entry.UserAgent
+		}
+		if len(entry.Referer) > 0 {
+			headers["Referer"] = entry.Referer
+		}
+
+		if !entry.Time.IsZero() && (start.IsZero() || entry.Time.Before(start)) {
+			start = entry.Time
+		}
+
+		items = append(items, &BenchmarkItem{
+			URL:     target + uri,
+			Method:  strings.ToUpper(entry.Method),
+			Headers: headers,
+			Params:  make(map[string]string),
+			Raw:     true,
+		})
+
+		times = append(times, entry.Time)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(items) == 0 {
+		return nil, fmt.Errorf("%s: no request found (%d lines skipped)", path, skipped)
+	}
+
+	for i, item := range items {
+		if times[i].After(start) {
+			item.Offset = times[i].Sub(start)
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Offset < items[j].Offset
+	})
+
+	return items, nil
+}
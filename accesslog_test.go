@@ -0,0 +1,172 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeTestAccessLog(t *testing.T, lines ...string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "access.log")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+type testAccessLogItem struct {
+	method string
+	url    string
+	offset time.Duration
+}
+
+func checkAccessLogItems(t *testing.T, items []*BenchmarkItem, want []testAccessLogItem) {
+	t.Helper()
+
+	if len(items) != len(want) {
+		t.Fatalf("items = %d, want %d", len(items), len(want))
+	}
+
+	for i, item := range items {
+		if item.Method != want[i].method || item.URL != want[i].url || item.Offset != want[i].offset || !item.Raw {
+			t.Errorf("items[%d] = %s %s at %v, want %s %s at %v", i, item.Method, item.URL, item.Offset,
+				want[i].method, want[i].url, want[i].offset)
+		}
+	}
+}
+
+func TestAccessLogCombined(t *testing.T) {
+	path := writeTestAccessLog(t,
+		`127.0.0.1 - - [10/Oct/2000:13:55:38 -0700] "POST /orders HTTP/1.1" 201 12 "-" "curl/7.68"`,
+		`127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /items?id=1 HTTP/1.1" 200 2326 "http://ref/" "Mozilla/4.08 \"x\""`,
+		``,
+		`not a log line`,
+		`127.0.0.1 - - [10/Oct/2000:20:55:37 +0000] "GET http://proxy.host/a/b HTTP/1.1" 200 10`,
+		`127.0.0.1 - - [10/Oct/2000:13:55:39 -0700] "TRACE / HTTP/1.1" 405 0 "-" "-"`,
+		`127.0.0.1 - - [99/Oct/2000:13:55:39 -0700] "GET / HTTP/1.1" 200 0 "-" "-"`,
+	)
+
+	items, err := LoadAccessLog(path, AccessLogAuto, "http://target/")
+	if err != nil {
+		t.Fatalf("LoadAccessLog() failed: %v", err)
+	}
+
+	// Requests are sorted by time of different zones
+	checkAccessLogItems(t, items, []testAccessLogItem{
+		{"GET", "http://target/items?id=1", 0},
+		{"GET", "http://target/a/b", time.Second},
+		{"POST", "http://target/orders", 2 * time.Second},
+	})
+
+	first := items[0]
+	if first.Headers["Referer"] != "http://ref/" || first.Headers["User-Agent"] != `Mozilla/4.08 "x"` {
+		t.Errorf("headers = %v", first.Headers)
+	}
+
+	if _, exists := items[1].Headers["User-Agent"]; exists {
+		t.Errorf("headers of common log format = %v", items[1].Headers)
+	}
+
+	if items[2].Headers["User-Agent"] != "curl/7.68" || len(items[2].Headers) != 1 {
+		t.Errorf("headers = %v", items[2].Headers)
+	}
+}
+
+func TestAccessLogJSON(t *testing.T) {
+	path := writeTestAccessLog(t,
+		`{"time": "2020-01-01T00:00:01Z", "method": "GET", "uri": "/a", "http_user_agent": "ua"}`,
+		`{"time_iso8601": "2020-01-01T00:00:00.500Z", "request": "POST /b HTTP/1.1", "referer": "-"}`,
+		`{"msec": 1577836802.25, "request_method": "put", "request_uri": "c"}`,
+		`{"ts": "1577836803", "method": "DELETE", "path": "/d"}`,
+		`{"time": "01/Jan/2020:00:00:04 +0000", "method": "GET", "url": "/e"}`,
+		`{"method": "GET"}`,
+		`{"time": "yesterday", "method": "GET", "uri": "/f"}`,
+		`{"method": "GET", "uri": `,
+	)
+
+	items, err := LoadAccessLog(path, AccessLogJSON, "http://target")
+	if err != nil {
+		t.Fatalf("LoadAccessLog() failed: %v", err)
+	}
+
+	checkAccessLogItems(t, items, []testAccessLogItem{
+		{"POST", "http://target/b", 0},
+		{"GET", "http://target/a", 500 * time.Millisecond},
+		{"PUT", "http://target/c", 1750 * time.Millisecond},
+		{"DELETE", "http://target/d", 2500 * time.Millisecond},
+		{"GET", "http://target/e", 3500 * time.Millisecond},
+	})
+
+	if items[1].Headers["User-Agent"] != "ua" || len(items[0].Headers) != 0 {
+		t.Errorf("headers = %v, %v", items[1].Headers, items[0].Headers)
+	}
+}
+
+func TestAccessLogErrors(t *testing.T) {
+	path := writeTestAccessLog(t, `not a log line`, `{"uri": "/a"}`)
+
+	if _, err := LoadAccessLog(path, "", "http://target"); err == nil || !strings.Contains(err.Error(), "2 lines skipped") {
+		t.Errorf("LoadAccessLog() of malformed lines = %v", err)
+	}
+
+	if _, err := LoadAccessLog(path, "", ""); err == nil {
+		t.Error("LoadAccessLog() without target should fail")
+	}
+
+	if _, err := LoadAccessLog(path, "csv", "http://target"); err == nil {
+		t.Error("LoadAccessLog() of unknown format should fail")
+	}
+
+	// JSON lines are not parsed as combined format
+	path = writeTestAccessLog(t, `{"method": "GET", "uri": "/a"}`)
+
+	if _, err := LoadAccessLog(path, AccessLogCombined, "http://target"); err == nil {
+		t.Error("LoadAccessLog() of combined format should fail")
+	}
+}
+
+func TestAccessLogSpeed(t *testing.T) {
+	path := writeTestAccessLog(t,
+		`127.0.0.1 - - [10/Oct/2000:13:55:36 +0000] "GET /a HTTP/1.1" 200 1`,
+		`127.0.0.1 - - [10/Oct/2000:13:55:37 +0000] "GET /b HTTP/1.1" 200 1`,
+		`127.0.0.1 - - [10/Oct/2000:13:55:39 +0000] "GET /c HTTP/1.1" 200 1`,
+	)
+
+	items, err := LoadAccessLog(path, "", "http://target")
+	if err != nil {
+		t.Fatalf("LoadAccessLog() failed: %v", err)
+	}
+
+	tests := []struct {
+		timed   bool
+		speed   float64
+		offsets []time.Duration
+	}{
+		{false, 2, []time.Duration{0, 0, 0, 0}},
+		{true, 0, []time.Duration{0, time.Second, 3 * time.Second, 3*time.Second + time.Millisecond}},
+		{true, 1, []time.Duration{0, time.Second, 3 * time.Second, 3*time.Second + time.Millisecond}},
+		{true, 2, []time.Duration{0, 500 * time.Millisecond, 1500 * time.Millisecond, 1500*time.Millisecond + 500*time.Microsecond}},
+		{true, 0.5, []time.Duration{0, 2 * time.Second, 6 * time.Second, 6*time.Second + 2*time.Millisecond}},
+	}
+
+	for _, test := range tests {
+		picker := NewSequencePicker(items, test.timed, test.speed)
+
+		// The 4th request starts the next round
+		for i, want := range test.offsets {
+			item, offset := picker.Pick()
+			if offset != want || item != items[i%len(items)] {
+				t.Errorf("timed %v speed %v: offset[%d] = %v of %s, want %v", test.timed, test.speed, i, offset, item.URL, want)
+			}
+		}
+	}
+}
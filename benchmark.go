@@ -3,50 +3,67 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-package main
+package gobenchmark
 
 import (
-	"encoding/json"
-	"fmt"
-	"net/http"
-	"os"
-	"sort"
+	"bytes"
+	"context"
+	"net/url"
 	"strconv"
-	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type BenchmarkItem struct {
+	Name    string
+	Weight  int
+	Offset  time.Duration
+	Timeout time.Duration
 	URL     string
 	Headers map[string]string
 	Params  map[string]string
 	Method  string
 	Body    []byte
+
+	// Expected response status and body, status 200 is expected if zero
+	ExpectStatus int
+	ExpectBody   []byte
+
+	// Raw item is sent as it is and placeholders are not rendered,
+	// imported items are raw so their text is not run as templates
+	Raw bool
+
+	template *itemTemplate
 }
 
 type BenchmarkArgs struct {
+	Context   context.Context
 	Simple    *BenchmarkItem
 	WaitGroup *sync.WaitGroup
 	Stats     *Stats
 }
 
 const (
-	version = "1.0.0"
+	Version = "1.0.0"
 )
 
 var (
-	scriptFile     string
-	targetLink     string
-	logPath        string
-	reqMethod      = "GET"
-	reqHeaders     = make(map[string]string)
-	reqArgs        = make(map[string]string)
-	reqBody        []byte
-	connections    = 10
-	benchmarkTimes = 1
+	// Percentiles of request time in results and reports
+	Percentiles = []float64{50, 75, 90, 99}
 )
 
-func benchmark(params ...interface{}) interface{} {
+func NewBenchmarkArgs(ctx context.Context, simple *BenchmarkItem, group *sync.WaitGroup, stats *Stats) *BenchmarkArgs {
+	return &BenchmarkArgs{
+		Context:   ctx,
+		Simple:    simple,
+		WaitGroup: group,
+		Stats:     stats,
+	}
+}
+
+// Send one request, worker is index of the pool coroutine running it
+func (r *Runner) benchmark(worker int, params ...interface{}) interface{} {
 	if len(params) <= 0 {
 		return nil
 	}
@@ -63,17 +80,40 @@ func benchmark(params ...interface{}) interface{} {
 		return nil
 	}
 
+	// Queued requests are dropped after benchmark is stopped
+	if args.Context.Err() != nil {
+		if r.feeder != nil && r.feeder.Exhausted() {
+			atomic.AddInt64(&r.dropped, 1)
+		}
+		return nil
+	}
+
 	simple := args.Simple
 	stats := args.Stats
 
+	var row map[string]string
+
+	if r.feeder != nil {
+		var ok bool
+		if row, ok = r.feeder.Next(worker); !ok {
+			atomic.AddInt64(&r.dropped, 1)
+			// Other partitions may still have rows
+			if r.feeder.Exhausted() {
+				r.stop()
+			}
+			return nil
+		}
+	}
+
+	simple, err := simple.Render(row)
+	if err != nil {
+		r.log.Errorf("Render request template failed: %s", err.Error())
+		return nil
+	}
+
 	method := MethodGet
 	if len(simple.Method) > 0 {
-		switch strings.ToUpper(simple.Method) {
-		case "GET":
-			method = MethodGet
-		case "POST":
-			method = MethodPost
-		}
+		method = ParseMethod(simple.Method)
 	}
 
 	var opts []Option
@@ -93,262 +133,185 @@ func benchmark(params ...interface{}) interface{} {
 		opts = append(opts, BodyOption(simple.Body))
 	}
 
+	if len(simple.Name) > 0 {
+		opts = append(opts, TagOption(simple.Name))
+	}
+
+	if simple.Timeout > 0 {
+		opts = append(opts, TimeoutOption(simple.Timeout))
+	} else if r.config.Timeout > 0 {
+		opts = append(opts, TimeoutOption(r.config.Timeout))
+	}
+
 	req := NewRequest(opts...)
 
-	if !ReqRunScript(req) {
-		Errorf("Call script request() function return false")
+	if r.script != nil && !r.script.Request(req, row) {
+		r.log.Errorf("Call script request() function return false")
+		return nil
+	}
+
+	if hook := r.config.Hooks.BeforeRequest; hook != nil && !hook(req, row) {
 		return nil
 	}
 
 	if len(req.opts.URL) == 0 {
-		Errorf("Testing target URL has not set")
+		r.log.Errorf("Testing target URL has not set")
 		return nil
 	}
 
-	body, err := req.Do()
+	if tag := r.requestTag(req); len(tag) > 0 {
+		stats = stats.Group(tag)
+	}
+
+	if r.config.Stream.Enabled {
+		req.onEvent = r.streamEvent(req)
+	}
 
-	elapsed := req.GetLastElapsed()
+	rsp := r.execute(req)
+
+	elapsed := int64(rsp.Elapsed / time.Millisecond)
 
-	stats.AddTotalPreReqs(1000000000000 / elapsed)
 	stats.AddTotalReqs()
 	stats.AddTotalTime(elapsed)
 
-	if req.Status != 0 {
-		stats.AddStatusCount(req.Status)
+	if len(rsp.Status) > 0 {
+		stats.AddStatusCount(rsp.Status)
+	}
+
+	if len(rsp.Protocol) > 0 {
+		stats.AddProtocolCount(rsp.Protocol)
+	}
+
+	if rsp.Connect > 0 {
+		stats.AddConnect(int64(rsp.Connect / time.Millisecond))
+	}
+
+	if rsp.Disconnected {
+		stats.AddDisconnect()
+	}
+
+	if rsp.Dropped > 0 {
+		stats.AddDroppedMessages(rsp.Dropped)
+	}
+
+	if rsp.FirstByte > 0 || rsp.Events > 0 {
+		stats.AddStream(int64(rsp.FirstByte/time.Millisecond), rsp.Events)
+		for _, gap := range rsp.EventGaps {
+			stats.AddEventGap(int64(gap / time.Millisecond))
+		}
+	}
+
+	success := rsp.OK
+	if simple.ExpectStatus > 0 {
+		success = rsp.Status == strconv.Itoa(simple.ExpectStatus)
 	}
 
-	if err != nil || req.Status != http.StatusOK {
+	if rsp.Err != nil || !success {
 		stats.AddFailure()
-		if err != nil {
-			Errorf("%s", err.Error())
+		if rsp.Err != nil {
+			r.log.Errorf("%s", rsp.Err.Error())
 		}
 		return nil
 	}
 
-	stats.AddTotalRecvBytes(int64(len(body)))
+	if simple.ExpectBody != nil && !bytes.Equal(rsp.Body, simple.ExpectBody) {
+		stats.AddFailure()
+		r.log.Errorf("Response mismatch: %s, %s", req.opts.URL, string(rsp.Body))
+		return nil
+	}
+
+	stats.AddTotalRecvBytes(rsp.Bytes)
 	stats.UpdateReqElapsed(elapsed)
 
-	if CheckRunScript(body) {
-		stats.AddSuccess()
-	} else {
+	if r.script != nil && !r.script.Check(rsp) {
+		stats.AddFailure()
+		r.log.Errorf("Check result false: %s, %s", req.opts.URL, string(rsp.Body))
+		return nil
+	}
+
+	if hook := r.config.Hooks.AfterResponse; hook != nil && !hook(req, rsp) {
 		stats.AddFailure()
-		Errorf("Check result false: %s, %s", req.opts.URL, string(body))
+		r.log.Errorf("Check result false: %s, %s", req.opts.URL, string(rsp.Body))
+		return nil
 	}
 
+	stats.AddSuccess()
+
 	return nil
 }
 
-func showStatusCount(stats *Stats) {
-	var codes []int
+// Get handler of streaming response events, nil if
+// neither Lua event() function nor hook is set
+func (r *Runner) streamEvent(req *Request) func(event *StreamEvent) bool {
+	hook := r.config.Hooks.OnEvent
 
-	for state, _ := range stats.statusStats {
-		codes = append(codes, state)
+	if (r.script == nil || !r.script.hasEvent) && hook == nil {
+		return nil
 	}
 
-	sort.Ints(codes)
-
-	for _, code := range codes {
-		fmt.Printf("Status %d: %d reqs\n", code, stats.statusStats[code])
+	return func(event *StreamEvent) bool {
+		if r.script != nil && !r.script.Event(event) {
+			return false
+		}
+		return hook == nil || hook(req, event)
 	}
 }
 
-func showBenchmarkResult(stats *Stats) {
-	// Make sure dividend not zero
-	totalReqs := stats.totalReqs
-	if totalReqs == 0 {
-		totalReqs = 1
-	}
-
-	totalSeconds := stats.totalTimes / 1000
-	if totalSeconds == 0 {
-		totalSeconds = 1
-	}
-
-	var (
-		totalRecv float64
-		totalUnit string
-	)
-
-	if stats.totalRecvBytes > 1024*1024*1024 {
-		totalRecv = float64(stats.totalRecvBytes) / 1024 / 1024 / 1024
-		totalUnit = "GB"
-	} else if stats.totalRecvBytes > 1024*1024 {
-		totalRecv = float64(stats.totalRecvBytes) / 1024 / 1024
-		totalUnit = "MB"
-	} else if stats.totalRecvBytes > 1024 {
-		totalRecv = float64(stats.totalRecvBytes) / 1024
-		totalUnit = "KB"
-	} else {
-		totalRecv = float64(stats.totalRecvBytes)
-		totalUnit = "B"
-	}
-
-	fmt.Printf("  Connections(Routines): %d\n", connections)
-	fmt.Printf("  Success Total: %d reqs\n", stats.success)
-	fmt.Printf("  Failure Total: %d reqs\n", stats.failure)
-	fmt.Printf("  Success Rate: %d%%\n", stats.success*100/totalReqs)
-	fmt.Printf("  Receive Data %0.3f(%s)\n", totalRecv, totalUnit)
-	fmt.Printf("  Fastest Request: %d(MS)\n", stats.minReqElapsed)
-	fmt.Printf("  Slowest Request: %d(MS)\n", stats.maxReqElapsed)
-	fmt.Printf("  Average Request Time: %d(MS)\n", stats.totalTimes/totalReqs)
-	fmt.Printf("  Requests/sec: %d\n", stats.totalPreReqs/stats.totalReqs/1000000)
-	fmt.Printf("  Transfer/sec: %0.3f(%s)\n", totalRecv/float64(totalSeconds), totalUnit)
-	fmt.Printf("----------------------------\n")
-
-	showStatusCount(stats)
-}
-
-func parseArgs() {
-	argsLen := len(os.Args)
-
-	for i := 0; i < argsLen; i++ {
-		if os.Args[i][0] == '-' && len(os.Args[i]) > 1 {
-			switch os.Args[i][1] {
-			case 't':
-				if argsLen > i+1 {
-					targetLink = os.Args[i+1]
-					if len(targetLink) > 0 {
-						info := strings.Split(targetLink, "://")
-						if len(info) < 2 {
-							targetLink = "http://" + targetLink
-						}
-					}
-					i++
-				}
-			case 'L':
-				if argsLen > i+1 {
-					logPath = os.Args[i+1]
-					i++
-				}
-			case 's':
-				if argsLen > i+1 {
-					scriptFile = os.Args[i+1]
-					i++
-				}
-			case 'c':
-				if argsLen > i+1 {
-					value, err := strconv.Atoi(os.Args[i+1])
-					if err == nil && value > 0 {
-						connections = value
-						i++
-					}
-				}
-			case 'n':
-				if argsLen > i+1 {
-					value, err := strconv.Atoi(os.Args[i+1])
-					if err == nil && value > 0 {
-						benchmarkTimes = value
-						i++
-					}
-				}
-			case 'm':
-				if argsLen > i+1 {
-					switch strings.ToUpper(os.Args[i+1]) {
-					case "GET":
-						reqMethod = "GET"
-					case "POST":
-						reqMethod = "POST"
-					}
-					i++
-				}
-			case 'A':
-				if argsLen > i+1 {
-					var args map[string]string
-					if err := json.Unmarshal([]byte(os.Args[i+1]), &args); err == nil {
-						reqArgs = args
-					}
-					i++
-				}
-			case 'H':
-				if argsLen > i+1 {
-					var headers map[string]string
-					if err := json.Unmarshal([]byte(os.Args[i+1]), &headers); err == nil {
-						reqHeaders = headers
-					}
-					i++
-				}
-			case 'B':
-				if argsLen > i+1 {
-					reqBody = []byte(os.Args[i+1])
-					i++
-				}
-			case 'h':
-				usage()
-				os.Exit(0)
-			case 'v':
-				fmt.Printf("gobenchmark version: %s\n", version)
-				os.Exit(0)
-			}
-		}
+// Send request by executor of URL scheme, errors
+// of preparing request are set to response
+func (r *Runner) execute(req *Request) *Response {
+	executor, err := r.executor(req.opts.URL)
+	if err != nil {
+		return &Response{Err: err}
 	}
-}
 
-func NewBenchmarkArgs(simple *BenchmarkItem, group *sync.WaitGroup, stats *Stats) *BenchmarkArgs {
-	return &BenchmarkArgs{
-		Simple:    simple,
-		WaitGroup: group,
-		Stats:     stats,
+	prepared, err := executor.Prepare(req)
+	if err != nil {
+		return &Response{Err: err}
 	}
-}
 
-func startBenchmark(simples []*BenchmarkItem) {
-	group := &sync.WaitGroup{}
-	stats := NewStats()
-	pool := NewGoPool(connections)
+	ctx := context.Background()
 
-	for _, simple := range simples {
-		group.Add(1)
-		pool.Do(benchmark, NewBenchmarkArgs(simple, group, stats))
+	// Timeout of stream is added to its duration, streams closed
+	// by duration are not failed by timeout
+	timeout := req.opts.Timeout
+	if stream := r.config.Stream; stream.Enabled && stream.Duration > 0 && timeout > 0 {
+		timeout += stream.Duration
 	}
 
-	group.Wait()
-
-	showBenchmarkResult(stats)
-}
+	// Sent requests are not canceled when benchmark is stopped
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
 
-func usage() {
-	fmt.Println("Usage: gobenchmark <options>           \n",
-		"  Options:                                     \n",
-		"    -t <S>  Testing target URL                 \n",
-		"    -c <N>  Connections to keep open           \n",
-		"    -n <N>  How many request for testing       \n",
-		"    -L <S>  Error log path                     \n",
-		"    -m <S>  Request method (etc: GET, POST)    \n",
-		"    -H <S>  Add header to request (JSON format)\n",
-		"    -A <S>  Request arguments (JSON format)    \n",
-		"    -B <S>  Request body                       \n",
-		"                                               \n",
-		"    -s <S>  Load Lua script file               \n",
-		"    -h      Show usage for gobenchmark         \n",
-		"    -v      Print version details              ")
-}
+	start := time.Now()
 
-func main() {
-	parseArgs()
+	rsp := executor.Execute(ctx, prepared)
 
-	if len(scriptFile) > 0 {
-		err := InitScript(scriptFile)
-		if err != nil {
-			fmt.Println(err)
-			os.Exit(-1)
-		}
+	if rsp.Elapsed <= 0 {
+		rsp.Elapsed = time.Since(start)
 	}
 
-	if len(logPath) > 0 {
-		InitDefaultLog(logPath, DebugLevel)
+	if rsp.Bytes <= 0 {
+		rsp.Bytes = int64(len(rsp.Body))
 	}
 
-	var simples []*BenchmarkItem
+	return rsp
+}
+
+// Get tag of request for grouping stats, the tag set by script
+// or endpoint takes precedence over the URL path
+func (r *Runner) requestTag(req *Request) string {
+	tag := req.GetTag()
 
-	for i := 0; i < benchmarkTimes; i++ {
-		simples = append(simples, &BenchmarkItem{
-			URL:     targetLink,
-			Headers: reqHeaders,
-			Params:  reqArgs,
-			Method:  reqMethod,
-			Body:    reqBody,
-		})
+	if len(tag) == 0 && r.config.GroupByPath {
+		tag = "/"
+		if info, err := url.Parse(req.opts.URL); err == nil && len(info.Path) > 0 {
+			tag = info.Path
+		}
 	}
 
-	startBenchmark(simples)
+	return tag
 }
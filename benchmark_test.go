@@ -0,0 +1,99 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func runMockBenchmark(t *testing.T, opts MockOptions, items []*BenchmarkItem, total int) *Result {
+	t.Helper()
+
+	server := httptest.NewServer(NewMockServer(opts))
+	defer server.Close()
+
+	for _, item := range items {
+		item.URL = server.URL + item.URL
+	}
+
+	config := NewConfig()
+	config.Items = items
+	config.Requests = total
+
+	return runBenchmark(t, config)
+}
+
+func TestBenchmarkMock(t *testing.T) {
+	items := []*BenchmarkItem{
+		{Name: "ok", Weight: 3, URL: "/?size=100", Method: "GET"},
+		{Name: "error", Weight: 1, URL: "/?status=500", Method: "POST"},
+	}
+
+	result := runMockBenchmark(t, MockOptions{}, items, 200)
+	stats := result.Stats
+
+	if stats.totalReqs != 200 || stats.success+stats.failure != 200 {
+		t.Fatalf("total = %d, success = %d, failure = %d", stats.totalReqs, stats.success, stats.failure)
+	}
+
+	ok, failed := stats.Group("ok"), stats.Group("error")
+
+	if ok.success != ok.totalReqs || ok.totalRecvBytes != ok.totalReqs*100 {
+		t.Errorf("group ok: total = %d, success = %d, bytes = %d", ok.totalReqs, ok.success, ok.totalRecvBytes)
+	}
+
+	if failed.failure != failed.totalReqs || failed.statusStats["500"] != failed.totalReqs {
+		t.Errorf("group error: total = %d, failure = %d", failed.totalReqs, failed.failure)
+	}
+
+	if stats.RequestsPerSec() <= 0 {
+		t.Errorf("requests/sec = %v, want positive", stats.RequestsPerSec())
+	}
+
+	var output bytes.Buffer
+
+	result.WriteText(&output)
+
+	for _, text := range []string{"Tag(ok):", "Tag(error):", "Status 500:", "Requests/sec:"} {
+		if !strings.Contains(output.String(), text) {
+			t.Errorf("result does not contain %q", text)
+		}
+	}
+}
+
+func TestBenchmarkAbortedRequests(t *testing.T) {
+	// Aborted requests finish within a millisecond, which used to
+	// divide requests/sec by zero elapsed time
+	items := []*BenchmarkItem{{URL: "/", Method: "GET"}}
+
+	stats := runMockBenchmark(t, MockOptions{ErrorRate: 100}, items, 50).Stats
+
+	if stats.failure != 50 || stats.success != 0 {
+		t.Errorf("success = %d, failure = %d, want 0/50", stats.success, stats.failure)
+	}
+}
+
+func TestBenchmarkExpectedResponse(t *testing.T) {
+	items := []*BenchmarkItem{
+		{Name: "status", URL: "/?status=404", ExpectStatus: 404},
+		{Name: "body", URL: "/?size=3", ExpectBody: []byte("xxx")},
+		{Name: "mismatch", URL: "/?size=3", ExpectBody: []byte("yyy")},
+	}
+
+	stats := runMockBenchmark(t, MockOptions{}, items, 60).Stats
+
+	for _, name := range []string{"status", "body"} {
+		if group := stats.Group(name); group.failure != 0 {
+			t.Errorf("group %s has %d failures", name, group.failure)
+		}
+	}
+
+	if group := stats.Group("mismatch"); group.success != 0 {
+		t.Errorf("group mismatch has %d successes", group.success)
+	}
+}
@@ -0,0 +1,838 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/liexusong/gobenchmark"
+)
+
+type Command struct {
+	Name  string
+	Usage string
+	Run   func(args []string) error
+}
+
+var commands []*Command
+
+func init() {
+	commands = []*Command{
+		{Name: "run", Usage: "[scenario] <options>", Run: commandRun},
+		{Name: "compare", Usage: "<base.json> <new.json>", Run: commandCompare},
+		{Name: "record", Usage: "<options>", Run: commandRecord},
+		{Name: "serve", Usage: "<options>", Run: commandServe},
+		{Name: "version", Usage: "", Run: commandVersion},
+		{Name: "help", Usage: "[command]", Run: commandHelp},
+	}
+}
+
+func findCommand(name string) *Command {
+	for _, cmd := range commands {
+		if cmd.Name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// Dispatch subcommand, run benchmark if no subcommand given
+// @param args: command line arguments without program name
+func runCommand(args []string) error {
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd := findCommand(args[0])
+		if cmd == nil {
+			usage(os.Stderr)
+			return fmt.Errorf("unknown command: %s", args[0])
+		}
+		return cmd.Run(args[1:])
+	}
+
+	return commandRun(args)
+}
+
+// Flag value which accepts a JSON object of strings
+type jsonMapValue struct {
+	value *map[string]string
+}
+
+func (v *jsonMapValue) String() string {
+	if v.value == nil || len(*v.value) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(*v.value)
+	return string(data)
+}
+
+func (v *jsonMapValue) Set(text string) error {
+	var fields map[string]string
+	if err := json.Unmarshal([]byte(text), &fields); err != nil {
+		return errors.New("expect JSON object of strings")
+	}
+	*v.value = fields
+	return nil
+}
+
+// Flag value which accepts an integer greater than zero
+type positiveIntValue struct {
+	value *int
+}
+
+func (v *positiveIntValue) String() string {
+	if v.value == nil {
+		return ""
+	}
+	return strconv.Itoa(*v.value)
+}
+
+func (v *positiveIntValue) Set(text string) error {
+	value, err := strconv.Atoi(text)
+	if err != nil || value <= 0 {
+		return errors.New("expect integer greater than 0")
+	}
+	*v.value = value
+	return nil
+}
+
+// Flag value which accepts a supported request method
+type methodValue struct {
+	value *string
+}
+
+func (v *methodValue) String() string {
+	if v.value == nil {
+		return ""
+	}
+	return *v.value
+}
+
+func (v *methodValue) Set(text string) error {
+	method := strings.ToUpper(text)
+	if gobenchmark.ParseMethod(method) == gobenchmark.MethodNone {
+		return errors.New("unsupported request method")
+	}
+	*v.value = method
+	return nil
+}
+
+// Flag value of testing target URL, default scheme is http
+type targetValue struct {
+	value *string
+}
+
+func (v *targetValue) String() string {
+	if v.value == nil {
+		return ""
+	}
+	return *v.value
+}
+
+func (v *targetValue) Set(text string) error {
+	if len(text) > 0 && !strings.Contains(text, "://") {
+		text = "http://" + text
+	}
+	*v.value = text
+	return nil
+}
+
+// Flag value of data file mode
+type feedModeValue struct {
+	value *string
+}
+
+func (v *feedModeValue) String() string {
+	if v.value == nil {
+		return ""
+	}
+	return *v.value
+}
+
+func (v *feedModeValue) Set(text string) error {
+	if _, err := gobenchmark.ParseFeedMode(text); err != nil {
+		return errors.New("expect sequential, random or partition")
+	}
+	*v.value = text
+	return nil
+}
+
+// Flag value of request body
+type bodyValue struct {
+	value *[]byte
+}
+
+func (v *bodyValue) String() string {
+	if v.value == nil {
+		return ""
+	}
+	return string(*v.value)
+}
+
+// Body is loaded from file if text starts with @ (etc: @payload.bin),
+// @@ is escape of literal @ (etc: @@mention is body @mention)
+func (v *bodyValue) Set(text string) error {
+	if strings.HasPrefix(text, "@@") {
+		*v.value = []byte(text[1:])
+		return nil
+	}
+	if strings.HasPrefix(text, "@") {
+		data, err := ioutil.ReadFile(text[1:])
+		if err != nil {
+			return err
+		}
+		*v.value = data
+		return nil
+	}
+	*v.value = []byte(text)
+	return nil
+}
+
+// Flag value which accepts comma separated list, can be repeated
+type stringListValue struct {
+	value *[]string
+}
+
+func (v *stringListValue) String() string {
+	if v.value == nil {
+		return ""
+	}
+	return strings.Join(*v.value, ",")
+}
+
+func (v *stringListValue) Set(text string) error {
+	for _, item := range strings.Split(text, ",") {
+		if item = strings.TrimSpace(item); len(item) > 0 {
+			*v.value = append(*v.value, item)
+		}
+	}
+	return nil
+}
+
+// Flag value of mock server latency distribution
+type latencyValue struct {
+	value **gobenchmark.Latency
+}
+
+func (v *latencyValue) String() string {
+	return ""
+}
+
+func (v *latencyValue) Set(text string) error {
+	latency, err := gobenchmark.ParseLatency(text)
+	if err != nil {
+		return errors.New("expect 50ms, 10ms-100ms, normal:50ms,10ms or exp:50ms")
+	}
+	*v.value = latency
+	return nil
+}
+
+// Flag value of mock server status code mix
+type statusMixValue struct {
+	value *[]gobenchmark.MockStatus
+}
+
+func (v *statusMixValue) String() string {
+	return ""
+}
+
+func (v *statusMixValue) Set(text string) error {
+	statuses, err := gobenchmark.ParseStatusMix(text)
+	if err != nil {
+		return err
+	}
+	*v.value = statuses
+	return nil
+}
+
+// Register both short and long names of flag
+func flagVar(fs *flag.FlagSet, value flag.Value, short, long, usage string) {
+	if len(short) > 0 {
+		fs.Var(value, short, usage)
+	}
+	fs.Var(value, long, usage)
+}
+
+func flagBool(fs *flag.FlagSet, value *bool, short, long, usage string) {
+	if len(short) > 0 {
+		fs.BoolVar(value, short, *value, usage)
+	}
+	fs.BoolVar(value, long, *value, usage)
+}
+
+func newRunFlagSet(config *gobenchmark.Config, showVersion *bool) *flag.FlagSet {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	target := &targetValue{&config.Target}
+
+	flagVar(fs, target, "t", "target", "Testing target URL")
+	flagVar(fs, target, "l", "url", "Testing target URL")
+	flagVar(fs, &positiveIntValue{&config.Connections}, "c", "connections", "Connections to keep open")
+	flagVar(fs, &positiveIntValue{&config.Requests}, "n", "requests", "How many request for testing")
+	fs.DurationVar(&config.Timeout, "timeout", config.Timeout, "Request timeout")
+	fs.StringVar(&config.Log, "L", config.Log, "Error log path")
+	fs.StringVar(&config.Log, "log", config.Log, "Error log path")
+	flagVar(fs, &methodValue{&config.Method}, "m", "method", "Request method")
+	flagVar(fs, &jsonMapValue{&config.Headers}, "H", "headers", "Request headers")
+	flagVar(fs, &jsonMapValue{&config.Params}, "A", "params", "Request arguments")
+	flagVar(fs, &bodyValue{&config.Body}, "B", "body", "Request body")
+	fs.StringVar(&config.Endpoints, "e", config.Endpoints, "Weighted endpoints file")
+	fs.StringVar(&config.Endpoints, "endpoints", config.Endpoints, "Weighted endpoints file")
+	flagBool(fs, &config.GroupByPath, "g", "group-by-path", "Group statistics by URL path")
+	fs.StringVar(&config.Data, "d", config.Data, "Data file")
+	fs.StringVar(&config.Data, "data", config.Data, "Data file")
+	flagVar(fs, &feedModeValue{&config.DataMode}, "D", "data-mode", "Data file mode")
+	flagBool(fs, &config.DataOnce, "o", "data-once", "Stop at the end of data file")
+	fs.StringVar(&config.Curl, "curl", config.Curl, "Curl command")
+	fs.StringVar(&config.AccessLog, "access-log", config.AccessLog, "Access log file")
+	fs.StringVar(&config.AccessLogFormat, "access-log-format", config.AccessLogFormat, "Access log format")
+	fs.StringVar(&config.HAR, "har", config.HAR, "HAR file")
+	fs.Var(&stringListValue{&config.HAROptions.Hosts}, "har-host", "Only import HAR entries of hosts")
+	fs.BoolVar(&config.HAROptions.StripCookies, "har-strip-cookies", config.HAROptions.StripCookies, "Remove cookies of HAR entries")
+	fs.BoolVar(&config.HAROptions.Mix, "har-mix", config.HAROptions.Mix, "Import HAR entries as weighted mix")
+	fs.BoolVar(&config.HAROptions.Validate, "har-validate", config.HAROptions.Validate, "Check responses against HAR entries")
+	fs.StringVar(&config.OpenAPI, "openapi", config.OpenAPI, "OpenAPI document")
+	fs.Var(&stringListValue{&config.OpenAPIOperations}, "openapi-ops", "Only benchmark these OpenAPI operations")
+	fs.StringVar(&config.GRPC.Protoset, "grpc-protoset", config.GRPC.Protoset, "Compiled descriptor set of gRPC services")
+	fs.StringVar(&config.GRPC.CACert, "grpc-cacert", config.GRPC.CACert, "CA certificate of gRPC server")
+	fs.StringVar(&config.GRPC.ServerName, "grpc-servername", config.GRPC.ServerName, "Server name of gRPC certificate")
+	fs.BoolVar(&config.GRPC.Insecure, "grpc-insecure", config.GRPC.Insecure, "Skip verifying gRPC server certificate")
+	fs.StringVar(&config.UnixSocket, "unix-socket", config.UnixSocket, "Dial Unix socket instead of host of target")
+	fs.StringVar(&config.HTTP.Protocol, "http-protocol", config.HTTP.Protocol, "Protocol of HTTP requests")
+	fs.IntVar(&config.HTTP.MaxStreams, "http-max-streams", config.HTTP.MaxStreams, "Max concurrent streams of each HTTP/2 connection")
+	fs.BoolVar(&config.HTTP.ZeroRTT, "http-0rtt", config.HTTP.ZeroRTT, "Send HTTP/3 requests in 0-RTT data")
+	fs.StringVar(&config.WebSocket.Match, "ws-match", config.WebSocket.Match, "JSON field of WebSocket correlation id")
+	fs.DurationVar(&config.WebSocket.Interval, "ws-interval", config.WebSocket.Interval, "Interval between WebSocket messages")
+	fs.BoolVar(&config.WebSocket.NoReply, "ws-no-reply", config.WebSocket.NoReply, "Don't wait for WebSocket replies")
+	fs.BoolVar(&config.Stream.Enabled, "stream", config.Stream.Enabled, "Read responses as stream")
+	fs.DurationVar(&config.Stream.Duration, "stream-duration", config.Stream.Duration, "Maximum duration of each stream")
+	fs.IntVar(&config.Stream.MaxEvents, "stream-events", config.Stream.MaxEvents, "Maximum events of each stream")
+	fs.StringVar(&config.Socket.Encoding, "socket-encoding", config.Socket.Encoding, "Encoding of TCP/UDP payload")
+	fs.StringVar(&config.Socket.Delimiter, "socket-delimiter", config.Socket.Delimiter, "Delimiter of TCP/UDP response")
+	fs.IntVar(&config.Socket.Length, "socket-length", config.Socket.Length, "Length of TCP/UDP response")
+	fs.DurationVar(&config.Socket.ReadTimeout, "socket-read-timeout", config.Socket.ReadTimeout, "Idle timeout of reading TCP/UDP response")
+	fs.StringVar(&config.Socket.Expect, "socket-expect", config.Socket.Expect, "Expected content of TCP/UDP response")
+	fs.BoolVar(&config.Socket.Close, "socket-close", config.Socket.Close, "Close TCP connection after each response")
+	fs.StringVar(&config.Redis.Commands, "redis-commands", config.Redis.Commands, "Weighted Redis commands")
+	fs.Int64Var(&config.Redis.Keys, "redis-keys", config.Redis.Keys, "Size of Redis key space")
+	fs.StringVar(&config.Redis.Distribution, "redis-distribution", config.Redis.Distribution, "Distribution of Redis keys")
+	fs.IntVar(&config.Redis.ValueSize, "redis-value-size", config.Redis.ValueSize, "Size of Redis values")
+	fs.IntVar(&config.Redis.MaxValueSize, "redis-max-value-size", config.Redis.MaxValueSize, "Maximum size of Redis values")
+	fs.IntVar(&config.Redis.Pipeline, "redis-pipeline", config.Redis.Pipeline, "Redis commands in each pipeline")
+	fs.StringVar(&config.Memcache.Commands, "memcache-commands", config.Memcache.Commands, "Weighted Memcached commands")
+	fs.Int64Var(&config.Memcache.Keys, "memcache-keys", config.Memcache.Keys, "Size of Memcached key space")
+	fs.StringVar(&config.Memcache.Distribution, "memcache-distribution", config.Memcache.Distribution, "Distribution of Memcached keys")
+	fs.IntVar(&config.Memcache.ValueSize, "memcache-value-size", config.Memcache.ValueSize, "Size of Memcached values")
+	fs.IntVar(&config.Memcache.MaxValueSize, "memcache-max-value-size", config.Memcache.MaxValueSize, "Maximum size of Memcached values")
+	fs.IntVar(&config.Memcache.MultiGet, "memcache-multi-get", config.Memcache.MultiGet, "Keys of each Memcached multi-get")
+	fs.BoolVar(&config.Memcache.Binary, "memcache-binary", config.Memcache.Binary, "Use binary protocol of Memcached")
+	fs.BoolVar(&config.KeepTiming, "keep-timing", config.KeepTiming, "Keep original timing of requests")
+	fs.Float64Var(&config.Speed, "speed", config.Speed, "Replay speed when keeping original timing")
+	fs.StringVar(&config.Script, "s", config.Script, "Lua script file")
+	fs.StringVar(&config.Script, "script", config.Script, "Lua script file")
+	flagBool(fs, showVersion, "v", "version", "Print version details")
+
+	return fs
+}
+
+// Parse flags, print usage and error if failed
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	err := fs.Parse(args)
+	if err == flag.ErrHelp {
+		usage(os.Stdout)
+		os.Exit(0)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected argument: %s", fs.Arg(0))
+	}
+
+	return nil
+}
+
+// Run benchmark, options of command line would override the scenario file
+// Example: gobenchmark run scenario.yaml -c 100
+func commandRun(args []string) error {
+	var scenario *gobenchmark.Scenario
+
+	config := gobenchmark.NewConfig()
+
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		var err error
+
+		scenario, err = gobenchmark.LoadScenario(args[0])
+		if err != nil {
+			return err
+		}
+
+		scenario.Apply(config)
+
+		args = args[1:]
+	}
+
+	showVersion := false
+
+	fs := newRunFlagSet(config, &showVersion)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if showVersion {
+		return commandVersion(nil)
+	}
+
+	runner, err := gobenchmark.NewRunner(config)
+	if err != nil {
+		return err
+	}
+
+	defer runner.Close()
+
+	// Result of sent requests is still shown when interrupted
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	result, err := runner.Run(ctx)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+
+	if scenario == nil {
+		result.WriteText(os.Stdout)
+		return nil
+	}
+
+	outputs := scenario.Outputs
+	if len(outputs) == 0 {
+		outputs = []gobenchmark.ScenarioOutput{{Format: "text"}}
+	}
+
+	for _, output := range outputs {
+		if err := writeOutput(output.Format, output.Path, result, scenario.Name); err != nil {
+			return err
+		}
+	}
+
+	failures := scenario.Thresholds.Check(result.Report(scenario.Name))
+	if len(failures) > 0 {
+		for _, failure := range failures {
+			fmt.Printf("Threshold failed: %s\n", failure)
+		}
+		return fmt.Errorf("%d threshold(s) failed", len(failures))
+	}
+
+	return nil
+}
+
+// Write benchmark result to output
+// @param format: output format (etc: text, json)
+// @param path: output file path, write to stdout if empty
+func writeOutput(format string, path string, result *gobenchmark.Result, name string) error {
+	var w io.Writer = os.Stdout
+
+	if len(path) > 0 {
+		file, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		w = file
+	}
+
+	switch format {
+	case "", "text":
+		result.WriteText(w)
+		return nil
+	case "json":
+		return result.Report(name).WriteJSON(w)
+	}
+
+	return fmt.Errorf("unknown output format: %s", format)
+}
+
+// Compare two JSON reports
+// Example: gobenchmark compare base.json new.json
+func commandCompare(args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: gobenchmark compare <base.json> <new.json>")
+	}
+
+	base, err := gobenchmark.LoadReport(args[0])
+	if err != nil {
+		return err
+	}
+
+	current, err := gobenchmark.LoadReport(args[1])
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("  %-24s %12s %12s %10s\n", "", "Base", "New", "Delta")
+	showSummaryCompare(os.Stdout, base.Summary, current.Summary)
+
+	var names []string
+	for name := range current.Tags {
+		if _, exists := base.Tags[name]; exists {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Printf("\n  Tag(%s):\n", name)
+		showSummaryCompare(os.Stdout, base.Tags[name], current.Tags[name])
+	}
+
+	return nil
+}
+
+// Run recording proxy, requests are saved as HAR file which can
+// be replayed by benchmark with --har option
+// Example: gobenchmark record -t http://backend:8080 -a :8888 -w session.har
+func commandRecord(args []string) error {
+	var (
+		listen    = ":8888"
+		target    string
+		output    = "workload.har"
+		responses bool
+		logPath   string
+	)
+
+	fs := flag.NewFlagSet("record", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	flagVar(fs, &targetValue{&target}, "t", "target", "Real target URL")
+	fs.StringVar(&listen, "a", listen, "Listen address")
+	fs.StringVar(&listen, "listen", listen, "Listen address")
+	fs.StringVar(&output, "w", output, "Output HAR file")
+	fs.StringVar(&output, "output", output, "Output HAR file")
+	fs.BoolVar(&responses, "responses", responses, "Save response bodies")
+	fs.StringVar(&logPath, "L", logPath, "Error log path")
+	fs.StringVar(&logPath, "log", logPath, "Error log path")
+
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if len(logPath) > 0 {
+		if err := gobenchmark.InitDefaultLog(logPath, gobenchmark.DebugLevel); err != nil {
+			return err
+		}
+	}
+
+	recorder, err := gobenchmark.NewRecorder(target, output, responses)
+	if err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{Handler: recorder}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	// Requests in flight are recorded before HAR file is saved
+	shutdown := make(chan struct{})
+
+	go func() {
+		defer close(shutdown)
+		<-signals
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(ctx)
+	}()
+
+	if len(target) > 0 {
+		fmt.Printf("Recording requests of %s on %s to %s, press Ctrl+C to stop\n", target, listener.Addr(), output)
+	} else {
+		fmt.Printf("Recording proxy requests on %s to %s, press Ctrl+C to stop\n", listener.Addr(), output)
+	}
+
+	if err := server.Serve(listener); err != http.ErrServerClosed {
+		_ = recorder.Close()
+		return err
+	}
+
+	<-shutdown
+
+	if err := recorder.Close(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Recorded %d requests to %s\n", recorder.Count(), output)
+
+	return nil
+}
+
+// Run mock server for calibrating benchmark and testing
+// Example: gobenchmark serve -a :8080 --latency 10ms-50ms --status 200:95,500:5
+func commandServe(args []string) error {
+	var (
+		listen = ":8080"
+		opts   gobenchmark.MockOptions
+	)
+
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	fs.StringVar(&listen, "a", listen, "Listen address")
+	fs.StringVar(&listen, "listen", listen, "Listen address")
+	fs.Var(&latencyValue{&opts.Latency}, "latency", "Response latency")
+	fs.IntVar(&opts.Size, "size", opts.Size, "Response size")
+	fs.Var(&statusMixValue{&opts.Statuses}, "status", "Status code mix")
+	fs.Float64Var(&opts.ErrorRate, "error-rate", opts.ErrorRate, "Percent of aborted requests")
+	fs.IntVar(&opts.Chunks, "chunks", opts.Chunks, "Chunks of response")
+	fs.DurationVar(&opts.ChunkDelay, "chunk-delay", opts.ChunkDelay, "Delay between chunks")
+
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if opts.Size < 0 {
+		return errors.New("invalid value for flag -size: cannot be negative")
+	}
+
+	if opts.ErrorRate < 0 || opts.ErrorRate > 100 {
+		return errors.New("invalid value for flag -error-rate: must be between 0 and 100")
+	}
+
+	mock := gobenchmark.NewMockServer(opts)
+
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{Handler: mock}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		<-signals
+		_ = server.Close()
+	}()
+
+	fmt.Printf("Serving on %s, press Ctrl+C to stop\n", listener.Addr())
+
+	if err := server.Serve(listener); err != http.ErrServerClosed {
+		return err
+	}
+
+	requests, failures := mock.Count()
+
+	fmt.Printf("Served %d requests (%d aborted)\n", requests, failures)
+
+	return nil
+}
+
+func showCompareLine(w io.Writer, name string, base, current float64, format string) {
+	delta := "-"
+	if base != 0 {
+		delta = fmt.Sprintf("%+0.2f%%", (current-base)*100/base)
+	}
+
+	fmt.Fprintf(w, "  %-24s %12s %12s %10s\n", name,
+		fmt.Sprintf(format, base), fmt.Sprintf(format, current), delta)
+}
+
+func showSummaryCompare(w io.Writer, base, current gobenchmark.ReportSummary) {
+	showCompareLine(w, "Total Requests:", float64(base.Total), float64(current.Total), "%0.0f")
+	showCompareLine(w, "Success Rate(%):", base.SuccessRate, current.SuccessRate, "%0.2f")
+	showCompareLine(w, "Fastest Request(MS):", float64(base.MinTime), float64(current.MinTime), "%0.0f")
+	showCompareLine(w, "Slowest Request(MS):", float64(base.MaxTime), float64(current.MaxTime), "%0.0f")
+	showCompareLine(w, "Average Request(MS):", float64(base.AvgTime), float64(current.AvgTime), "%0.0f")
+	showCompareLine(w, "Requests/sec:", base.RPS, current.RPS, "%0.2f")
+
+	for _, percent := range gobenchmark.Percentiles {
+		name := gobenchmark.PercentileName(percent)
+		showCompareLine(w, fmt.Sprintf("%v%% Request(MS):", percent),
+			float64(base.Percentiles[name]), float64(current.Percentiles[name]), "%0.0f")
+	}
+}
+
+func commandVersion(args []string) error {
+	fmt.Printf("gobenchmark version: %s\n", gobenchmark.Version)
+	return nil
+}
+
+func commandHelp(args []string) error {
+	if len(args) > 0 && args[0] != "run" {
+		cmd := findCommand(args[0])
+		if cmd == nil {
+			return fmt.Errorf("unknown command: %s", args[0])
+		}
+		fmt.Printf("Usage: gobenchmark %s %s\n", cmd.Name, cmd.Usage)
+		return nil
+	}
+
+	usage(os.Stdout)
+	return nil
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: gobenchmark [run] [scenario] <options>\n",
+		"      gobenchmark compare <base.json> <new.json>\n",
+		"      gobenchmark record <options>              \n",
+		"      gobenchmark serve <options>               \n",
+		"      gobenchmark version                       \n",
+		"  Options:                                                \n",
+		"    -t, --target <S>       Testing target URL (alias: -l)  \n",
+		"    -c, --connections <N>  Connections to keep open        \n",
+		"    -n, --requests <N>     How many request for testing    \n",
+		"                           (default: one pass of sequence) \n",
+		"        --timeout <D>      Request timeout (etc: 500ms, 5s)\n",
+		"    -L, --log <S>          Error log path                  \n",
+		"    -m, --method <S>       Request method (etc: GET, POST, \n",
+		"                           PUT, DELETE, PATCH, HEAD)       \n",
+		"    -H, --headers <S>      Request headers (JSON format)   \n",
+		"    -A, --params <S>       Request arguments (JSON format) \n",
+		"    -B, --body <S>         Request body (@file to load)    \n",
+		"    -e, --endpoints <S>    Load weighted endpoints file    \n",
+		"    -g, --group-by-path    Group statistics by URL path    \n",
+		"    -d, --data <S>         Load data file (CSV/JSON lines) \n",
+		"    -D, --data-mode <S>    Data file mode (etc: sequential,\n",
+		"                           random, partition)              \n",
+		"    -o, --data-once        Stop at the end of data file    \n",
+		"        --curl <S>         Load request from curl command  \n",
+		"        --har <S>          Load requests from HAR file     \n",
+		"        --har-host <S>     Only import entries of hosts    \n",
+		"        --har-strip-cookies                                \n",
+		"                           Remove cookies of entries       \n",
+		"        --har-mix          Import entries as weighted mix  \n",
+		"        --har-validate     Check responses against entries \n",
+		"        --openapi <S>      Load operations of OpenAPI 3    \n",
+		"        --openapi-ops <S>  Only load operations of IDs,    \n",
+		"                           tags or methods (etc: getUser)  \n",
+		"        --access-log <S>   Replay access log against target\n",
+		"        --access-log-format <S>                            \n",
+		"                           Access log format (etc: auto,   \n",
+		"                           combined, json)                 \n",
+		"        --grpc-protoset <S>                                \n",
+		"                           Compiled descriptors of gRPC    \n",
+		"                           (server reflection if not set)  \n",
+		"        --grpc-cacert <S>  CA certificate of grpcs server  \n",
+		"        --grpc-servername <S>                              \n",
+		"                           Server name of grpcs certificate\n",
+		"        --grpc-insecure    Skip verifying grpcs certificate\n",
+		"        --unix-socket <S>  Dial Unix socket instead of host\n",
+		"                           of target (etc: /run/app.sock)  \n",
+		"        --http-protocol <S>                                \n",
+		"                           HTTP protocol: auto, http1, h2  \n",
+		"                           (TLS), h2c (prior knowledge) or \n",
+		"                           h3 (QUIC)                       \n",
+		"        --http-max-streams <N>                             \n",
+		"                           Max concurrent streams of each  \n",
+		"                           HTTP/2 or HTTP/3 connection     \n",
+		"        --http-0rtt        Send GET and HEAD requests in   \n",
+		"                           0-RTT data of resumed HTTP/3    \n",
+		"                           connections                     \n",
+		"        --ws-match <S>     JSON field of correlation id of \n",
+		"                           WebSocket messages (etc: id)    \n",
+		"        --ws-interval <T>  Min interval of messages on each\n",
+		"                           connection (etc: 1s)            \n",
+		"        --ws-no-reply      Don't wait for WebSocket replies\n",
+		"        --stream           Read responses as stream and    \n",
+		"                           measure SSE events or chunks    \n",
+		"        --stream-duration <T>                              \n",
+		"                           Close stream after duration     \n",
+		"        --stream-events <N>                                \n",
+		"                           Close stream after N events     \n",
+		"        --socket-encoding <S>                              \n",
+		"                           Encoding of TCP/UDP payload     \n",
+		"                           (etc: text, hex)                \n",
+		"        --socket-delimiter <S>                             \n",
+		"                           Response ends with delimiter    \n",
+		"        --socket-length <N>                                \n",
+		"                           Response is fixed length bytes  \n",
+		"        --socket-read-timeout <T>                          \n",
+		"                           Response ends if no data in time\n",
+		"        --socket-expect <S>                                \n",
+		"                           Response must contain content   \n",
+		"        --socket-close     Close TCP connection after each \n",
+		"                           response                        \n",
+		"        --redis-commands <S>                               \n",
+		"                           Weighted Redis commands (etc:   \n",
+		"                           GET:70,SET:20,INCR:5,LPUSH:5)   \n",
+		"        --redis-keys <N>   Random keys in [1, N] (default: \n",
+		"                           100000)                         \n",
+		"        --redis-distribution <S>                           \n",
+		"                           Distribution of keys (etc:      \n",
+		"                           uniform, zipfian)               \n",
+		"        --redis-value-size <N>                             \n",
+		"                           Size of values (default: 3)     \n",
+		"        --redis-max-value-size <N>                         \n",
+		"                           Random size of values in [size, \n",
+		"                           N]                              \n",
+		"        --redis-pipeline <N>                               \n",
+		"                           Commands in each request        \n",
+		"        --memcache-commands <S>                            \n",
+		"                           Weighted Memcached commands     \n",
+		"                           (etc: get:80,set:15,mget:5)     \n",
+		"        --memcache-keys <N>                                \n",
+		"                           Random keys in [1, N] (default: \n",
+		"                           100000)                         \n",
+		"        --memcache-distribution <S>                        \n",
+		"                           Distribution of keys (etc:      \n",
+		"                           uniform, zipfian)               \n",
+		"        --memcache-value-size <N>                          \n",
+		"                           Size of values (default: 3)     \n",
+		"        --memcache-max-value-size <N>                      \n",
+		"                           Random size of values in [size, \n",
+		"                           N]                              \n",
+		"        --memcache-multi-get <N>                           \n",
+		"                           Keys of each mget (default: 10) \n",
+		"        --memcache-binary  Use binary protocol of Memcached\n",
+		"        --keep-timing      Keep original timing of requests\n",
+		"        --speed <F>        Replay speed (etc: 2 means 2x)  \n",
+		"                                                           \n",
+		"    -s, --script <S>       Load Lua script file            \n",
+		"    -h, --help             Show usage for gobenchmark      \n",
+		"    -v, --version          Print version details           \n",
+		"                                                           \n",
+		"  Record Options:                                         \n",
+		"    -t, --target <S>       Real target URL (forward proxy  \n",
+		"                           if not set)                     \n",
+		"    -a, --listen <S>       Listen address (default: :8888) \n",
+		"    -w, --output <S>       Output HAR file                 \n",
+		"                           (default: workload.har)         \n",
+		"        --responses        Save response bodies            \n",
+		"    -L, --log <S>          Error log path                  \n",
+		"                                                           \n",
+		"  Serve Options:                                          \n",
+		"    -a, --listen <S>       Listen address (default: :8080) \n",
+		"        --latency <S>      Response latency (etc: 50ms,    \n",
+		"                           10ms-100ms, normal:50ms,10ms,   \n",
+		"                           exp:50ms)                       \n",
+		"        --size <N>         Response size in bytes          \n",
+		"        --status <S>       Status code mix (etc: 200:95,   \n",
+		"                           500:5)                          \n",
+		"        --error-rate <F>   Percent of aborted connections  \n",
+		"        --chunks <N>       Send response in chunks         \n",
+		"        --chunk-delay <D>  Delay between chunks            ")
+}
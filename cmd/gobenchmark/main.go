@@ -0,0 +1,18 @@
+// A simple benchmark tool for testing web performance
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	if err := runCommand(os.Args[1:]); err != nil {
+		fmt.Println(err)
+		os.Exit(-1)
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"time"
+)
+
+// Hooks are called by runner, they are called concurrently
+// by workers so they must be thread safe
+type Hooks struct {
+	// Called before request is sent (after Lua request() function),
+	// the request would be skipped if it returns false
+	BeforeRequest func(req *Request, row map[string]string) bool
+	// Called after successful response is received (after Lua check()
+	// function), the request would be failure if it returns false
+	AfterResponse func(req *Request, rsp *Response) bool
+	// Called for each event of streaming response (after Lua event()
+	// function), the stream would be closed as failure if it returns false
+	OnEvent func(req *Request, event *StreamEvent) bool
+	// Called with stats snapshot every ProgressInterval while running
+	OnProgress func(snapshot Snapshot)
+}
+
+// Config of benchmark, the request source is chosen in order:
+// Items, Curl, AccessLog, HAR, OpenAPI, scenario requests, Endpoints,
+// Redis commands, Memcached commands, Target
+type Config struct {
+	// Testing target URL, base URL of relative endpoints
+	Target string
+	// Dial Unix socket instead of host of targets (etc: /run/app.sock),
+	// host and path of URL are still used in requests
+	UnixSocket string
+	// Default request options of items
+	Method  string
+	Headers map[string]string
+	Params  map[string]string
+	Body    []byte
+	Timeout time.Duration
+
+	// Concurrent workers (connections)
+	Connections int
+	// Total requests, zero means one request or one pass of sequence
+	Requests int
+	// Group stats by URL path if request has no tag
+	GroupByPath bool
+
+	// Lua script file path
+	Script string
+	// Error log path
+	Log string
+
+	// Weighted endpoints file
+	Endpoints string
+
+	// Data file which rows are consumed by requests
+	Data     string
+	DataMode string
+	DataOnce bool
+
+	// Curl command
+	Curl string
+
+	// Access log file which requests are replayed against target
+	AccessLog       string
+	AccessLogFormat string
+
+	// HAR file and import options
+	HAR        string
+	HAROptions HAROptions
+
+	// OpenAPI document and operations filter
+	OpenAPI           string
+	OpenAPIOperations []string
+
+	// Options of gRPC executor
+	GRPC GRPCOptions
+
+	// Protocol and streams of HTTP client
+	HTTP HTTPOptions
+	// Options of WebSocket executor
+	WebSocket WebSocketOptions
+	// Read HTTP responses as stream (etc: SSE)
+	Stream StreamOptions
+	// Options of TCP and UDP executors
+	Socket SocketOptions
+	// Command mix of Redis target
+	Redis RedisOptions
+	// Command mix and protocol of Memcached target
+	Memcache MemcacheOptions
+
+	// Keep original timing of sequence, scaled by speed
+	KeepTiming bool
+	Speed      float64
+
+	// Benchmark items built by caller
+	Items []*BenchmarkItem
+	// Send items in order instead of weighted mix
+	Sequential bool
+
+	Hooks            Hooks
+	ProgressInterval time.Duration
+
+	// Requests of scenario file, relative URLs are joined to target
+	scenarioRequests []endpointConfig
+	scenarioPath     string
+}
+
+// Create config with default values
+func NewConfig() *Config {
+	return &Config{
+		Method:           "GET",
+		Headers:          make(map[string]string),
+		Params:           make(map[string]string),
+		Connections:      10,
+		DataMode:         "sequential",
+		AccessLogFormat:  AccessLogAuto,
+		Speed:            1.0,
+		ProgressInterval: time.Second,
+	}
+}
@@ -0,0 +1,407 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// Options which would be ignored, the client behaves the same
+	// by default or they only affect output of curl
+	curlIgnoreFlags = map[string]bool{
+		"-s": true, "--silent": true,
+		"-S": true, "--show-error": true,
+		"-v": true, "--verbose": true,
+		"-i": true, "--include": true,
+		"-L": true, "--location": true,
+		"-k": true, "--insecure": true,
+		"--compressed": true,
+		"-f":           true, "--fail": true,
+		"-g": true, "--globoff": true,
+		"-#": true, "--progress-bar": true,
+		"--http1.0": true, "--http1.1": true, "--http2": true,
+		"--no-buffer": true, "-N": true,
+	}
+
+	// Options with argument which would be ignored
+	curlIgnoreArgFlags = map[string]bool{
+		"-o": true, "--output": true,
+		"-w": true, "--write-out": true,
+		"--connect-timeout": true,
+		"--retry":           true,
+	}
+
+	// Short options without argument which can be combined (etc: -sSL)
+	curlShortFlags = "sSvikLfgGIN#"
+)
+
+// Split command line into words like shell, supports single quote,
+// double quote, ANSI-C quote ($'...') and backslash escape
+func splitCommand(command string) ([]string, error) {
+	var (
+		words   []string
+		word    strings.Builder
+		inWord  bool
+		runes   = []rune(command)
+		size    = len(runes)
+		i       = 0
+		closing = errors.New("unterminated quote in command")
+	)
+
+	for i < size {
+		c := runes[i]
+
+		switch {
+		case c == '\\' && i+1 < size && (runes[i+1] == '\n' || runes[i+1] == '\r'):
+			// Line continuation
+			i += 2
+			if i < size && runes[i-1] == '\r' && runes[i] == '\n' {
+				i++
+			}
+			continue
+
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+
+		case c == '\\':
+			inWord = true
+			if i+1 < size {
+				i++
+				word.WriteRune(runes[i])
+			}
+
+		case c == '\'':
+			inWord = true
+			end := i + 1
+			for end < size && runes[end] != '\'' {
+				end++
+			}
+			if end >= size {
+				return nil, closing
+			}
+			word.WriteString(string(runes[i+1 : end]))
+			i = end
+
+		case c == '$' && i+1 < size && runes[i+1] == '\'':
+			inWord = true
+			i += 2
+			for ; i < size && runes[i] != '\''; i++ {
+				if runes[i] != '\\' || i+1 >= size {
+					word.WriteRune(runes[i])
+					continue
+				}
+				i++
+				switch runes[i] {
+				case 'n':
+					word.WriteByte('\n')
+				case 't':
+					word.WriteByte('\t')
+				case 'r':
+					word.WriteByte('\r')
+				case 'x':
+					if i+2 < size {
+						if value, err := strconv.ParseUint(string(runes[i+1:i+3]), 16, 8); err == nil {
+							word.WriteByte(byte(value))
+							i += 2
+							continue
+						}
+					}
+					word.WriteRune(runes[i])
+				case 'u':
+					if i+4 < size {
+						if value, err := strconv.ParseUint(string(runes[i+1:i+5]), 16, 32); err == nil {
+							word.WriteRune(rune(value))
+							i += 4
+							continue
+						}
+					}
+					word.WriteRune(runes[i])
+				default:
+					word.WriteRune(runes[i])
+				}
+			}
+			if i >= size {
+				return nil, closing
+			}
+
+		case c == '"':
+			inWord = true
+			i++
+			for ; i < size && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < size && strings.ContainsRune("\"\\$`\n", runes[i+1]) {
+					i++
+					if runes[i] == '\n' {
+						continue
+					}
+				}
+				word.WriteRune(runes[i])
+			}
+			if i >= size {
+				return nil, closing
+			}
+
+		default:
+			inWord = true
+			word.WriteRune(c)
+		}
+
+		i++
+	}
+
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	return words, nil
+}
+
+// Get data of -d option, @file means reading from file
+func curlData(value string, binary bool) (string, error) {
+	if !strings.HasPrefix(value, "@") {
+		return value, nil
+	}
+
+	data, err := ioutil.ReadFile(value[1:])
+	if err != nil {
+		return "", err
+	}
+
+	if !binary {
+		// The same as curl, newlines are stripped when not binary
+		return strings.NewReplacer("\r", "", "\n", "").Replace(string(data)), nil
+	}
+
+	return string(data), nil
+}
+
+// Get data of --data-urlencode option (etc: "name=value", "=value", "name@file")
+func curlURLEncodeData(value string) (string, error) {
+	if index := strings.IndexAny(value, "=@"); index >= 0 {
+		name, content := value[:index], value[index+1:]
+
+		if value[index] == '@' {
+			data, err := ioutil.ReadFile(content)
+			if err != nil {
+				return "", err
+			}
+			content = string(data)
+		}
+
+		if len(name) == 0 {
+			return url.QueryEscape(content), nil
+		}
+		return name + "=" + url.QueryEscape(content), nil
+	}
+
+	return url.QueryEscape(value), nil
+}
+
+// Parse curl command as benchmark item
+// Example: ParseCurl(`curl -X POST -H 'Content-Type: application/json' -d '{"id":1}' http://host/api`)
+func ParseCurl(command string) (*BenchmarkItem, error) {
+	words, err := splitCommand(strings.TrimSpace(command))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(words) > 0 && (words[0] == "curl" || strings.HasSuffix(words[0], "/curl")) {
+		words = words[1:]
+	}
+
+	var (
+		item = &BenchmarkItem{
+			Headers: make(map[string]string),
+			Params:  make(map[string]string),
+			Raw:     true,
+		}
+		data    []string
+		getData bool
+		isJSON  bool
+		method  string
+	)
+
+	for i := 0; i < len(words); i++ {
+		word := words[i]
+
+		// Split combined short options (etc: -sSL, -XPOST, -H'Accept: */*')
+		if len(word) > 2 && word[0] == '-' && word[1] != '-' {
+			if strings.ContainsRune("XHdbuAemow", rune(word[1])) {
+				words = append(words[:i+1], append([]string{word[2:]}, words[i+1:]...)...)
+				word = word[:2]
+			} else if strings.Trim(word[1:], curlShortFlags) == "" {
+				var flags []string
+				for _, c := range word[1:] {
+					flags = append(flags, "-"+string(c))
+				}
+				words = append(words[:i], append(flags, words[i+1:]...)...)
+				word = words[i]
+			}
+		}
+
+		// Option of --name=value format
+		if strings.HasPrefix(word, "--") {
+			if index := strings.Index(word, "="); index > 0 {
+				words = append(words[:i+1], append([]string{word[index+1:]}, words[i+1:]...)...)
+				word = word[:index]
+			}
+		}
+
+		if !strings.HasPrefix(word, "-") || word == "-" {
+			if len(item.URL) > 0 {
+				return nil, fmt.Errorf("unexpected argument of curl: %s", word)
+			}
+			item.URL = word
+			continue
+		}
+
+		if curlIgnoreFlags[word] {
+			continue
+		}
+
+		switch word {
+		case "-G", "--get":
+			getData = true
+			continue
+		case "-I", "--head":
+			method = "HEAD"
+			continue
+		}
+
+		if i+1 >= len(words) {
+			return nil, fmt.Errorf("curl option %s requires an argument", word)
+		}
+
+		i++
+		value := words[i]
+
+		if curlIgnoreArgFlags[word] {
+			continue
+		}
+
+		switch word {
+		case "--url":
+			item.URL = value
+
+		case "-X", "--request":
+			method = strings.ToUpper(value)
+			if ParseMethod(method) == MethodNone {
+				return nil, fmt.Errorf("unsupported request method: %s", value)
+			}
+
+		case "-H", "--header":
+			index := strings.Index(value, ":")
+			if index <= 0 {
+				return nil, fmt.Errorf("invalid header of curl: %s", value)
+			}
+			item.Headers[strings.TrimSpace(value[:index])] = strings.TrimSpace(value[index+1:])
+
+		case "-d", "--data", "--data-ascii", "--data-binary", "--data-raw", "--json":
+			content := value
+			if word != "--data-raw" {
+				if content, err = curlData(value, word != "-d" && word != "--data" && word != "--data-ascii"); err != nil {
+					return nil, err
+				}
+			}
+			if word == "--json" {
+				isJSON = true
+			}
+			data = append(data, content)
+
+		case "--data-urlencode":
+			content, err := curlURLEncodeData(value)
+			if err != nil {
+				return nil, err
+			}
+			data = append(data, content)
+
+		case "-u", "--user":
+			item.Headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(value))
+
+		case "-b", "--cookie":
+			if !strings.Contains(value, "=") {
+				return nil, fmt.Errorf("cookie file of curl is not supported: %s", value)
+			}
+			item.Headers["Cookie"] = value
+
+		case "-A", "--user-agent":
+			item.Headers["User-Agent"] = value
+
+		case "-e", "--referer":
+			item.Headers["Referer"] = value
+
+		case "-m", "--max-time":
+			seconds, err := strconv.ParseFloat(value, 64)
+			if err != nil || seconds <= 0 {
+				return nil, fmt.Errorf("invalid max time of curl: %s", value)
+			}
+			item.Timeout = time.Duration(seconds * float64(time.Second))
+
+		default:
+			return nil, fmt.Errorf("unsupported curl option: %s", word)
+		}
+	}
+
+	if len(item.URL) == 0 {
+		return nil, errors.New("URL not found in curl command")
+	}
+
+	if !HasScheme(item.URL) {
+		item.URL = "http://" + item.URL
+	}
+
+	if len(data) > 0 {
+		body := strings.Join(data, "&")
+
+		if getData {
+			separator := "?"
+			if strings.Contains(item.URL, "?") {
+				separator = "&"
+			}
+			item.URL += separator + body
+		} else {
+			item.Body = []byte(body)
+
+			hasContentType := false
+			for field := range item.Headers {
+				if CaseCompare(field, "Content-Type") == 0 {
+					hasContentType = true
+				}
+			}
+
+			if !hasContentType {
+				if isJSON {
+					item.Headers["Content-Type"] = "application/json"
+					item.Headers["Accept"] = "application/json"
+				} else {
+					item.Headers["Content-Type"] = "application/x-www-form-urlencoded"
+				}
+			}
+
+			if len(method) == 0 {
+				method = "POST"
+			}
+		}
+	}
+
+	if len(method) == 0 {
+		method = "GET"
+	}
+
+	item.Method = method
+
+	return item, nil
+}
@@ -0,0 +1,141 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		command string
+		words   []string
+	}{
+		{`curl  http://a`, []string{"curl", "http://a"}},
+		{`-H 'Accept: */*'`, []string{"-H", "Accept: */*"}},
+		{`-d '{"a": "b c"}'`, []string{"-d", `{"a": "b c"}`}},
+		{`-d "x \"y\" \$z \a"`, []string{"-d", `x "y" $z \a`}},
+		{`-d $'a\nb\tc\x41中\'d'`, []string{"-d", "a\nb\tcA中'd"}},
+		{`a\ b c`, []string{"a b", "c"}},
+		{"curl \\\n  -X POST \\\r\n  http://a", []string{"curl", "-X", "POST", "http://a"}},
+		{`'a'"b"c`, []string{"abc"}},
+		{`''`, []string{""}},
+		{"", nil},
+	}
+
+	for _, test := range tests {
+		words, err := splitCommand(test.command)
+		if err != nil {
+			t.Errorf("splitCommand(%q) failed: %v", test.command, err)
+			continue
+		}
+		if !reflect.DeepEqual(words, test.words) {
+			t.Errorf("splitCommand(%q) = %q, want %q", test.command, words, test.words)
+		}
+	}
+
+	for _, command := range []string{`'a`, `"a`, `$'a`, `a "b\"`} {
+		if _, err := splitCommand(command); err == nil {
+			t.Errorf("splitCommand(%q) should fail", command)
+		}
+	}
+}
+
+func TestParseCurl(t *testing.T) {
+	dir := t.TempDir()
+
+	file := filepath.Join(dir, "body.txt")
+	if err := os.WriteFile(file, []byte("a=1\nb=2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		command string
+		method  string
+		url     string
+		headers map[string]string
+		body    string
+	}{
+		{`curl example.com/api`, "GET", "http://example.com/api", nil, ""},
+		{`curl -I https://a/`, "HEAD", "https://a/", nil, ""},
+		{`curl -XPUT --url=http://a/ -sSL`, "PUT", "http://a/", nil, ""},
+		{`curl http://a/ -X delete -k --compressed -o /dev/null`, "DELETE", "http://a/", nil, ""},
+		{`curl -H 'Accept: */*' -H'X-Id:  7 ' -A agent -e http://r/ http://a/`, "GET", "http://a/",
+			map[string]string{"Accept": "*/*", "X-Id": "7", "User-Agent": "agent", "Referer": "http://r/"}, ""},
+		{`curl -u user:pass -b 'a=1; b=2' http://a/`, "GET", "http://a/",
+			map[string]string{"Authorization": "Basic dXNlcjpwYXNz", "Cookie": "a=1; b=2"}, ""},
+		{`curl -d a=1 -d b=2 http://a/`, "POST", "http://a/",
+			map[string]string{"Content-Type": "application/x-www-form-urlencoded"}, "a=1&b=2"},
+		{`curl -X PUT -H 'content-type: text/plain' --data-raw @raw http://a/`, "PUT", "http://a/",
+			map[string]string{"content-type": "text/plain"}, "@raw"},
+		{`curl --data-urlencode 'q=a b&c' --data-urlencode =x/y http://a/`, "POST", "http://a/",
+			map[string]string{"Content-Type": "application/x-www-form-urlencoded"}, "q=a+b%26c&x%2Fy"},
+		{`curl -G -d a=1 --data-urlencode 'b=c d' 'http://a/?x=1'`, "GET", "http://a/?x=1&a=1&b=c+d", nil, ""},
+		{`curl --json '{"id":1}' http://a/`, "POST", "http://a/",
+			map[string]string{"Content-Type": "application/json", "Accept": "application/json"}, `{"id":1}`},
+		{`curl -d @` + file + ` http://a/`, "POST", "http://a/",
+			map[string]string{"Content-Type": "application/x-www-form-urlencoded"}, "a=1b=2"},
+		{`curl --data-binary @` + file + ` http://a/`, "POST", "http://a/",
+			map[string]string{"Content-Type": "application/x-www-form-urlencoded"}, "a=1\nb=2\n"},
+		{`curl -H 'Content-Type: text/plain' -d '{{name}}' http://a/`, "POST", "http://a/",
+			map[string]string{"Content-Type": "text/plain"}, "{{name}}"},
+	}
+
+	for _, test := range tests {
+		item, err := ParseCurl(test.command)
+		if err != nil {
+			t.Errorf("ParseCurl(%s) failed: %v", test.command, err)
+			continue
+		}
+
+		if item.Method != test.method || item.URL != test.url || string(item.Body) != test.body || !item.Raw {
+			t.Errorf("ParseCurl(%s) = %s %s %q, want %s %s %q", test.command,
+				item.Method, item.URL, item.Body, test.method, test.url, test.body)
+		}
+
+		if len(item.Headers) != len(test.headers) {
+			t.Errorf("ParseCurl(%s) headers = %v, want %v", test.command, item.Headers, test.headers)
+			continue
+		}
+
+		for field, value := range test.headers {
+			if item.Headers[field] != value {
+				t.Errorf("ParseCurl(%s) header %s = %q, want %q", test.command, field, item.Headers[field], value)
+			}
+		}
+	}
+
+	item, err := ParseCurl(`curl -m 1.5 http://a/`)
+	if err != nil || item.Timeout != 1500*time.Millisecond {
+		t.Errorf("ParseCurl(-m 1.5) = %v, %v", item, err)
+	}
+}
+
+func TestParseCurlErrors(t *testing.T) {
+	tests := map[string]string{
+		`curl`:                       "URL not found",
+		`curl -H 'Accept: */*'`:      "URL not found",
+		`curl -X`:                    "requires an argument",
+		`curl -X FETCH http://a/`:    "unsupported request method",
+		`curl -H Accept http://a/`:   "invalid header",
+		`curl -b cookies.txt http:/`: "cookie file",
+		`curl -m 0 http://a/`:        "invalid max time",
+		`curl --proxy p http://a/`:   "unsupported curl option",
+		`curl http://a/ http://b/`:   "unexpected argument",
+		`curl -d @/not/exists http:`: "no such file",
+		`curl 'http://a/`:            "unterminated quote",
+	}
+
+	for command, want := range tests {
+		if _, err := ParseCurl(command); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ParseCurl(%s) = %v, want %q", command, err, want)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+)
+
+type endpointConfig struct {
+	Name    string            `json:"name" yaml:"name"`
+	URL     string            `json:"url" yaml:"url"`
+	Method  string            `json:"method" yaml:"method"`
+	Headers map[string]string `json:"headers" yaml:"headers"`
+	Params  map[string]string `json:"params" yaml:"params"`
+	Body    string            `json:"body" yaml:"body"`
+	Weight  int               `json:"weight" yaml:"weight"`
+}
+
+// Load weighted endpoints from JSON file
+// @param path: endpoints file path
+// @param base: target URL which relative endpoint URLs are joined to
+// @return: benchmark items
+func LoadEndpoints(path string, base string) ([]*BenchmarkItem, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var configs []endpointConfig
+
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err.Error())
+	}
+
+	if len(configs) == 0 {
+		return nil, fmt.Errorf("%s: no endpoint defined", path)
+	}
+
+	return buildEndpoints(configs, base, path)
+}
+
+func buildEndpoints(configs []endpointConfig, base string, path string) ([]*BenchmarkItem, error) {
+	var items []*BenchmarkItem
+
+	for i, config := range configs {
+		if config.Weight < 0 {
+			return nil, fmt.Errorf("%s: endpoint #%d weight cannot be negative", path, i+1)
+		}
+
+		url := config.URL
+		if strings.HasPrefix(url, "/") {
+			url = strings.TrimRight(base, "/") + url
+		}
+
+		if len(url) == 0 {
+			return nil, fmt.Errorf("%s: endpoint #%d URL has not set", path, i+1)
+		}
+
+		if !HasScheme(url) {
+			url = "http://" + url
+		}
+
+		name := config.Name
+		if len(name) == 0 {
+			name = url
+		}
+
+		var body []byte
+		if len(config.Body) > 0 {
+			body = []byte(config.Body)
+		}
+
+		items = append(items, &BenchmarkItem{
+			Name:    name,
+			URL:     url,
+			Headers: config.Headers,
+			Params:  config.Params,
+			Method:  config.Method,
+			Body:    body,
+			Weight:  config.Weight,
+		})
+	}
+
+	return items, nil
+}
+
+// Picker decides which item would be sent next and when
+type Picker interface {
+	// Pick next item and its send time offset from the start of benchmark,
+	// zero offset means sending immediately (not thread safe)
+	Pick() (*BenchmarkItem, time.Duration)
+}
+
+type ItemPicker struct {
+	items  []*BenchmarkItem
+	bounds []int
+	total  int
+	random *rand.Rand
+}
+
+// Create weighted picker for benchmark items,
+// item which weight is zero would be treated as one
+// @param items: benchmark items
+func NewItemPicker(items []*BenchmarkItem) *ItemPicker {
+	picker := &ItemPicker{
+		items:  items,
+		bounds: make([]int, len(items)),
+		random: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+
+	for i, item := range items {
+		weight := item.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		picker.total += weight
+		picker.bounds[i] = picker.total
+	}
+
+	return picker
+}
+
+// Pick a benchmark item by weight
+func (p *ItemPicker) Pick() (*BenchmarkItem, time.Duration) {
+	if len(p.items) == 1 {
+		return p.items[0], 0
+	}
+
+	n := p.random.Intn(p.total)
+
+	return p.items[sort.SearchInts(p.bounds, n+1)], 0
+}
+
+type SequencePicker struct {
+	items []*BenchmarkItem
+	index int
+	timed bool
+	speed float64
+	cycle time.Duration
+}
+
+// Create picker which sends items in order and starts over after the last one
+// @param items: benchmark items, Offset of item is the original send time
+// @param timed: keep the original send time of items
+// @param speed: replay speed when keeping original send time (etc: 2 means 2x faster)
+func NewSequencePicker(items []*BenchmarkItem, timed bool, speed float64) *SequencePicker {
+	if speed <= 0 {
+		speed = 1
+	}
+
+	picker := &SequencePicker{
+		items: items,
+		timed: timed,
+		speed: speed,
+	}
+
+	if len(items) > 0 {
+		// Next round starts after the last item of this round
+		picker.cycle = items[len(items)-1].Offset + time.Millisecond
+	}
+
+	return picker
+}
+
+func (p *SequencePicker) Pick() (*BenchmarkItem, time.Duration) {
+	round := p.index / len(p.items)
+	item := p.items[p.index%len(p.items)]
+
+	p.index++
+
+	if !p.timed {
+		return item, 0
+	}
+
+	offset := time.Duration(round)*p.cycle + item.Offset
+
+	return item, time.Duration(float64(offset) / p.speed)
+}
@@ -0,0 +1,344 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Response of request sent by executor
+type Response struct {
+	// Protocol status (etc: 200 of HTTP), empty if no response received
+	Status string
+	// Whether status means success, used when item has no expected status
+	OK   bool
+	Body []byte
+	// Received bytes, length of body is used if zero
+	Bytes int64
+	// Latency of request, measured by runner if zero
+	Elapsed time.Duration
+	// Time of opening new connection for request, zero if reused
+	Connect time.Duration
+	// Connection is closed by peer or broken
+	Disconnected bool
+	// Received messages which no request waited for (etc: WebSocket)
+	Dropped int64
+	// Time to first byte of body, count of events and time
+	// between events, set for streaming response
+	FirstByte time.Duration
+	Events    int64
+	EventGaps []time.Duration
+	// Negotiated protocol (etc: HTTP/2.0), empty if not reported
+	Protocol string
+	Err      error
+}
+
+// Executor sends requests of a protocol, it is shared by all
+// workers of runner so it must be thread safe
+type Executor interface {
+	// Build protocol request from request, it is not timed
+	Prepare(req *Request) (interface{}, error)
+	// Send prepared request and wait for response, the context
+	// is done when request timeout
+	Execute(ctx context.Context, prepared interface{}) *Response
+	// Release resources of executor (etc: connections)
+	Close() error
+}
+
+// Create executor for runner
+type ExecutorFactory func(config *Config) (Executor, error)
+
+var (
+	executorLock      sync.RWMutex
+	executorFactories = map[string]ExecutorFactory{
+		"http":  NewHTTPExecutor,
+		"https": NewHTTPExecutor,
+		"unix":  NewHTTPExecutor,
+	}
+)
+
+// Register executor of URL scheme, registered executor would be replaced
+// @param scheme: URL scheme (etc: redis of redis://127.0.0.1:6379)
+// @param factory: function to create executor
+func RegisterExecutor(scheme string, factory ExecutorFactory) {
+	executorLock.Lock()
+	executorFactories[strings.ToLower(scheme)] = factory
+	executorLock.Unlock()
+}
+
+// Create executor of URL scheme
+func NewExecutor(scheme string, config *Config) (Executor, error) {
+	executorLock.RLock()
+	factory, exists := executorFactories[scheme]
+	executorLock.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("unsupported protocol: %s", scheme)
+	}
+
+	return factory(config)
+}
+
+// Get lower case scheme of URL, default scheme is http
+func URLScheme(link string) string {
+	index := schemeIndex(link)
+	if index <= 0 {
+		return "http"
+	}
+	return strings.ToLower(link[:index])
+}
+
+// Get index of "://" after scheme of URL, -1 if URL has no scheme
+func schemeIndex(link string) int {
+	index := strings.Index(link, "://")
+	if index <= 0 {
+		return -1
+	}
+
+	// Characters of scheme are letters, digits, "+", "-" and "."
+	for i, c := range link[:index] {
+		letter := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		if !letter && (i == 0 || !strings.ContainsRune("0123456789+-.", c)) {
+			return -1
+		}
+	}
+
+	return index
+}
+
+// Clients of connections to the same server
+type httpGroup struct {
+	clients *httpClients
+	// Clients with limited streams, each client is repeated max
+	// streams times, nil if streams are not limited
+	streams chan *httpClients
+	// All clients of group, they are closed by close
+	all []*httpClients
+}
+
+type httpExecutor struct {
+	group      *httpGroup
+	protocol   string
+	zeroRTT    bool
+	stream     StreamOptions
+	conns      int
+	maxStreams int
+
+	// Clients of unix:// targets of each socket path
+	unixLock sync.Mutex
+	unix     map[string]*httpGroup
+}
+
+// Create executor of HTTP and HTTPS
+func NewHTTPExecutor(config *Config) (Executor, error) {
+	options := config.HTTP
+
+	if options.MaxStreams < 0 {
+		return nil, fmt.Errorf("invalid max streams: %d", options.MaxStreams)
+	}
+
+	if options.ZeroRTT && strings.ToLower(options.Protocol) != ProtocolH3 {
+		return nil, fmt.Errorf("0-RTT is only supported by h3 protocol")
+	}
+
+	executor := &httpExecutor{
+		protocol:   options.Protocol,
+		zeroRTT:    options.ZeroRTT,
+		stream:     config.Stream,
+		conns:      config.Connections,
+		maxStreams: options.MaxStreams,
+		unix:       make(map[string]*httpGroup),
+	}
+
+	group, err := newHTTPGroup(options.Protocol, true, config.UnixSocket, config.Connections, options.MaxStreams)
+	if err != nil {
+		return nil, err
+	}
+
+	executor.group = group
+
+	return executor, nil
+}
+
+// Create clients of server, shared clients are used if streams are not
+// limited and socket is empty, at most max streams of each connection
+// are used by connections workers
+func newHTTPGroup(protocol string, shared bool, socket string, connections, maxStreams int) (*httpGroup, error) {
+	// Streams of HTTP/1 are connections
+	if maxStreams == 0 || strings.ToLower(protocol) == ProtocolHTTP1 {
+		clients, err := newHTTPClients(protocol, shared, socket)
+		if err != nil {
+			return nil, err
+		}
+
+		return &httpGroup{clients: clients, all: []*httpClients{clients}}, nil
+	}
+
+	count := (connections + maxStreams - 1) / maxStreams
+	if count <= 0 {
+		count = 1
+	}
+
+	group := &httpGroup{}
+
+	for i := 0; i < count; i++ {
+		clients, err := newHTTPClients(protocol, false, socket)
+		if err != nil {
+			group.close()
+			return nil, err
+		}
+		group.all = append(group.all, clients)
+	}
+
+	group.streams = make(chan *httpClients, count*maxStreams)
+
+	// Interleave clients so requests are spread over connections
+	for i := 0; i < maxStreams; i++ {
+		for _, clients := range group.all {
+			group.streams <- clients
+		}
+	}
+
+	return group, nil
+}
+
+// Take clients with free stream, release must be called after response is read
+func (g *httpGroup) acquire() (*httpClients, func()) {
+	if g.streams == nil {
+		return g.clients, func() {}
+	}
+
+	clients := <-g.streams
+
+	return clients, func() {
+		g.streams <- clients
+	}
+}
+
+func (g *httpGroup) close() {
+	for _, clients := range g.all {
+		clients.close()
+	}
+}
+
+func (e *httpExecutor) Prepare(req *Request) (interface{}, error) {
+	request, err := req.httpRequest()
+	if err != nil {
+		return nil, err
+	}
+
+	if request.URL.Scheme == "unix" {
+		socket, err := rewriteUnixRequest(request)
+		if err != nil {
+			return nil, err
+		}
+		request = request.WithContext(context.WithValue(request.Context(), unixSocketKey{}, socket))
+	}
+
+	if err := checkHTTPProtocol(e.protocol, request.URL.Scheme); err != nil {
+		return nil, err
+	}
+
+	if e.zeroRTT {
+		request.Method = zeroRTTMethod(request.Method)
+	}
+
+	if !e.stream.Enabled {
+		return request, nil
+	}
+
+	return &httpStream{request: request, onEvent: req.onEvent}, nil
+}
+
+// Take client of request, release must be called after response is read
+func (e *httpExecutor) acquire(request *http.Request) (client *http.Client, release func(), err error) {
+	group := e.group
+
+	if socket, ok := request.Context().Value(unixSocketKey{}).(string); ok {
+		if group, err = e.unixGroup(socket); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	clients, release := group.acquire()
+
+	if request.URL.Scheme == "https" {
+		return clients.secure, release, nil
+	}
+
+	return clients.client, release, nil
+}
+
+// Get clients of Unix socket, they are created if not exist
+func (e *httpExecutor) unixGroup(socket string) (*httpGroup, error) {
+	e.unixLock.Lock()
+	defer e.unixLock.Unlock()
+
+	group, exists := e.unix[socket]
+	if !exists {
+		var err error
+
+		group, err = newHTTPGroup(e.protocol, false, socket, e.conns, e.maxStreams)
+		if err != nil {
+			return nil, err
+		}
+
+		e.unix[socket] = group
+	}
+
+	return group, nil
+}
+
+func (e *httpExecutor) Execute(ctx context.Context, prepared interface{}) *Response {
+	if call, ok := prepared.(*httpStream); ok {
+		return e.readStream(ctx, call)
+	}
+
+	request := prepared.(*http.Request)
+
+	client, release, err := e.acquire(request)
+	if err != nil {
+		return &Response{Err: err}
+	}
+	defer release()
+
+	rsp, err := client.Do(request.WithContext(ctx))
+	if err != nil {
+		return &Response{Err: err}
+	}
+
+	defer func() {
+		_ = rsp.Body.Close()
+	}()
+
+	body, err := ioutil.ReadAll(rsp.Body)
+
+	return &Response{
+		Status:   strconv.Itoa(rsp.StatusCode),
+		OK:       rsp.StatusCode == http.StatusOK,
+		Body:     body,
+		Protocol: rsp.Proto,
+		Err:      err,
+	}
+}
+
+func (e *httpExecutor) Close() error {
+	e.group.close()
+
+	e.unixLock.Lock()
+	for _, group := range e.unix {
+		group.close()
+	}
+	e.unixLock.Unlock()
+
+	return nil
+}
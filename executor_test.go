@@ -0,0 +1,122 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Executor echoes body of request, status is set by method
+type fakeExecutor struct {
+	closed int32
+}
+
+func (e *fakeExecutor) Prepare(req *Request) (interface{}, error) {
+	if strings.HasSuffix(req.GetURL(), "/invalid") {
+		return nil, errors.New("invalid request")
+	}
+	return req, nil
+}
+
+func (e *fakeExecutor) Execute(ctx context.Context, prepared interface{}) *Response {
+	req := prepared.(*Request)
+
+	if strings.HasSuffix(req.GetURL(), "/slow") {
+		<-ctx.Done()
+		return &Response{Err: ctx.Err()}
+	}
+
+	status := "OK"
+	if req.GetMethod() == "DELETE" {
+		status = "ERR"
+	}
+
+	return &Response{
+		Status:  status,
+		OK:      status == "OK",
+		Body:    req.GetBody(),
+		Elapsed: 2 * time.Millisecond,
+	}
+}
+
+func (e *fakeExecutor) Close() error {
+	atomic.StoreInt32(&e.closed, 1)
+	return nil
+}
+
+func TestExecutorRegister(t *testing.T) {
+	executor := &fakeExecutor{}
+
+	RegisterExecutor("FAKE", func(config *Config) (Executor, error) {
+		return executor, nil
+	})
+
+	config := NewConfig()
+	config.Timeout = 20 * time.Millisecond
+	config.Requests = 100
+	config.Items = []*BenchmarkItem{
+		{Name: "ok", Weight: 2, URL: "fake://host/ok", Method: "POST", Body: []byte("abc")},
+		{Name: "error", Weight: 1, URL: "fake://host/error", Method: "DELETE"},
+		{Name: "invalid", Weight: 1, URL: "fake://host/invalid"},
+		{Name: "slow", Weight: 1, URL: "fake://host/slow"},
+	}
+
+	result := runBenchmark(t, config)
+
+	if atomic.LoadInt32(&executor.closed) != 1 {
+		t.Error("executor has not been closed")
+	}
+
+	snapshot := result.Stats.Snapshot()
+
+	ok := snapshot.Groups["ok"]
+	if ok.Success != ok.Total || ok.RecvBytes != ok.Total*3 || ok.Status["OK"] != ok.Total || ok.MinTime != 2 {
+		t.Errorf("group ok = %+v", ok)
+	}
+
+	for _, name := range []string{"error", "invalid", "slow"} {
+		if group := snapshot.Groups[name]; group.Total == 0 || group.Failure != group.Total {
+			t.Errorf("group %s: total = %d, failure = %d", name, group.Total, group.Failure)
+		}
+	}
+
+	if snapshot.Groups["error"].Status["ERR"] == 0 || len(snapshot.Groups["invalid"].Status) > 0 {
+		t.Errorf("status = %v", snapshot.Status)
+	}
+
+	if report := result.Report(""); report.Summary.Status["OK"] != ok.Total {
+		t.Errorf("report status = %v", report.Summary.Status)
+	}
+}
+
+func TestExecutorUnsupported(t *testing.T) {
+	config := NewConfig()
+	config.Target = "unknown://127.0.0.1"
+
+	if _, err := NewRunner(config); err == nil || !strings.Contains(err.Error(), "unknown") {
+		t.Errorf("NewRunner() error = %v, want unsupported protocol", err)
+	}
+}
+
+func TestURLScheme(t *testing.T) {
+	tests := map[string]string{
+		"http://a":        "http",
+		"HTTPS://a":       "https",
+		"redis://a:6379":  "redis",
+		"example.com/a":   "http",
+		"/path?u=http://": "http",
+	}
+
+	for link, want := range tests {
+		if got := URLScheme(link); got != want {
+			t.Errorf("URLScheme(%q) = %q, want %q", link, got, want)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	FeedSequential = iota
+	FeedRandom
+	FeedPartition
+)
+
+type Feeder struct {
+	Columns []string
+
+	rows []map[string]string
+	mode int
+	once bool
+
+	mutex   sync.Mutex
+	cursor  int
+	order   []int
+	parts   [][]map[string]string
+	cursors []int
+	random  *rand.Rand
+}
+
+func ParseFeedMode(mode string) (int, error) {
+	switch strings.ToLower(mode) {
+	case "", "sequential":
+		return FeedSequential, nil
+	case "random":
+		return FeedRandom, nil
+	case "partition":
+		return FeedPartition, nil
+	}
+
+	return 0, fmt.Errorf("unknown feeder mode: %s", mode)
+}
+
+// Create feeder from CSV or JSON lines file
+// @param path: data file path, CSV file must has header row
+// @param mode: how rows are consumed (sequential, random or partition)
+// @param once: stop at the end of data instead of wrapping around
+// @param workers: how many partitions when using partition mode
+func NewFeeder(path string, mode int, once bool, workers int) (*Feeder, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		columns []string
+		rows    []map[string]string
+	)
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		columns, rows, err = parseCSVRows(data)
+	case ".json", ".jsonl", ".ndjson":
+		columns, rows, err = parseJSONRows(data)
+	default:
+		if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+			columns, rows, err = parseJSONRows(data)
+		} else {
+			columns, rows, err = parseCSVRows(data)
+		}
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err.Error())
+	}
+
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%s: no data row found", path)
+	}
+
+	feeder := &Feeder{
+		Columns: columns,
+		rows:    rows,
+		mode:    mode,
+		once:    once,
+		random:  rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+
+	switch mode {
+	case FeedRandom:
+		feeder.order = feeder.random.Perm(len(rows))
+	case FeedPartition:
+		if workers <= 0 {
+			workers = 1
+		}
+		if workers > len(rows) {
+			workers = len(rows)
+		}
+		for i := 0; i < workers; i++ {
+			feeder.parts = append(feeder.parts, rows[i*len(rows)/workers:(i+1)*len(rows)/workers])
+		}
+		feeder.cursors = make([]int, workers)
+	}
+
+	return feeder, nil
+}
+
+func parseCSVRows(data []byte) ([]string, []map[string]string, error) {
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, nil, errors.New("header row not found")
+	}
+
+	columns := records[0]
+
+	var rows []map[string]string
+
+	for _, record := range records[1:] {
+		row := make(map[string]string, len(columns))
+		for i, column := range columns {
+			if i < len(record) {
+				row[column] = record[i]
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return columns, rows, nil
+}
+
+func parseJSONRows(data []byte) ([]string, []map[string]string, error) {
+	var (
+		columns []string
+		rows    []map[string]string
+		seen    = make(map[string]bool)
+		lineNo  = 0
+	)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	for scanner.Scan() {
+		lineNo++
+
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var fields map[string]interface{}
+
+		decoder := json.NewDecoder(bytes.NewReader(line))
+		decoder.UseNumber()
+
+		if err := decoder.Decode(&fields); err != nil {
+			return nil, nil, fmt.Errorf("line %d: %s", lineNo, err.Error())
+		}
+
+		row := make(map[string]string, len(fields))
+
+		for field, value := range fields {
+			switch v := value.(type) {
+			case string:
+				row[field] = v
+			case nil:
+				row[field] = ""
+			case json.Number, bool:
+				row[field] = fmt.Sprint(v)
+			default:
+				encoded, _ := json.Marshal(v)
+				row[field] = string(encoded)
+			}
+
+			if !seen[field] {
+				seen[field] = true
+				columns = append(columns, field)
+			}
+		}
+
+		rows = append(rows, row)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return columns, rows, nil
+}
+
+// Get next data row (thread safe)
+// @param worker: index of worker which requests the row, used by partition mode
+// @return: data row, false if all rows were consumed and wrapping is disabled
+func (f *Feeder) Next(worker int) (map[string]string, bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	switch f.mode {
+	case FeedRandom:
+		if !f.once {
+			return f.rows[f.random.Intn(len(f.rows))], true
+		}
+		if f.cursor >= len(f.order) {
+			return nil, false
+		}
+		row := f.rows[f.order[f.cursor]]
+		f.cursor++
+		return row, true
+
+	case FeedPartition:
+		index := worker % len(f.parts)
+		part := f.parts[index]
+		if f.cursors[index] >= len(part) {
+			if f.once {
+				return nil, false
+			}
+			f.cursors[index] = 0
+		}
+		row := part[f.cursors[index]]
+		f.cursors[index]++
+		return row, true
+	}
+
+	if f.cursor >= len(f.rows) {
+		if f.once {
+			return nil, false
+		}
+		f.cursor = 0
+	}
+
+	row := f.rows[f.cursor]
+	f.cursor++
+
+	return row, true
+}
+
+// All rows are used in once mode, Next would not return any row
+func (f *Feeder) Exhausted() bool {
+	if !f.once {
+		return false
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	switch f.mode {
+	case FeedRandom:
+		return f.cursor >= len(f.order)
+	case FeedPartition:
+		for index, part := range f.parts {
+			if f.cursors[index] < len(part) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return f.cursor >= len(f.rows)
+}
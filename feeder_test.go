@@ -0,0 +1,147 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func writeTestData(t *testing.T, name string, lines []string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestFeederModes(t *testing.T) {
+	csvPath := writeTestData(t, "users.csv", []string{"id,name", "1,a", "2,b", "3,c"})
+	jsonPath := writeTestData(t, "users.jsonl", []string{`{"id": 1, "name": "a"}`, `{"id": 2, "name": "b"}`, `{"id": 3, "name": "c", "tags": ["x"]}`})
+
+	for _, path := range []string{csvPath, jsonPath} {
+		feeder, err := NewFeeder(path, FeedSequential, true, 1)
+		if err != nil {
+			t.Fatalf("NewFeeder(%s) failed: %v", path, err)
+		}
+
+		var ids []string
+		for {
+			row, ok := feeder.Next(0)
+			if !ok {
+				break
+			}
+			ids = append(ids, row["id"])
+		}
+
+		if strings.Join(ids, ",") != "1,2,3" {
+			t.Errorf("%s: ids = %v, want 1,2,3", path, ids)
+		}
+
+		if !feeder.Exhausted() {
+			t.Errorf("%s: feeder is not exhausted", path)
+		}
+	}
+
+	// Random mode with once consumes every row exactly once
+	feeder, err := NewFeeder(csvPath, FeedRandom, true, 1)
+	if err != nil {
+		t.Fatalf("NewFeeder() failed: %v", err)
+	}
+
+	seen := make(map[string]bool)
+	for row, ok := feeder.Next(0); ok; row, ok = feeder.Next(0) {
+		seen[row["id"]] = true
+	}
+
+	if len(seen) != 3 {
+		t.Errorf("random rows = %v, want 3 rows", seen)
+	}
+
+	if !feeder.Exhausted() {
+		t.Error("random feeder is not exhausted")
+	}
+
+	// Rows are reused without once
+	if feeder, _ := NewFeeder(csvPath, FeedSequential, false, 1); feeder.Exhausted() {
+		t.Error("feeder without once is exhausted")
+	}
+
+	if _, err := ParseFeedMode("shuffle"); err == nil {
+		t.Error("ParseFeedMode(shuffle) should fail")
+	}
+}
+
+func TestFeederPartition(t *testing.T) {
+	const (
+		workers = 4
+		total   = 40
+	)
+
+	lines := []string{"id"}
+	for i := 0; i < total; i++ {
+		lines = append(lines, fmt.Sprint(i))
+	}
+
+	feeder, err := NewFeeder(writeTestData(t, "ids.csv", lines), FeedPartition, true, workers)
+	if err != nil {
+		t.Fatalf("NewFeeder() failed: %v", err)
+	}
+
+	pool := NewGoPool(workers)
+	defer pool.Stop()
+
+	var (
+		lock   sync.Mutex
+		owners = make(map[string]map[int]bool)
+	)
+
+	// Rows are requested by the worker coroutines which run the jobs,
+	// any worker may run any job
+	job := func(worker int, args ...interface{}) interface{} {
+		row, ok := feeder.Next(worker)
+		if ok {
+			lock.Lock()
+			if owners[row["id"]] == nil {
+				owners[row["id"]] = make(map[int]bool)
+			}
+			owners[row["id"]][worker] = true
+			lock.Unlock()
+		}
+		time.Sleep(time.Millisecond)
+		return nil
+	}
+
+	var pipes []<-chan interface{}
+	for i := 0; i < total*2; i++ {
+		pipes = append(pipes, pool.DoWorker(job))
+	}
+
+	for _, pipe := range pipes {
+		<-pipe
+	}
+
+	if len(owners) != total {
+		t.Errorf("consumed rows = %d, want %d", len(owners), total)
+	}
+
+	if !feeder.Exhausted() {
+		t.Error("partition feeder is not exhausted")
+	}
+
+	for id, workers := range owners {
+		if len(workers) != 1 {
+			t.Errorf("row %s is consumed by workers %v", id, workers)
+		}
+	}
+}
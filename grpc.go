@@ -0,0 +1,503 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const (
+	// Timeout of resolving method by server reflection
+	grpcReflectionTimeout = 10 * time.Second
+)
+
+// Options of gRPC executor, methods are called by URL
+// grpc://host:port/package.Service/Method (grpcs:// for TLS)
+type GRPCOptions struct {
+	// Compiled descriptor set file (etc: protoc --include_imports
+	// --descriptor_set_out), server reflection is used if empty
+	Protoset string
+	// CA certificate file to verify server, system CAs are used if empty
+	CACert string
+	// Server name to verify certificate, host of URL is used if empty
+	ServerName string
+	// Skip verifying certificate of server
+	Insecure bool
+}
+
+type grpcMethod struct {
+	desc  protoreflect.MethodDescriptor
+	types *dynamicpb.Types
+	// Closed after resolved, err is set if failed
+	ready chan struct{}
+	err   error
+}
+
+// Connections of host, used in turn by requests
+type grpcConns struct {
+	conns []*grpc.ClientConn
+	next  int
+}
+
+type grpcCall struct {
+	conn     *grpc.ClientConn
+	method   *grpcMethod
+	path     string
+	request  proto.Message
+	metadata metadata.MD
+}
+
+type grpcExecutor struct {
+	tls *tls.Config
+	// Descriptors of protoset, nil if server reflection is used
+	files *protoregistry.Files
+
+	// Max connections of each host
+	size int
+
+	lock    sync.Mutex
+	conns   map[string]*grpcConns
+	methods map[string]*grpcMethod
+}
+
+func init() {
+	RegisterExecutor("grpc", NewGRPCExecutor)
+	RegisterExecutor("grpcs", NewGRPCExecutor)
+}
+
+// Create executor of gRPC unary and server streaming methods,
+// request message is JSON body and headers are sent as metadata,
+// up to config.Connections connections are opened to each host
+func NewGRPCExecutor(config *Config) (Executor, error) {
+	options := config.GRPC
+
+	executor := &grpcExecutor{
+		tls: &tls.Config{
+			ServerName:         options.ServerName,
+			InsecureSkipVerify: options.Insecure,
+		},
+		size:    config.Connections,
+		conns:   make(map[string]*grpcConns),
+		methods: make(map[string]*grpcMethod),
+	}
+
+	if executor.size < 1 {
+		executor.size = 1
+	}
+
+	if len(options.CACert) > 0 {
+		data, err := ioutil.ReadFile(options.CACert)
+		if err != nil {
+			return nil, err
+		}
+
+		executor.tls.RootCAs = x509.NewCertPool()
+		if !executor.tls.RootCAs.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("%s: no certificate found", options.CACert)
+		}
+	}
+
+	if len(options.Protoset) > 0 {
+		files, err := loadProtoset(options.Protoset)
+		if err != nil {
+			return nil, err
+		}
+
+		executor.files = files
+	}
+
+	return executor, nil
+}
+
+// Load descriptors of compiled descriptor set file
+func loadProtoset(path string) (*protoregistry.Files, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	set := &descriptorpb.FileDescriptorSet{}
+
+	if err := proto.Unmarshal(data, set); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err.Error())
+	}
+
+	files, err := protodesc.NewFiles(set)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err.Error())
+	}
+
+	return files, nil
+}
+
+// Parse URL of method, the path is /package.Service/Method
+func parseGRPCURL(link string) (*url.URL, string, string, error) {
+	info, err := url.Parse(link)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	fields := strings.Split(strings.Trim(info.Path, "/"), "/")
+	if len(fields) != 2 || len(fields[0]) == 0 || len(fields[1]) == 0 {
+		return nil, "", "", fmt.Errorf("invalid gRPC method: %s, expect /package.Service/Method", info.Path)
+	}
+
+	return info, fields[0], fields[1], nil
+}
+
+func (e *grpcExecutor) Prepare(req *Request) (interface{}, error) {
+	info, service, name, err := parseGRPCURL(req.GetURL())
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := e.conn(info)
+	if err != nil {
+		return nil, err
+	}
+
+	method, err := e.method(conn, info.Host, service, name)
+	if err != nil {
+		return nil, err
+	}
+
+	request := dynamicpb.NewMessage(method.desc.Input())
+
+	if body := req.GetBody(); len(body) > 0 {
+		options := protojson.UnmarshalOptions{Resolver: method.types}
+		if err := options.Unmarshal(body, request); err != nil {
+			return nil, fmt.Errorf("invalid message of %s: %s", method.desc.FullName(), err.Error())
+		}
+	}
+
+	md := metadata.MD{}
+	for field, value := range req.GetHeaders() {
+		md.Set(field, value)
+	}
+
+	return &grpcCall{
+		conn:     conn,
+		method:   method,
+		path:     "/" + service + "/" + name,
+		request:  request,
+		metadata: md,
+	}, nil
+}
+
+// Get connection of host in turn, connections are created
+// until the max connections of each host
+func (e *grpcExecutor) conn(info *url.URL) (*grpc.ClientConn, error) {
+	key := strings.ToLower(info.Scheme) + "://" + info.Host
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	group, exists := e.conns[key]
+	if !exists {
+		group = &grpcConns{}
+		e.conns[key] = group
+	}
+
+	if len(group.conns) < e.size {
+		creds := insecure.NewCredentials()
+		if strings.ToLower(info.Scheme) == "grpcs" {
+			creds = credentials.NewTLS(e.tls)
+		}
+
+		// No connection is made until the first call
+		conn, err := grpc.NewClient(info.Host, grpc.WithTransportCredentials(creds))
+		if err != nil {
+			return nil, err
+		}
+
+		group.conns = append(group.conns, conn)
+
+		return conn, nil
+	}
+
+	conn := group.conns[group.next]
+	group.next = (group.next + 1) % len(group.conns)
+
+	return conn, nil
+}
+
+// Get descriptor of method from protoset or server reflection,
+// the method is resolved once by the first request of it
+func (e *grpcExecutor) method(conn *grpc.ClientConn, host, service, name string) (*grpcMethod, error) {
+	key := host + "/" + service + "/" + name
+
+	e.lock.Lock()
+	method, exists := e.methods[key]
+	if !exists {
+		method = &grpcMethod{ready: make(chan struct{})}
+		e.methods[key] = method
+	}
+	e.lock.Unlock()
+
+	if exists {
+		<-method.ready
+		if method.err != nil {
+			return nil, method.err
+		}
+		return method, nil
+	}
+
+	method.desc, method.types, method.err = e.resolve(conn, service, name)
+	if method.err != nil {
+		// Resolved again by later requests
+		e.lock.Lock()
+		delete(e.methods, key)
+		e.lock.Unlock()
+	}
+
+	close(method.ready)
+
+	if method.err != nil {
+		return nil, method.err
+	}
+
+	return method, nil
+}
+
+// Resolve descriptor of method from protoset or server reflection
+func (e *grpcExecutor) resolve(conn *grpc.ClientConn, service, name string) (protoreflect.MethodDescriptor, *dynamicpb.Types, error) {
+	files := e.files
+	if files == nil {
+		var err error
+
+		files, err = reflectFiles(conn, service)
+		if err != nil {
+			return nil, nil, fmt.Errorf("reflect service %s: %s", service, err.Error())
+		}
+	}
+
+	desc, err := files.FindDescriptorByName(protoreflect.FullName(service))
+	if err != nil {
+		return nil, nil, fmt.Errorf("service %s not found", service)
+	}
+
+	serviceDesc, ok := desc.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s is not a service", service)
+	}
+
+	methodDesc := serviceDesc.Methods().ByName(protoreflect.Name(name))
+	if methodDesc == nil {
+		return nil, nil, fmt.Errorf("method %s not found in service %s", name, service)
+	}
+
+	if methodDesc.IsStreamingClient() {
+		return nil, nil, fmt.Errorf("client streaming method %s is unsupported", methodDesc.FullName())
+	}
+
+	return methodDesc, dynamicpb.NewTypes(files), nil
+}
+
+// Get file of service and its dependencies by server reflection
+func reflectFiles(conn *grpc.ClientConn, service string) (*protoregistry.Files, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), grpcReflectionTimeout)
+	defer cancel()
+
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		files   = make(map[string]*descriptorpb.FileDescriptorProto)
+		pending = []*reflectionpb.ServerReflectionRequest{{
+			MessageRequest: &reflectionpb.ServerReflectionRequest_FileContainingSymbol{
+				FileContainingSymbol: service,
+			},
+		}}
+	)
+
+	for len(pending) > 0 {
+		request := pending[0]
+		pending = pending[1:]
+
+		// Dependency may be sent with previous response
+		if name := request.GetFileByFilename(); len(name) > 0 && files[name] != nil {
+			continue
+		}
+
+		if err := stream.Send(request); err != nil {
+			return nil, err
+		}
+
+		rsp, err := stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+
+		if failure := rsp.GetErrorResponse(); failure != nil {
+			return nil, errors.New(failure.GetErrorMessage())
+		}
+
+		for _, data := range rsp.GetFileDescriptorResponse().GetFileDescriptorProto() {
+			file := &descriptorpb.FileDescriptorProto{}
+			if err := proto.Unmarshal(data, file); err != nil {
+				return nil, err
+			}
+
+			if files[file.GetName()] != nil {
+				continue
+			}
+
+			files[file.GetName()] = file
+
+			for _, dependency := range file.GetDependency() {
+				if files[dependency] == nil {
+					pending = append(pending, &reflectionpb.ServerReflectionRequest{
+						MessageRequest: &reflectionpb.ServerReflectionRequest_FileByFilename{
+							FileByFilename: dependency,
+						},
+					})
+				}
+			}
+		}
+	}
+
+	_ = stream.CloseSend()
+
+	set := &descriptorpb.FileDescriptorSet{}
+	for _, file := range files {
+		set.File = append(set.File, file)
+	}
+
+	return protodesc.NewFiles(set)
+}
+
+func (e *grpcExecutor) Execute(ctx context.Context, prepared interface{}) *Response {
+	call := prepared.(*grpcCall)
+	desc := call.method.desc
+
+	ctx = metadata.NewOutgoingContext(ctx, call.metadata)
+
+	var (
+		messages []proto.Message
+		err      error
+	)
+
+	if desc.IsStreamingServer() {
+		messages, err = call.stream(ctx)
+	} else {
+		message := dynamicpb.NewMessage(desc.Output())
+		err = call.conn.Invoke(ctx, call.path, call.request, message)
+		messages = append(messages, message)
+	}
+
+	code := status.Code(err)
+
+	rsp := &Response{
+		Status: code.String(),
+		OK:     code == codes.OK,
+		Err:    err,
+	}
+
+	if err != nil {
+		return rsp
+	}
+
+	options := protojson.MarshalOptions{Resolver: call.method.types}
+
+	var bodies [][]byte
+
+	for _, message := range messages {
+		body, err := options.Marshal(message)
+		if err != nil {
+			rsp.Err = err
+			return rsp
+		}
+
+		bodies = append(bodies, body)
+		rsp.Bytes += int64(proto.Size(message))
+	}
+
+	// Messages of server streaming method are returned as JSON array
+	if desc.IsStreamingServer() {
+		rsp.Body = append(append([]byte("["), bytes.Join(bodies, []byte(","))...), ']')
+	} else {
+		rsp.Body = bodies[0]
+	}
+
+	return rsp
+}
+
+// Call server streaming method and receive all messages
+func (call *grpcCall) stream(ctx context.Context) ([]proto.Message, error) {
+	stream, err := call.conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, call.path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Status of call is returned by RecvMsg if sending failed with EOF
+	if err := stream.SendMsg(call.request); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	if err := stream.CloseSend(); err != nil {
+		return nil, err
+	}
+
+	var messages []proto.Message
+
+	for {
+		message := dynamicpb.NewMessage(call.method.desc.Output())
+
+		err := stream.RecvMsg(message)
+		if err == io.EOF {
+			return messages, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, message)
+	}
+}
+
+func (e *grpcExecutor) Close() error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	var err error
+
+	for key, group := range e.conns {
+		for _, conn := range group.conns {
+			if closeErr := conn.Close(); closeErr != nil {
+				err = closeErr
+			}
+		}
+		delete(e.conns, key)
+	}
+
+	return err
+}
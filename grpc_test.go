@@ -0,0 +1,322 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// Descriptor of server streaming service for test:
+// service Stream { rpc List(StringValue) returns (stream StringValue) }
+func testStreamFile() *descriptorpb.FileDescriptorProto {
+	return &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("bench/stream.proto"),
+		Package:    proto.String("bench"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/protobuf/wrappers.proto"},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Stream"),
+			Method: []*descriptorpb.MethodDescriptorProto{{
+				Name:            proto.String("List"),
+				InputType:       proto.String(".google.protobuf.StringValue"),
+				OutputType:      proto.String(".google.protobuf.StringValue"),
+				ServerStreaming: proto.Bool(true),
+			}},
+		}},
+	}
+}
+
+// Resolve descriptors of test files, then global files
+type testResolver struct {
+	files *protoregistry.Files
+}
+
+func (r *testResolver) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	if file, err := r.files.FindFileByPath(path); err == nil {
+		return file, nil
+	}
+	return protoregistry.GlobalFiles.FindFileByPath(path)
+}
+
+func (r *testResolver) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	if desc, err := r.files.FindDescriptorByName(name); err == nil {
+		return desc, nil
+	}
+	return protoregistry.GlobalFiles.FindDescriptorByName(name)
+}
+
+// Send 3 messages of request value and x-token metadata
+func streamList(srv interface{}, stream grpc.ServerStream) error {
+	request := &wrapperspb.StringValue{}
+	if err := stream.RecvMsg(request); err != nil {
+		return err
+	}
+
+	md, _ := metadata.FromIncomingContext(stream.Context())
+
+	for i := 0; i < 3; i++ {
+		value := fmt.Sprintf("%s-%s-%d", request.Value, strings.Join(md.Get("x-token"), ","), i)
+		if err := stream.SendMsg(wrapperspb.String(value)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Start gRPC server with health, reflection and stream services
+func newTestGRPCServer(t *testing.T) string {
+	t.Helper()
+
+	file, err := protodesc.NewFile(testStreamFile(), protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := &protoregistry.Files{}
+	if err := files.RegisterFile(file); err != nil {
+		t.Fatal(err)
+	}
+
+	server := grpc.NewServer()
+
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(server, healthServer)
+
+	server.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "bench.Stream",
+		HandlerType: (*interface{})(nil),
+		Streams: []grpc.StreamDesc{{
+			StreamName:    "List",
+			Handler:       streamList,
+			ServerStreams: true,
+		}},
+	}, struct{}{})
+
+	reflectionpb.RegisterServerReflectionServer(server, reflection.NewServerV1(reflection.ServerOptions{
+		Services:           server,
+		DescriptorResolver: &testResolver{files: files},
+	}))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		_ = server.Serve(listener)
+	}()
+
+	t.Cleanup(server.Stop)
+
+	return listener.Addr().String()
+}
+
+// Write protoset of stream service for test
+func writeTestProtoset(t *testing.T) string {
+	t.Helper()
+
+	set := &descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{
+			protodesc.ToFileDescriptorProto(wrapperspb.File_google_protobuf_wrappers_proto),
+			testStreamFile(),
+		},
+	}
+
+	data, err := proto.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "stream.protoset")
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestGRPCReflection(t *testing.T) {
+	addr := newTestGRPCServer(t)
+
+	config := NewConfig()
+	config.Requests = 60
+	config.Items = []*BenchmarkItem{
+		{Name: "serving", URL: "grpc://" + addr + "/grpc.health.v1.Health/Check", Body: []byte(`{"service": ""}`)},
+		{Name: "unknown", URL: "grpc://" + addr + "/grpc.health.v1.Health/Check", Body: []byte(`{"service": "unknown"}`)},
+	}
+
+	var bodies = make(chan string, 60)
+
+	config.Hooks.AfterResponse = func(req *Request, rsp *Response) bool {
+		bodies <- string(rsp.Body)
+		return true
+	}
+
+	snapshot := runBenchmark(t, config).Stats.Snapshot()
+
+	serving, unknown := snapshot.Groups["serving"], snapshot.Groups["unknown"]
+
+	if serving.Total == 0 || serving.Success != serving.Total || serving.Status["OK"] != serving.Total {
+		t.Errorf("group serving = %+v", serving)
+	}
+
+	if unknown.Total == 0 || unknown.Failure != unknown.Total || unknown.Status["NotFound"] != unknown.Total {
+		t.Errorf("group unknown = %+v", unknown)
+	}
+
+	if serving.RecvBytes == 0 {
+		t.Error("received bytes of messages are not counted")
+	}
+
+	if body := <-bodies; body != `{"status":"SERVING"}` {
+		t.Errorf("body = %s", body)
+	}
+}
+
+func TestGRPCStream(t *testing.T) {
+	addr := newTestGRPCServer(t)
+
+	// Descriptors of stream service are loaded from protoset or reflection
+	for _, protoset := range []string{writeTestProtoset(t), ""} {
+		config := NewConfig()
+		config.GRPC.Protoset = protoset
+
+		executor, err := NewGRPCExecutor(config)
+		if err != nil {
+			t.Fatalf("NewGRPCExecutor() failed: %v", err)
+		}
+		defer executor.Close()
+
+		req := NewRequest(URLOption("grpc://"+addr+"/bench.Stream/List"),
+			BodyOption([]byte(`"abc"`)), HeaderOption("X-Token", "t1"))
+
+		prepared, err := executor.Prepare(req)
+		if err != nil {
+			t.Fatalf("Prepare() with protoset %q failed: %v", protoset, err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		rsp := executor.Execute(ctx, prepared)
+		if rsp.Err != nil || rsp.Status != "OK" || !rsp.OK {
+			t.Fatalf("Execute() with protoset %q = %+v", protoset, rsp)
+		}
+
+		if body := string(rsp.Body); body != `["abc-t1-0","abc-t1-1","abc-t1-2"]` {
+			t.Errorf("body with protoset %q = %s", protoset, body)
+		}
+	}
+}
+
+func TestGRPCInvalidRequest(t *testing.T) {
+	addr := newTestGRPCServer(t)
+
+	executor, err := NewGRPCExecutor(NewConfig())
+	if err != nil {
+		t.Fatalf("NewGRPCExecutor() failed: %v", err)
+	}
+	defer executor.Close()
+
+	tests := []struct {
+		url, body string
+	}{
+		{"grpc://" + addr + "/Check", ""},
+		{"grpc://" + addr + "/grpc.health.v1.Health/Unknown", ""},
+		{"grpc://" + addr + "/unknown.Service/Check", ""},
+		{"grpc://" + addr + "/grpc.health.v1.Health/Check", `{"unknown": 1}`},
+	}
+
+	for _, test := range tests {
+		req := NewRequest(URLOption(test.url), BodyOption([]byte(test.body)))
+		if _, err := executor.Prepare(req); err == nil {
+			t.Errorf("Prepare(%s, %s) should fail", test.url, test.body)
+		}
+	}
+
+	if _, err := NewGRPCExecutor(&Config{GRPC: GRPCOptions{Protoset: "not-exists.protoset"}}); err == nil {
+		t.Error("NewGRPCExecutor() should fail without protoset")
+	}
+}
+
+func TestGRPCConnections(t *testing.T) {
+	addr := newTestGRPCServer(t)
+
+	config := NewConfig()
+	config.Connections = 3
+
+	executor, err := NewGRPCExecutor(config)
+	if err != nil {
+		t.Fatalf("NewGRPCExecutor() failed: %v", err)
+	}
+	defer executor.Close()
+
+	// Methods are resolved once by concurrent requests
+	calls := make(chan *grpcCall, 12)
+
+	var group sync.WaitGroup
+
+	for i := 0; i < cap(calls); i++ {
+		group.Add(1)
+		go func() {
+			defer group.Done()
+
+			req := NewRequest(URLOption("grpc://"+addr+"/grpc.health.v1.Health/Check"), BodyOption([]byte(`{}`)))
+
+			prepared, err := executor.Prepare(req)
+			if err != nil {
+				t.Errorf("Prepare() failed: %v", err)
+				return
+			}
+
+			calls <- prepared.(*grpcCall)
+		}()
+	}
+
+	group.Wait()
+	close(calls)
+
+	conns := make(map[*grpc.ClientConn]int)
+	methods := make(map[*grpcMethod]bool)
+
+	for call := range calls {
+		conns[call.conn]++
+		methods[call.method] = true
+	}
+
+	// Connections of host are used in turn
+	if len(conns) != 3 || len(methods) != 1 {
+		t.Fatalf("conns = %d, methods = %d", len(conns), len(methods))
+	}
+
+	for _, count := range conns {
+		if count != 4 {
+			t.Errorf("calls of conns = %v", conns)
+			break
+		}
+	}
+}
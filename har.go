@@ -0,0 +1,244 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+type HAROptions struct {
+	// Only keep entries which request these hosts, keep all if empty
+	Hosts []string
+	// Remove cookies from requests
+	StripCookies bool
+	// Build weighted mix of distinct requests instead of sequence
+	Mix bool
+	// Check responses against recorded status and content
+	Validate bool
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+}
+
+type harEntry struct {
+	StartedDateTime time.Time    `json:"startedDateTime"`
+	Time            float64      `json:"time"`
+	Request         harRequest   `json:"request"`
+	Response        *harResponse `json:"response,omitempty"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harLog struct {
+	Log struct {
+		Version string      `json:"version,omitempty"`
+		Creator *harCreator `json:"creator,omitempty"`
+		Entries []harEntry  `json:"entries"`
+	} `json:"log"`
+}
+
+var (
+	// Headers which are managed by HTTP client
+	harSkipHeaders = map[string]bool{
+		"host":              true,
+		"content-length":    true,
+		"connection":        true,
+		"transfer-encoding": true,
+	}
+)
+
+func harMatchHost(link *url.URL, hosts []string) bool {
+	if len(hosts) == 0 {
+		return true
+	}
+
+	for _, host := range hosts {
+		if strings.EqualFold(host, link.Host) || strings.EqualFold(host, link.Hostname()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Load HAR file as benchmark items, item Offset is the start time
+// of the entry relative to the first entry
+// @param path: HAR file path
+// @param opts: import options
+func LoadHAR(path string, opts HAROptions) ([]*BenchmarkItem, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var har harLog
+
+	if err := json.Unmarshal(data, &har); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err.Error())
+	}
+
+	var (
+		items   []*BenchmarkItem
+		indexes = make(map[string]*BenchmarkItem)
+		start   time.Time
+	)
+
+	for _, entry := range har.Log.Entries {
+		if start.IsZero() || entry.StartedDateTime.Before(start) {
+			start = entry.StartedDateTime
+		}
+	}
+
+	for i, entry := range har.Log.Entries {
+		link, err := url.Parse(entry.Request.URL)
+		if err != nil || !HasScheme(entry.Request.URL) {
+			return nil, fmt.Errorf("%s: entry #%d has invalid URL: %s", path, i+1, entry.Request.URL)
+		}
+
+		if !harMatchHost(link, opts.Hosts) {
+			continue
+		}
+
+		if ParseMethod(entry.Request.Method) == MethodNone {
+			return nil, fmt.Errorf("%s: entry #%d has unsupported method: %s", path, i+1, entry.Request.Method)
+		}
+
+		headers := make(map[string]string)
+
+		for _, header := range entry.Request.Headers {
+			name := strings.ToLower(header.Name)
+
+			// Skip HTTP/2 pseudo headers (etc: ":authority")
+			if strings.HasPrefix(name, ":") || harSkipHeaders[name] {
+				continue
+			}
+
+			// Names of HTTP/2 entries are lower case, repeated headers
+			// are merged whatever case they are recorded in
+			field := http.CanonicalHeaderKey(header.Name)
+
+			separator := ", "
+			if name == "cookie" {
+				if opts.StripCookies {
+					continue
+				}
+				separator = "; "
+			}
+
+			if value, exists := headers[field]; exists {
+				headers[field] = value + separator + header.Value
+			} else {
+				headers[field] = header.Value
+			}
+		}
+
+		var body []byte
+
+		if entry.Request.PostData != nil && len(entry.Request.PostData.Text) > 0 {
+			body = []byte(entry.Request.PostData.Text)
+
+			if _, exists := headers["Content-Type"]; !exists && len(entry.Request.PostData.MimeType) > 0 {
+				headers["Content-Type"] = entry.Request.PostData.MimeType
+			}
+		}
+
+		method := strings.ToUpper(entry.Request.Method)
+		name := method + " " + link.Path
+		if len(link.Path) == 0 {
+			name = method + " /"
+		}
+
+		key := method + " " + entry.Request.URL + " " + string(body)
+
+		if item, exists := indexes[key]; exists && opts.Mix {
+			item.Weight++
+			continue
+		}
+
+		item := &BenchmarkItem{
+			Name:    name,
+			Weight:  1,
+			URL:     entry.Request.URL,
+			Headers: headers,
+			Params:  make(map[string]string),
+			Method:  method,
+			Body:    body,
+			Raw:     true,
+		}
+
+		if entry.StartedDateTime.After(start) {
+			item.Offset = entry.StartedDateTime.Sub(start)
+		}
+
+		if opts.Validate && entry.Response != nil && entry.Response.Status > 0 {
+			item.ExpectStatus = entry.Response.Status
+
+			if content := entry.Response.Content; len(content.Text) > 0 {
+				if content.Encoding == "base64" {
+					if item.ExpectBody, err = base64.StdEncoding.DecodeString(content.Text); err != nil {
+						return nil, fmt.Errorf("%s: entry #%d has invalid content: %s", path, i+1, err.Error())
+					}
+				} else {
+					item.ExpectBody = []byte(content.Text)
+				}
+			}
+		}
+
+		indexes[key] = item
+		items = append(items, item)
+	}
+
+	if len(items) == 0 {
+		return nil, fmt.Errorf("%s: no entry found", path)
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Offset < items[j].Offset
+	})
+
+	return items, nil
+}
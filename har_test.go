@@ -0,0 +1,155 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testHAR = `{
+  "log": {
+    "version": "1.2",
+    "entries": [
+      {
+        "startedDateTime": "2020-01-01T00:00:01.500Z",
+        "request": {
+          "method": "post",
+          "url": "https://api.example.com/orders?id=1",
+          "headers": [
+            {"name": ":authority", "value": "api.example.com"},
+            {"name": "content-length", "value": "9"},
+            {"name": "accept", "value": "text/html"},
+            {"name": "Accept", "value": "application/json"},
+            {"name": "cookie", "value": "a=1"},
+            {"name": "Cookie", "value": "b=2"}
+          ],
+          "postData": {"mimeType": "application/json", "text": "{\"id\": 1}"}
+        },
+        "response": {"status": 201, "content": {"text": "b2s=", "encoding": "base64"}}
+      },
+      {
+        "startedDateTime": "2020-01-01T00:00:00Z",
+        "request": {
+          "method": "GET",
+          "url": "https://api.example.com/items",
+          "headers": [{"name": "accept", "value": "application/json"}]
+        },
+        "response": {"status": 200, "content": {"text": "[]"}}
+      },
+      {
+        "startedDateTime": "2020-01-01T00:00:01Z",
+        "request": {"method": "GET", "url": "https://cdn.example.com/app.js", "headers": []}
+      },
+      {
+        "startedDateTime": "2020-01-01T00:00:02Z",
+        "request": {
+          "method": "GET",
+          "url": "https://api.example.com/items",
+          "headers": [{"name": "Accept", "value": "application/json"}]
+        }
+      }
+    ]
+  }
+}`
+
+func writeTestHAR(t *testing.T) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "page.har")
+	if err := os.WriteFile(path, []byte(testHAR), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoadHARSequence(t *testing.T) {
+	items, err := LoadHAR(writeTestHAR(t), HAROptions{Hosts: []string{"API.example.com"}, Validate: true})
+	if err != nil {
+		t.Fatalf("LoadHAR() failed: %v", err)
+	}
+
+	// Entries are sorted by start time, other hosts are skipped
+	want := []struct {
+		name   string
+		offset time.Duration
+	}{
+		{"GET /items", 0},
+		{"POST /orders", 1500 * time.Millisecond},
+		{"GET /items", 2 * time.Second},
+	}
+
+	if len(items) != len(want) {
+		t.Fatalf("items = %d, want %d", len(items), len(want))
+	}
+
+	for i, item := range items {
+		if item.Name != want[i].name || item.Offset != want[i].offset || !item.Raw {
+			t.Errorf("items[%d] = %s at %v raw %v, want %s at %v", i, item.Name, item.Offset, item.Raw, want[i].name, want[i].offset)
+		}
+	}
+
+	order := items[1]
+
+	// Header names are merged case insensitively
+	headers := map[string]string{
+		"Accept":       "text/html, application/json",
+		"Cookie":       "a=1; b=2",
+		"Content-Type": "application/json",
+	}
+
+	if len(order.Headers) != len(headers) {
+		t.Errorf("headers = %v, want %v", order.Headers, headers)
+	}
+
+	for field, value := range headers {
+		if order.Headers[field] != value {
+			t.Errorf("header %s = %q, want %q", field, order.Headers[field], value)
+		}
+	}
+
+	if string(order.Body) != `{"id": 1}` || order.ExpectStatus != 201 || string(order.ExpectBody) != "ok" {
+		t.Errorf("body = %q, expect %d %q", order.Body, order.ExpectStatus, order.ExpectBody)
+	}
+
+	if items[0].ExpectStatus != 200 || string(items[0].ExpectBody) != "[]" || items[2].ExpectStatus != 0 {
+		t.Errorf("expect status = %d, %d", items[0].ExpectStatus, items[2].ExpectStatus)
+	}
+}
+
+func TestLoadHARMix(t *testing.T) {
+	items, err := LoadHAR(writeTestHAR(t), HAROptions{Mix: true, StripCookies: true})
+	if err != nil {
+		t.Fatalf("LoadHAR() failed: %v", err)
+	}
+
+	// Same requests are merged as weight
+	weights := map[string]int{"GET /items": 2, "POST /orders": 1, "GET /app.js": 1}
+
+	if len(items) != len(weights) {
+		t.Fatalf("items = %d, want %d", len(items), len(weights))
+	}
+
+	for _, item := range items {
+		if item.Weight != weights[item.Name] {
+			t.Errorf("weight of %s = %d, want %d", item.Name, item.Weight, weights[item.Name])
+		}
+
+		if _, exists := item.Headers["Cookie"]; exists {
+			t.Errorf("cookie of %s is not stripped", item.Name)
+		}
+
+		if item.ExpectStatus != 0 {
+			t.Errorf("responses of %s are validated", item.Name)
+		}
+	}
+
+	if _, err := LoadHAR(writeTestHAR(t), HAROptions{Hosts: []string{"www.example.com"}}); err == nil {
+		t.Error("LoadHAR() without matched entry should fail")
+	}
+}
@@ -2,7 +2,7 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-package main
+package gobenchmark
 
 import (
 	"fmt"
@@ -27,16 +27,17 @@ var (
 	logEnable bool
 )
 
-func InitDefaultLog(path string, displayLevel int) {
+func InitDefaultLog(path string, displayLevel int) error {
 	var err error
 
 	log, err = NewLog(path, displayLevel)
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(-1)
+		return err
 	}
 
 	logEnable = true
+
+	return nil
 }
 
 func NewLog(path string, displayLevel int) (*Log, error) {
@@ -52,8 +53,9 @@ func NewLog(path string, displayLevel int) (*Log, error) {
 	}, nil
 }
 
+// Write message of level, nothing is written by nil log
 func (l *Log) logFormat(level int, format string, args ...interface{}) bool {
-	if level < l.DisplayLevel {
+	if l == nil || level < l.DisplayLevel {
 		return true
 	}
 
@@ -92,6 +94,14 @@ func (l *Log) Errorf(format string, args ...interface{}) {
 	l.logFormat(ErrorLevel, format, args...)
 }
 
+// Close log file, it is safe to close nil log
+func (l *Log) Close() error {
+	if l == nil {
+		return nil
+	}
+	return l.File.Close()
+}
+
 func Debugf(format string, args ...interface{}) {
 	if logEnable {
 		log.logFormat(DebugLevel, format, args...)
@@ -0,0 +1,51 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLogLevel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "error.log")
+
+	logger, err := NewLog(path, InfoLevel)
+	if err != nil {
+		t.Fatalf("NewLog() failed: %v", err)
+	}
+	defer logger.File.Close()
+
+	logger.Debugf("debug %d", 1)
+	logger.Infof("info %d", 2)
+	logger.Errorf("error %s", "3")
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := string(data)
+
+	if strings.Contains(content, "debug 1") {
+		t.Error("debug message should be filtered")
+	}
+
+	if !strings.Contains(content, "<INFO> info 2") || !strings.Contains(content, "<ERROR> error 3") {
+		t.Errorf("unexpected log content: %q", content)
+	}
+
+	if lines := strings.Count(content, "\n"); lines != 2 {
+		t.Errorf("log has %d lines, want 2", lines)
+	}
+}
+
+func TestNewLogInvalidPath(t *testing.T) {
+	if _, err := NewLog(filepath.Join(t.TempDir(), "missing", "error.log"), DebugLevel); err == nil {
+		t.Error("NewLog() should fail when directory not exists")
+	}
+}
@@ -0,0 +1,502 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	memcacheDefaultMultiGet = 10
+	memcacheDefaultPort     = "11211"
+	memcacheMaxKeyLength    = 250
+
+	// Magic bytes and opcodes of binary protocol
+	memcacheRequestMagic  = 0x80
+	memcacheResponseMagic = 0x81
+	memcacheOpGet         = 0x00
+	memcacheOpSet         = 0x01
+	memcacheOpDelete      = 0x04
+	memcacheOpNoop        = 0x0a
+	memcacheOpGetKQ       = 0x0d
+	memcacheHeaderSize    = 24
+)
+
+// Options of Memcached command mix, requests are generated for target
+// memcache://host:port, keys and values have the same defaults as
+// Redis mix to make results of both tiers comparable
+type MemcacheOptions struct {
+	// Weighted commands of get, set, delete and mget (multi-get),
+	// body is sent as a command line if empty (etc: get key:1 key:2)
+	Commands string
+	// Keys are chosen randomly in [1, keys], default is 100000
+	Keys int64
+	// Distribution of keys: uniform (default) or zipfian
+	Distribution string
+	// Size of values of set, default is 3, size is random in
+	// [ValueSize, MaxValueSize] if max is greater
+	ValueSize    int
+	MaxValueSize int
+	// Keys of each mget, default is 10
+	MultiGet int
+	// Use binary protocol instead of text protocol
+	Binary bool
+}
+
+// Command lines of mix, {key} and {value} are replaced by random key and value
+var memcacheCommands = map[string]string{
+	"get":    "get key:{key}",
+	"set":    "set key:{key} {value}",
+	"delete": "delete key:{key}",
+}
+
+// Status of binary responses, names are the same as text replies
+var memcacheStatus = map[uint16]string{
+	0x01: "NOT_FOUND",
+	0x02: "EXISTS",
+	0x03: "TOO_LARGE",
+	0x04: "INVALID_ARGUMENTS",
+	0x05: "NOT_STORED",
+	0x81: "UNKNOWN_COMMAND",
+	0x82: "OUT_OF_MEMORY",
+}
+
+// Build benchmark items of command mix, each command is an item
+// named by command so latency is reported per command
+// @param target: URL of Memcached server
+// @param options: command mix
+func BuildMemcacheItems(target string, options MemcacheOptions) ([]*BenchmarkItem, error) {
+	if len(target) == 0 {
+		return nil, errors.New("testing target URL has not set")
+	}
+
+	keys := options.Keys
+	if keys <= 0 {
+		keys = defaultKeys
+	}
+
+	size := options.ValueSize
+	if size <= 0 {
+		size = defaultValueSize
+	}
+
+	multiGet := options.MultiGet
+	if multiGet <= 0 {
+		multiGet = memcacheDefaultMultiGet
+	}
+
+	key, err := keyTemplate(keys, options.Distribution)
+	if err != nil {
+		return nil, err
+	}
+
+	replacer := strings.NewReplacer("{key}", key, "{value}", valueTemplate(size, options.MaxValueSize))
+
+	commands, err := parseWeightedList(options.Commands)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []*BenchmarkItem
+
+	for _, command := range commands {
+		name := strings.ToLower(command.name)
+
+		line, exists := memcacheCommands[name]
+		if name == "mget" {
+			line = "get" + strings.Repeat(" key:{key}", multiGet)
+		} else if !exists {
+			return nil, fmt.Errorf("unsupported Memcached command: %s, supported: delete,get,mget,set", name)
+		}
+
+		items = append(items, &BenchmarkItem{
+			Name:   name,
+			Weight: command.weight,
+			URL:    target,
+			Body:   []byte(replacer.Replace(line)),
+		})
+	}
+
+	if len(items) == 0 {
+		return nil, errors.New("no Memcached command found")
+	}
+
+	return items, nil
+}
+
+type memcacheCall struct {
+	conn    *socketConn
+	name    string
+	keys    []string
+	value   []byte
+	connect time.Duration
+}
+
+type memcacheExecutor struct {
+	binary bool
+	pool   *connPool
+	// Unix socket dialed instead of address of URL
+	socket string
+}
+
+func init() {
+	RegisterExecutor("memcache", NewMemcacheExecutor)
+	RegisterExecutor("memcached", NewMemcacheExecutor)
+}
+
+// Create executor of Memcached protocol, body of request is a command
+// line: get key..., set key value or delete key, at most connections
+// sockets are opened
+func NewMemcacheExecutor(config *Config) (Executor, error) {
+	if config.Connections <= 0 {
+		return nil, fmt.Errorf("connections must be greater than 0")
+	}
+
+	executor := &memcacheExecutor{
+		binary: config.Memcache.Binary,
+		pool:   newConnPool(config.Connections),
+		socket: config.UnixSocket,
+	}
+
+	return executor, nil
+}
+
+// Parse command line of body, value of set is the rest of line
+func parseMemcacheCommand(line string) (*memcacheCall, error) {
+	line = strings.TrimSpace(line)
+
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil, errors.New("no Memcached command in body")
+	}
+
+	call := &memcacheCall{name: strings.ToLower(fields[0])}
+
+	switch call.name {
+	case "get", "gets":
+		call.name = "get"
+		call.keys = fields[1:]
+	case "delete":
+		call.keys = fields[1:]
+	case "set":
+		if len(fields) < 2 {
+			break
+		}
+
+		call.keys = fields[1:2]
+
+		// Value is text after "set key "
+		rest := strings.TrimLeft(line[len(fields[0]):], " \t")[len(fields[1]):]
+		if len(rest) > 0 {
+			call.value = []byte(rest[1:])
+		}
+	default:
+		return nil, fmt.Errorf("unsupported Memcached command: %s", fields[0])
+	}
+
+	if len(call.keys) == 0 || (call.name == "delete" && len(call.keys) > 1) {
+		return nil, fmt.Errorf("invalid Memcached command: %s", line)
+	}
+
+	for _, key := range call.keys {
+		if len(key) > memcacheMaxKeyLength {
+			return nil, fmt.Errorf("key is longer than %d bytes: %s", memcacheMaxKeyLength, key)
+		}
+	}
+
+	return call, nil
+}
+
+// Take idle connection or open new one
+func (e *memcacheExecutor) Prepare(req *Request) (interface{}, error) {
+	info, err := url.Parse(req.GetURL())
+	if err != nil {
+		return nil, err
+	}
+
+	call, err := parseMemcacheCommand(string(req.GetBody()))
+	if err != nil {
+		return nil, err
+	}
+
+	addr := info.Host
+	if len(info.Port()) == 0 {
+		addr = net.JoinHostPort(info.Hostname(), memcacheDefaultPort)
+	}
+
+	conn, connect, err := e.pool.get(addr, func() (net.Conn, error) {
+		if len(e.socket) > 0 {
+			return net.DialTimeout("unix", e.socket, req.GetTimeout())
+		}
+		return net.DialTimeout("tcp", addr, req.GetTimeout())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	call.conn = conn
+	call.connect = connect
+
+	return call, nil
+}
+
+func (e *memcacheExecutor) Execute(ctx context.Context, prepared interface{}) *Response {
+	call := prepared.(*memcacheCall)
+	c := call.conn
+
+	rsp := &Response{Connect: call.connect}
+
+	// Data of response may be left in reader after error, text
+	// protocol can't recover from error replies either
+	reuse := false
+
+	defer func() {
+		e.pool.put(c, reuse && rsp.Err == nil)
+	}()
+
+	deadline, _ := ctx.Deadline()
+	_ = c.conn.SetDeadline(deadline)
+
+	var request []byte
+
+	if e.binary {
+		request = call.appendBinary(nil)
+	} else {
+		request = call.appendText(nil)
+	}
+
+	start := time.Now()
+
+	if _, err := c.conn.Write(request); err != nil {
+		rsp.Err = err
+		rsp.Disconnected = isDisconnected(err)
+		return rsp
+	}
+
+	if e.binary {
+		reuse = e.readBinary(c, call, rsp)
+	} else {
+		reuse = e.readText(c, call, rsp)
+	}
+
+	rsp.Elapsed = time.Since(start)
+
+	if rsp.Err != nil {
+		rsp.Disconnected = isDisconnected(rsp.Err)
+	}
+
+	return rsp
+}
+
+// Encode command in text protocol
+func (call *memcacheCall) appendText(buf []byte) []byte {
+	buf = append(buf, call.name...)
+
+	for _, key := range call.keys {
+		buf = append(buf, ' ')
+		buf = append(buf, key...)
+	}
+
+	if call.name == "set" {
+		buf = append(buf, " 0 0 "...)
+		buf = strconv.AppendInt(buf, int64(len(call.value)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, call.value...)
+	}
+
+	return append(buf, '\r', '\n')
+}
+
+// Encode command in binary protocol, keys of multi-get are sent in
+// quiet gets which only reply hits, then a noop ends the replies
+func (call *memcacheCall) appendBinary(buf []byte) []byte {
+	switch {
+	case call.name == "set":
+		// Extras are flags and expiration
+		return appendMemcachePacket(buf, memcacheOpSet, make([]byte, 8), call.keys[0], call.value)
+	case call.name == "delete":
+		return appendMemcachePacket(buf, memcacheOpDelete, nil, call.keys[0], nil)
+	case len(call.keys) == 1:
+		return appendMemcachePacket(buf, memcacheOpGet, nil, call.keys[0], nil)
+	}
+
+	for _, key := range call.keys {
+		buf = appendMemcachePacket(buf, memcacheOpGetKQ, nil, key, nil)
+	}
+
+	return appendMemcachePacket(buf, memcacheOpNoop, nil, "", nil)
+}
+
+func appendMemcachePacket(buf []byte, opcode byte, extras []byte, key string, value []byte) []byte {
+	header := make([]byte, memcacheHeaderSize)
+	header[0] = memcacheRequestMagic
+	header[1] = opcode
+	binary.BigEndian.PutUint16(header[2:], uint16(len(key)))
+	header[4] = byte(len(extras))
+	binary.BigEndian.PutUint32(header[8:], uint32(len(extras)+len(key)+len(value)))
+
+	buf = append(buf, header...)
+	buf = append(buf, extras...)
+	buf = append(buf, key...)
+	return append(buf, value...)
+}
+
+// Read reply of text protocol, whether connection can be reused is returned
+func (e *memcacheExecutor) readText(c *socketConn, call *memcacheCall, rsp *Response) bool {
+	var values [][]byte
+
+	for {
+		line, err := c.reader.ReadSlice('\n')
+		rsp.Bytes += int64(len(line))
+		if err != nil {
+			rsp.Err = err
+			return false
+		}
+
+		reply := string(bytes.TrimRight(line, "\r\n"))
+
+		// ERROR, CLIENT_ERROR message or SERVER_ERROR message
+		if strings.HasSuffix(strings.SplitN(reply, " ", 2)[0], "ERROR") {
+			rsp.Status = strings.SplitN(reply, " ", 2)[0]
+			rsp.Body = []byte(reply)
+			return false
+		}
+
+		if call.name != "get" {
+			rsp.Status = reply
+			rsp.OK = true
+			return true
+		}
+
+		if reply == "END" {
+			break
+		}
+
+		// VALUE key flags bytes [cas]
+		fields := strings.Fields(reply)
+		if len(fields) < 4 || fields[0] != "VALUE" {
+			rsp.Err = fmt.Errorf("invalid Memcached reply: %q", reply)
+			return false
+		}
+
+		size, err := strconv.Atoi(fields[3])
+		if err != nil || size < 0 {
+			rsp.Err = fmt.Errorf("invalid Memcached reply: %q", reply)
+			return false
+		}
+
+		value := make([]byte, size+2)
+		if _, err := io.ReadFull(c.reader, value); err != nil {
+			rsp.Err = err
+			return false
+		}
+
+		rsp.Bytes += int64(len(value))
+		values = append(values, value[:size])
+	}
+
+	setMemcacheValues(rsp, call, values)
+
+	return true
+}
+
+// Read replies of binary protocol, whether connection can be reused is returned
+func (e *memcacheExecutor) readBinary(c *socketConn, call *memcacheCall, rsp *Response) bool {
+	var values [][]byte
+
+	header := make([]byte, memcacheHeaderSize)
+
+	for {
+		if _, err := io.ReadFull(c.reader, header); err != nil {
+			rsp.Err = err
+			return false
+		}
+
+		if header[0] != memcacheResponseMagic {
+			rsp.Err = fmt.Errorf("invalid magic of Memcached response: %#x", header[0])
+			return false
+		}
+
+		body := make([]byte, binary.BigEndian.Uint32(header[8:]))
+		if _, err := io.ReadFull(c.reader, body); err != nil {
+			rsp.Err = err
+			return false
+		}
+
+		rsp.Bytes += int64(len(header) + len(body))
+
+		opcode := header[1]
+		status := binary.BigEndian.Uint16(header[6:])
+		offset := int(header[4]) + int(binary.BigEndian.Uint16(header[2:]))
+
+		if offset > len(body) {
+			rsp.Err = errors.New("invalid length of Memcached response")
+			return false
+		}
+
+		// Quiet gets are ended by noop
+		if opcode == memcacheOpGetKQ || (opcode == memcacheOpGet && status == 0) {
+			values = append(values, body[offset:])
+		}
+
+		if opcode == memcacheOpGetKQ {
+			continue
+		}
+
+		if call.name == "get" && (status == 0 || status == 1) {
+			break
+		}
+
+		switch {
+		case status == 0 && call.name == "set":
+			rsp.Status = "STORED"
+		case status == 0:
+			rsp.Status = "DELETED"
+		case memcacheStatus[status] != "":
+			rsp.Status = memcacheStatus[status]
+		default:
+			rsp.Status = fmt.Sprintf("STATUS_%#x", status)
+		}
+
+		// Not found or not stored is normal reply of command
+		rsp.OK = status == 0 || status == 0x01 || status == 0x05
+		if !rsp.OK {
+			rsp.Body = body[offset:]
+		}
+
+		return true
+	}
+
+	setMemcacheValues(rsp, call, values)
+
+	return true
+}
+
+// Status of get is HIT if all keys are found, values are joined by lines
+func setMemcacheValues(rsp *Response, call *memcacheCall, values [][]byte) {
+	rsp.Body = bytes.Join(values, []byte("\n"))
+	rsp.OK = true
+
+	if len(values) == len(call.keys) {
+		rsp.Status = "HIT"
+	} else {
+		rsp.Status = "MISS"
+	}
+}
+
+func (e *memcacheExecutor) Close() error {
+	e.pool.close()
+	return nil
+}
@@ -0,0 +1,327 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Memcached compatible server for test, it supports get, set
+// and delete of text protocol and binary protocol
+type testMemcacheServer struct {
+	lock     sync.Mutex
+	data     map[string][]byte
+	commands map[string]int
+}
+
+func (s *testMemcacheServer) command(name string) {
+	s.lock.Lock()
+	s.commands[name]++
+	s.lock.Unlock()
+}
+
+func (s *testMemcacheServer) serveText(reader *bufio.Reader, conn net.Conn) error {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return err
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		_, err = io.WriteString(conn, "ERROR\r\n")
+		return err
+	}
+
+	s.command(fields[0])
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var reply string
+
+	switch fields[0] {
+	case "get":
+		for _, key := range fields[1:] {
+			if value, exists := s.data[key]; exists {
+				reply += fmt.Sprintf("VALUE %s 0 %d\r\n%s\r\n", key, len(value), value)
+			}
+		}
+		reply += "END\r\n"
+	case "set":
+		size, _ := strconv.Atoi(fields[4])
+		value := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, value); err != nil {
+			return err
+		}
+		s.data[fields[1]] = value[:size]
+		reply = "STORED\r\n"
+	case "delete":
+		if _, exists := s.data[fields[1]]; exists {
+			delete(s.data, fields[1])
+			reply = "DELETED\r\n"
+		} else {
+			reply = "NOT_FOUND\r\n"
+		}
+	default:
+		reply = "ERROR\r\n"
+	}
+
+	_, err = io.WriteString(conn, reply)
+	return err
+}
+
+func (s *testMemcacheServer) serveBinary(reader *bufio.Reader, conn net.Conn) error {
+	header := make([]byte, memcacheHeaderSize)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return err
+	}
+
+	body := make([]byte, binary.BigEndian.Uint32(header[8:]))
+	if _, err := io.ReadFull(reader, body); err != nil {
+		return err
+	}
+
+	opcode, extras := header[1], int(header[4])
+	key := string(body[extras : extras+int(binary.BigEndian.Uint16(header[2:]))])
+
+	s.command(fmt.Sprintf("%#x", opcode))
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	reply := func(status uint16, extras []byte, key string, value []byte) error {
+		packet := appendMemcachePacket(nil, opcode, extras, key, value)
+		packet[0] = memcacheResponseMagic
+		binary.BigEndian.PutUint16(packet[6:], status)
+		_, err := conn.Write(packet)
+		return err
+	}
+
+	value, exists := s.data[key]
+
+	switch opcode {
+	case memcacheOpGet, memcacheOpGetKQ:
+		if !exists && opcode == memcacheOpGetKQ {
+			return nil
+		}
+		if !exists {
+			return reply(1, nil, "", []byte("Not found"))
+		}
+		if opcode == memcacheOpGet {
+			key = ""
+		}
+		return reply(0, make([]byte, 4), key, value)
+	case memcacheOpSet:
+		s.data[key] = body[extras+len(key):]
+		return reply(0, nil, "", nil)
+	case memcacheOpDelete:
+		if !exists {
+			return reply(1, nil, "", []byte("Not found"))
+		}
+		delete(s.data, key)
+		return reply(0, nil, "", nil)
+	case memcacheOpNoop:
+		return reply(0, nil, "", nil)
+	}
+
+	return reply(0x81, nil, "", []byte("Unknown command"))
+}
+
+func newTestMemcacheServer(t *testing.T) (*testMemcacheServer, string) {
+	t.Helper()
+
+	server := &testMemcacheServer{
+		data:     make(map[string][]byte),
+		commands: make(map[string]int),
+	}
+
+	addr, _ := newTestTCPServer(t, func(conn net.Conn) {
+		reader := bufio.NewReader(conn)
+
+		for {
+			magic, err := reader.Peek(1)
+			if err != nil {
+				return
+			}
+
+			if magic[0] == memcacheRequestMagic {
+				err = server.serveBinary(reader, conn)
+			} else {
+				err = server.serveText(reader, conn)
+			}
+
+			if err != nil {
+				return
+			}
+		}
+	})
+
+	return server, addr
+}
+
+func TestMemcacheCommandMix(t *testing.T) {
+	for _, protocol := range []bool{false, true} {
+		server, addr := newTestMemcacheServer(t)
+
+		config := NewConfig()
+		config.Connections = 4
+		config.Requests = 200
+		config.Timeout = 5 * time.Second
+		config.Target = "memcache://" + addr
+		config.Memcache = MemcacheOptions{
+			Commands:     "get:5,set:3,delete,mget",
+			Keys:         20,
+			Distribution: KeyZipfian,
+			ValueSize:    10,
+			MaxValueSize: 20,
+			MultiGet:     3,
+			Binary:       protocol,
+		}
+
+		snapshot := runBenchmark(t, config).Stats.Snapshot()
+
+		if snapshot.Total != 200 || snapshot.Success != 200 {
+			t.Errorf("binary %v: total = %d, success = %d, status = %v", protocol, snapshot.Total, snapshot.Success, snapshot.Status)
+		}
+
+		if snapshot.Status["STORED"] == 0 || snapshot.Status["HIT"]+snapshot.Status["MISS"] == 0 {
+			t.Errorf("binary %v: status = %v", protocol, snapshot.Status)
+		}
+
+		if snapshot.Connects == 0 || snapshot.Connects > 4 || snapshot.RecvBytes == 0 {
+			t.Errorf("binary %v: connects = %d, bytes = %d", protocol, snapshot.Connects, snapshot.RecvBytes)
+		}
+
+		server.lock.Lock()
+
+		for key, value := range server.data {
+			id, _ := strconv.Atoi(strings.TrimPrefix(key, "key:"))
+			if id < 1 || id > 20 || len(value) < 10 || len(value) > 20 {
+				t.Errorf("binary %v: %s = %q out of key space or value size", protocol, key, value)
+			}
+		}
+
+		// Multi-get of binary protocol are quiet gets and noop
+		if protocol && (server.commands["0xd"] == 0 || int64(server.commands["0xa"]) != snapshot.Groups["mget"].Total) {
+			t.Errorf("binary commands = %v", server.commands)
+		}
+
+		server.lock.Unlock()
+	}
+}
+
+func TestMemcacheBody(t *testing.T) {
+	_, addr := newTestMemcacheServer(t)
+
+	tests := []struct {
+		body   string
+		status string
+		value  string
+	}{
+		{"get a", "MISS", ""},
+		{"set a hello world", "STORED", ""},
+		{"set b ", "STORED", ""},
+		{"get a", "HIT", "hello world"},
+		{"get a b c", "MISS", "hello world\n"},
+		{"gets a b", "HIT", "hello world\n"},
+		{"delete b", "DELETED", ""},
+		{"delete b", "NOT_FOUND", ""},
+		{"delete a", "DELETED", ""},
+	}
+
+	for _, protocol := range []bool{false, true} {
+		config := NewConfig()
+		config.Connections = 1
+		config.Memcache.Binary = protocol
+
+		executor, err := NewMemcacheExecutor(config)
+		if err != nil {
+			t.Fatalf("NewMemcacheExecutor() failed: %v", err)
+		}
+
+		for _, test := range tests {
+			req := NewRequest(URLOption("memcached://"+addr), BodyOption([]byte(test.body)))
+
+			prepared, err := executor.Prepare(req)
+			if err != nil {
+				t.Fatalf("Prepare(%q) failed: %v", test.body, err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			rsp := executor.Execute(ctx, prepared)
+			cancel()
+
+			if rsp.Err != nil || !rsp.OK || rsp.Status != test.status || string(rsp.Body) != test.value {
+				t.Errorf("binary %v: Execute(%q) = %+v, body = %q", protocol, test.body, rsp, rsp.Body)
+			}
+		}
+
+		for _, body := range []string{"", "incr a", "get", "delete a b", "set " + strings.Repeat("k", 251) + " v"} {
+			req := NewRequest(URLOption("memcache://"+addr), BodyOption([]byte(body)))
+			if _, err := executor.Prepare(req); err == nil {
+				t.Errorf("Prepare(%q) should fail", body)
+			}
+		}
+
+		_ = executor.Close()
+	}
+}
+
+func TestMemcacheItems(t *testing.T) {
+	items, err := BuildMemcacheItems("memcache://127.0.0.1", MemcacheOptions{Commands: "GET:3,mget", MultiGet: 2})
+	if err != nil {
+		t.Fatalf("BuildMemcacheItems() failed: %v", err)
+	}
+
+	if len(items) != 2 || items[0].Name != "get" || items[0].Weight != 3 || items[1].Name != "mget" {
+		t.Fatalf("items = %+v", items)
+	}
+
+	if want := "get key:{{randInt 1 100000}} key:{{randInt 1 100000}}"; string(items[1].Body) != want {
+		t.Errorf("body = %q, want %q", items[1].Body, want)
+	}
+
+	for _, options := range []MemcacheOptions{
+		{Commands: "incr"},
+		{Commands: "get:-1"},
+		{Commands: ","},
+		{Commands: "get", Distribution: "normal"},
+	} {
+		if _, err := BuildMemcacheItems("memcache://127.0.0.1", options); err == nil {
+			t.Errorf("BuildMemcacheItems(%+v) should fail", options)
+		}
+	}
+
+	// Smaller numbers are more frequent in zipfian distribution
+	counts := make(map[int64]int)
+
+	for i := 0; i < 1000; i++ {
+		n := templateZipfInt(1, 100)
+		if n < 1 || n > 100 {
+			t.Fatalf("zipfInt(1, 100) = %d", n)
+		}
+		counts[n]++
+	}
+
+	if counts[1] < counts[50]*5 || counts[1] < 100 {
+		t.Errorf("counts of 1 and 50 = %d, %d", counts[1], counts[50])
+	}
+
+	for i := 0; i < 100; i++ {
+		if value := templateRandValue(2, 4); len(value) < 2 || len(value) > 4 {
+			t.Fatalf("randValue(2, 4) = %q", value)
+		}
+	}
+}
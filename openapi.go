@@ -0,0 +1,623 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type openAPISchema struct {
+	Ref        string                    `yaml:"$ref"`
+	Type       interface{}               `yaml:"type"`
+	Format     string                    `yaml:"format"`
+	Example    interface{}               `yaml:"example"`
+	Examples   []interface{}             `yaml:"examples"`
+	Default    interface{}               `yaml:"default"`
+	Enum       []interface{}             `yaml:"enum"`
+	Properties map[string]*openAPISchema `yaml:"properties"`
+	Items      *openAPISchema            `yaml:"items"`
+	AllOf      []*openAPISchema          `yaml:"allOf"`
+	OneOf      []*openAPISchema          `yaml:"oneOf"`
+	AnyOf      []*openAPISchema          `yaml:"anyOf"`
+	Minimum    *float64                  `yaml:"minimum"`
+	MinLength  int                       `yaml:"minLength"`
+	Required   []string                  `yaml:"required"`
+}
+
+type openAPIExample struct {
+	Value interface{} `yaml:"value"`
+}
+
+type openAPIParameter struct {
+	Ref      string                    `yaml:"$ref"`
+	Name     string                    `yaml:"name"`
+	In       string                    `yaml:"in"`
+	Required bool                      `yaml:"required"`
+	Schema   *openAPISchema            `yaml:"schema"`
+	Example  interface{}               `yaml:"example"`
+	Examples map[string]openAPIExample `yaml:"examples"`
+}
+
+type openAPIMediaType struct {
+	Schema   *openAPISchema            `yaml:"schema"`
+	Example  interface{}               `yaml:"example"`
+	Examples map[string]openAPIExample `yaml:"examples"`
+}
+
+type openAPIRequestBody struct {
+	Ref     string                      `yaml:"$ref"`
+	Content map[string]openAPIMediaType `yaml:"content"`
+}
+
+type openAPIOperation struct {
+	OperationID string              `yaml:"operationId"`
+	Tags        []string            `yaml:"tags"`
+	Parameters  []*openAPIParameter `yaml:"parameters"`
+	RequestBody *openAPIRequestBody `yaml:"requestBody"`
+	// Responses by status code, only codes are used
+	Responses map[string]interface{} `yaml:"responses"`
+}
+
+type openAPIPathItem struct {
+	Parameters []*openAPIParameter `yaml:"parameters"`
+	Get        *openAPIOperation   `yaml:"get"`
+	Put        *openAPIOperation   `yaml:"put"`
+	Post       *openAPIOperation   `yaml:"post"`
+	Delete     *openAPIOperation   `yaml:"delete"`
+	Options    *openAPIOperation   `yaml:"options"`
+	Head       *openAPIOperation   `yaml:"head"`
+	Patch      *openAPIOperation   `yaml:"patch"`
+}
+
+type openAPIServer struct {
+	URL       string `yaml:"url"`
+	Variables map[string]struct {
+		Default string `yaml:"default"`
+	} `yaml:"variables"`
+}
+
+type openAPIDocument struct {
+	OpenAPI    string                     `yaml:"openapi"`
+	Servers    []openAPIServer            `yaml:"servers"`
+	Paths      map[string]openAPIPathItem `yaml:"paths"`
+	Components struct {
+		Schemas       map[string]*openAPISchema      `yaml:"schemas"`
+		Parameters    map[string]*openAPIParameter   `yaml:"parameters"`
+		RequestBodies map[string]*openAPIRequestBody `yaml:"requestBodies"`
+	} `yaml:"components"`
+}
+
+const (
+	// Max depth of nested schemas, avoid infinite recursion of cyclic references
+	openAPIMaxDepth = 8
+	// Optional properties of deeper objects are omitted
+	openAPIOptionalDepth = 2
+)
+
+func openAPIRefName(ref, prefix string) (string, error) {
+	if !strings.HasPrefix(ref, prefix) {
+		return "", fmt.Errorf("unsupported reference: %s", ref)
+	}
+	return strings.TrimPrefix(ref, prefix), nil
+}
+
+func (doc *openAPIDocument) schema(schema *openAPISchema) (*openAPISchema, error) {
+	for i := 0; schema != nil && len(schema.Ref) > 0; i++ {
+		if i >= openAPIMaxDepth {
+			return nil, fmt.Errorf("too deep reference: %s", schema.Ref)
+		}
+		name, err := openAPIRefName(schema.Ref, "#/components/schemas/")
+		if err != nil {
+			return nil, err
+		}
+		if schema = doc.Components.Schemas[name]; schema == nil {
+			return nil, fmt.Errorf("schema not found: %s", name)
+		}
+	}
+	return schema, nil
+}
+
+func (doc *openAPIDocument) parameter(param *openAPIParameter) (*openAPIParameter, error) {
+	if len(param.Ref) == 0 {
+		return param, nil
+	}
+	name, err := openAPIRefName(param.Ref, "#/components/parameters/")
+	if err != nil {
+		return nil, err
+	}
+	if param = doc.Components.Parameters[name]; param == nil {
+		return nil, fmt.Errorf("parameter not found: %s", name)
+	}
+	return param, nil
+}
+
+func (doc *openAPIDocument) requestBody(body *openAPIRequestBody) (*openAPIRequestBody, error) {
+	if body == nil || len(body.Ref) == 0 {
+		return body, nil
+	}
+	name, err := openAPIRefName(body.Ref, "#/components/requestBodies/")
+	if err != nil {
+		return nil, err
+	}
+	if body = doc.Components.RequestBodies[name]; body == nil {
+		return nil, fmt.Errorf("request body not found: %s", name)
+	}
+	return body, nil
+}
+
+// Get type of schema, OpenAPI 3.1 allows list of types
+func openAPIType(schema *openAPISchema) string {
+	switch v := schema.Type.(type) {
+	case string:
+		return v
+	case []interface{}:
+		for _, item := range v {
+			if name, ok := item.(string); ok && name != "null" {
+				return name
+			}
+		}
+	}
+
+	if len(schema.Properties) > 0 {
+		return "object"
+	}
+	if schema.Items != nil {
+		return "array"
+	}
+
+	return ""
+}
+
+// Synthesize a valid value from schema, example and default
+// value take precedence over generated value
+func (doc *openAPIDocument) sample(schema *openAPISchema, depth int) (interface{}, error) {
+	schema, err := doc.schema(schema)
+	if err != nil || schema == nil || depth > openAPIMaxDepth {
+		return nil, err
+	}
+
+	switch {
+	case schema.Example != nil:
+		return schema.Example, nil
+	case len(schema.Examples) > 0:
+		return schema.Examples[0], nil
+	case schema.Default != nil:
+		return schema.Default, nil
+	case len(schema.Enum) > 0:
+		return schema.Enum[0], nil
+	case len(schema.AllOf) > 0:
+		object := make(map[string]interface{})
+		for _, sub := range schema.AllOf {
+			value, err := doc.sample(sub, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			if fields, ok := value.(map[string]interface{}); ok {
+				for field, value := range fields {
+					object[field] = value
+				}
+			} else if value != nil {
+				return value, nil
+			}
+		}
+		return object, nil
+	case len(schema.OneOf) > 0:
+		return doc.sample(schema.OneOf[0], depth+1)
+	case len(schema.AnyOf) > 0:
+		return doc.sample(schema.AnyOf[0], depth+1)
+	}
+
+	switch openAPIType(schema) {
+	case "object":
+		required := make(map[string]bool, len(schema.Required))
+		for _, field := range schema.Required {
+			required[field] = true
+		}
+
+		object := make(map[string]interface{}, len(schema.Properties))
+		for field, property := range schema.Properties {
+			if depth >= openAPIOptionalDepth && !required[field] {
+				continue
+			}
+			value, err := doc.sample(property, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			if value != nil {
+				object[field] = value
+			}
+		}
+		return object, nil
+
+	case "array":
+		value, err := doc.sample(schema.Items, depth+1)
+		if err != nil || value == nil {
+			return []interface{}{}, err
+		}
+		return []interface{}{value}, nil
+
+	case "integer":
+		if schema.Minimum != nil && *schema.Minimum > 1 {
+			return int64(*schema.Minimum), nil
+		}
+		return 1, nil
+
+	case "number":
+		if schema.Minimum != nil && *schema.Minimum > 1 {
+			return *schema.Minimum, nil
+		}
+		return 1.0, nil
+
+	case "boolean":
+		return true, nil
+
+	case "string":
+		var value string
+
+		switch schema.Format {
+		case "date-time":
+			value = time.Now().UTC().Format(time.RFC3339)
+		case "date":
+			value = time.Now().UTC().Format("2006-01-02")
+		case "uuid":
+			value = newUUID()
+		case "email":
+			value = "user@example.com"
+		case "uri", "url":
+			value = "http://example.com"
+		case "ipv4":
+			value = "127.0.0.1"
+		case "byte":
+			value = "c3RyaW5n"
+		default:
+			value = "string"
+		}
+
+		for len(value) < schema.MinLength {
+			value += "x"
+		}
+
+		return value, nil
+	}
+
+	return nil, nil
+}
+
+// Convert sample value to string of path, query or header parameter
+func openAPIString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []interface{}:
+		var items []string
+		for _, item := range v {
+			items = append(items, openAPIString(item))
+		}
+		return strings.Join(items, ",")
+	case map[string]interface{}:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+
+	return fmt.Sprint(value)
+}
+
+func (doc *openAPIDocument) paramValue(param *openAPIParameter) (interface{}, error) {
+	if param.Example != nil {
+		return param.Example, nil
+	}
+
+	names := make([]string, 0, len(param.Examples))
+	for name := range param.Examples {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if len(names) > 0 {
+		return param.Examples[names[0]].Value, nil
+	}
+
+	return doc.sample(param.Schema, 0)
+}
+
+func (doc *openAPIDocument) mediaValue(media openAPIMediaType) (interface{}, error) {
+	if media.Example != nil {
+		return media.Example, nil
+	}
+
+	names := make([]string, 0, len(media.Examples))
+	for name := range media.Examples {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if len(names) > 0 {
+		return media.Examples[names[0]].Value, nil
+	}
+
+	return doc.sample(media.Schema, 0)
+}
+
+// Build request body of operation, JSON is preferred
+func (doc *openAPIDocument) body(body *openAPIRequestBody) (string, []byte, error) {
+	body, err := doc.requestBody(body)
+	if err != nil || body == nil || len(body.Content) == 0 {
+		return "", nil, err
+	}
+
+	var types []string
+	for contentType := range body.Content {
+		types = append(types, contentType)
+	}
+	sort.Strings(types)
+
+	for _, contentType := range types {
+		media := body.Content[contentType]
+
+		switch {
+		case strings.Contains(contentType, "json"):
+			value, err := doc.mediaValue(media)
+			if err != nil {
+				return "", nil, err
+			}
+			data, err := json.Marshal(value)
+			return contentType, data, err
+
+		case contentType == "application/x-www-form-urlencoded":
+			value, err := doc.mediaValue(media)
+			if err != nil {
+				return "", nil, err
+			}
+			form := url.Values{}
+			if fields, ok := value.(map[string]interface{}); ok {
+				for field, value := range fields {
+					form.Set(field, openAPIString(value))
+				}
+			}
+			return contentType, []byte(form.Encode()), nil
+		}
+	}
+
+	contentType := types[0]
+
+	value, err := doc.mediaValue(body.Content[contentType])
+	if err != nil {
+		return "", nil, err
+	}
+
+	return contentType, []byte(openAPIString(value)), nil
+}
+
+func (doc *openAPIDocument) serverURL() string {
+	if len(doc.Servers) == 0 {
+		return ""
+	}
+
+	server := doc.Servers[0]
+	link := server.URL
+
+	for name, variable := range server.Variables {
+		link = strings.ReplaceAll(link, "{"+name+"}", variable.Default)
+	}
+
+	return link
+}
+
+// Check whether operation is selected by filters, filter can be
+// operation ID, tag, method or "METHOD /path"
+func openAPISelected(filters []string, method, path string, op *openAPIOperation) bool {
+	if len(filters) == 0 {
+		return true
+	}
+
+	for _, filter := range filters {
+		if filter == op.OperationID || strings.EqualFold(filter, method) ||
+			strings.EqualFold(filter, method+" "+path) {
+			return true
+		}
+		for _, tag := range op.Tags {
+			if filter == tag {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Load OpenAPI 3 document (YAML or JSON) as benchmark items,
+// each operation is an item with the same weight
+// @param path: OpenAPI document path
+// @param target: base URL, servers of document would be used if empty
+// @param filters: only operations which match these filters are loaded
+func LoadOpenAPI(path string, target string, filters []string) ([]*BenchmarkItem, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &openAPIDocument{}
+
+	if err := yaml.Unmarshal(data, doc); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, strings.TrimPrefix(err.Error(), "yaml: "))
+	}
+
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		return nil, fmt.Errorf("%s: only OpenAPI 3 is supported", path)
+	}
+
+	base := target
+	if len(base) == 0 {
+		base = doc.serverURL()
+	} else if server := doc.serverURL(); strings.HasPrefix(server, "/") {
+		// Relative server URL is the base path of target
+		base = strings.TrimRight(base, "/") + server
+	}
+
+	if !HasScheme(base) {
+		return nil, fmt.Errorf("%s: testing target URL has not set", path)
+	}
+
+	base = strings.TrimRight(base, "/")
+
+	var paths []string
+	for name := range doc.Paths {
+		paths = append(paths, name)
+	}
+	sort.Strings(paths)
+
+	var items []*BenchmarkItem
+
+	for _, name := range paths {
+		pathItem := doc.Paths[name]
+
+		operations := []struct {
+			method string
+			op     *openAPIOperation
+		}{
+			{"GET", pathItem.Get}, {"POST", pathItem.Post}, {"PUT", pathItem.Put},
+			{"PATCH", pathItem.Patch}, {"DELETE", pathItem.Delete},
+			{"HEAD", pathItem.Head}, {"OPTIONS", pathItem.Options},
+		}
+
+		for _, operation := range operations {
+			method, op := operation.method, operation.op
+			if op == nil || !openAPISelected(filters, method, name, op) {
+				continue
+			}
+
+			item, err := doc.operation(method, name, base, pathItem.Parameters, op)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s %s: %s", path, method, name, err.Error())
+			}
+
+			items = append(items, item)
+		}
+	}
+
+	if len(items) == 0 {
+		return nil, fmt.Errorf("%s: no operation found", path)
+	}
+
+	return items, nil
+}
+
+// Get the lowest declared 2xx status code, zero if not declared
+func openAPIStatus(responses map[string]interface{}) int {
+	status := 0
+
+	for code := range responses {
+		value, err := strconv.Atoi(code)
+		if err != nil || value < 200 || value > 299 {
+			continue
+		}
+		if status == 0 || value < status {
+			status = value
+		}
+	}
+
+	return status
+}
+
+func (doc *openAPIDocument) operation(method, path, base string, common []*openAPIParameter, op *openAPIOperation) (*BenchmarkItem, error) {
+	item := &BenchmarkItem{
+		Name:    op.OperationID,
+		Weight:  1,
+		Method:  method,
+		Headers: make(map[string]string),
+		Params:  make(map[string]string),
+		Raw:     true,
+	}
+
+	if len(item.Name) == 0 {
+		item.Name = method + " " + path
+	}
+
+	// Parameters of operation override the common ones of path
+	params := make(map[string]*openAPIParameter)
+
+	for _, param := range append(append([]*openAPIParameter{}, common...), op.Parameters...) {
+		param, err := doc.parameter(param)
+		if err != nil {
+			return nil, err
+		}
+		params[param.In+":"+param.Name] = param
+	}
+
+	var (
+		query   = url.Values{}
+		cookies []string
+	)
+
+	for _, param := range params {
+		if !param.Required && param.In != "path" {
+			continue
+		}
+
+		value, err := doc.paramValue(param)
+		if err != nil {
+			return nil, err
+		}
+
+		text := openAPIString(value)
+
+		switch param.In {
+		case "path":
+			path = strings.ReplaceAll(path, "{"+param.Name+"}", url.PathEscape(text))
+		case "query":
+			query.Set(param.Name, text)
+		case "header":
+			item.Headers[param.Name] = text
+		case "cookie":
+			cookies = append(cookies, param.Name+"="+text)
+		}
+	}
+
+	// Values are escaped, so braces left are undeclared parameters
+	if start := strings.Index(path, "{"); start >= 0 {
+		name := path[start:]
+		if end := strings.Index(name, "}"); end >= 0 {
+			name = name[:end+1]
+		}
+		return nil, fmt.Errorf("path parameter %s is not declared", name)
+	}
+
+	item.URL = base + path
+	if len(query) > 0 {
+		item.URL += "?" + query.Encode()
+	}
+
+	if len(cookies) > 0 {
+		sort.Strings(cookies)
+		item.Headers["Cookie"] = strings.Join(cookies, "; ")
+	}
+
+	contentType, body, err := doc.body(op.RequestBody)
+	if err != nil {
+		return nil, err
+	}
+
+	if body != nil {
+		item.Body = body
+		item.Headers["Content-Type"] = contentType
+	}
+
+	item.ExpectStatus = openAPIStatus(op.Responses)
+
+	return item, nil
+}
@@ -0,0 +1,298 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const testOpenAPI = `
+openapi: 3.0.3
+servers:
+  - url: "{scheme}://api.example.com/v1"
+    variables:
+      scheme:
+        default: https
+paths:
+  /users/{id}:
+    parameters:
+      - $ref: "#/components/parameters/UserID"
+    get:
+      operationId: getUser
+      tags: [users]
+      parameters:
+        - name: fields
+          in: query
+          required: true
+          schema:
+            type: array
+            items:
+              type: string
+              enum: [name, email]
+        - name: verbose
+          in: query
+          schema:
+            type: boolean
+        - name: X-Trace
+          in: header
+          required: true
+          examples:
+            b: {value: trace-b}
+            a: {value: trace-a}
+        - name: session
+          in: cookie
+          required: true
+          schema:
+            type: string
+            default: s1
+        - name: lang
+          in: cookie
+          required: true
+          example: en
+      responses:
+        "200": {description: user}
+        "404": {description: not found}
+    delete:
+      operationId: deleteUser
+      tags: [users, admin]
+      parameters:
+        - name: id
+          in: path
+          required: true
+          schema:
+            type: integer
+            minimum: 100
+      responses:
+        204: {description: deleted}
+        default: {description: error}
+  /users:
+    post:
+      operationId: createUser
+      tags: [users]
+      requestBody:
+        $ref: "#/components/requestBodies/User"
+      responses:
+        "400": {description: invalid}
+        "202": {description: queued}
+        "201": {description: created}
+        2XX: {description: other}
+  /orders:
+    put:
+      requestBody:
+        content:
+          application/x-www-form-urlencoded:
+            schema:
+              type: object
+              properties:
+                item: {type: integer, example: 3}
+                note: {type: string, minLength: 6}
+          text/plain:
+            example: plain
+components:
+  parameters:
+    UserID:
+      name: id
+      in: path
+      required: true
+      schema:
+        type: string
+        format: uuid
+        example: "a b"
+  requestBodies:
+    User:
+      content:
+        application/json:
+          schema:
+            $ref: "#/components/schemas/NewUser"
+  schemas:
+    Named:
+      type: object
+      required: [name]
+      properties:
+        name: {type: string, default: alice}
+    NewUser:
+      allOf:
+        - $ref: "#/components/schemas/Named"
+        - type: object
+          properties:
+            email: {type: string, format: email}
+            age: {type: [integer, "null"], minimum: 18}
+            score: {type: number}
+            role:
+              oneOf:
+                - {type: string, enum: [admin, guest]}
+                - {type: integer}
+            tags:
+              type: array
+              items: {type: string}
+            address:
+              $ref: "#/components/schemas/Address"
+    Address:
+      type: object
+      required: [city]
+      properties:
+        city: {type: string}
+        geo:
+          type: object
+          properties:
+            lat: {type: number}
+`
+
+func writeTestOpenAPI(t *testing.T, text string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "openapi.yaml")
+	if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func loadTestOpenAPI(t *testing.T, target string, filters ...string) map[string]*BenchmarkItem {
+	t.Helper()
+
+	items, err := LoadOpenAPI(writeTestOpenAPI(t, testOpenAPI), target, filters)
+	if err != nil {
+		t.Fatalf("LoadOpenAPI() failed: %v", err)
+	}
+
+	named := make(map[string]*BenchmarkItem)
+	for _, item := range items {
+		named[item.Name] = item
+	}
+
+	return named
+}
+
+func TestOpenAPIParameters(t *testing.T) {
+	items := loadTestOpenAPI(t, "")
+
+	get := items["getUser"]
+	if get == nil || get.Method != "GET" || !get.Raw {
+		t.Fatalf("items = %v", items)
+	}
+
+	// Optional query parameters are omitted, path parameters are escaped
+	if get.URL != "https://api.example.com/v1/users/a%20b?fields=name" {
+		t.Errorf("URL = %s", get.URL)
+	}
+
+	if get.Headers["X-Trace"] != "trace-a" || get.Headers["Cookie"] != "lang=en; session=s1" {
+		t.Errorf("headers = %v", get.Headers)
+	}
+
+	// Parameter of operation overrides the one of path
+	if del := items["deleteUser"]; del == nil || del.URL != "https://api.example.com/v1/users/100" {
+		t.Errorf("delete = %+v", del)
+	}
+
+	// Relative server URL is joined to target
+	items = loadTestOpenAPI(t, "http://127.0.0.1:8080/")
+
+	if put := items["PUT /orders"]; put == nil || put.URL != "http://127.0.0.1:8080/orders" {
+		t.Errorf("put = %+v", put)
+	}
+}
+
+func TestOpenAPIBody(t *testing.T) {
+	items := loadTestOpenAPI(t, "http://127.0.0.1")
+
+	create := items["createUser"]
+	if create == nil || create.Method != "POST" || create.Headers["Content-Type"] != "application/json" {
+		t.Fatalf("create = %+v", create)
+	}
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(create.Body, &body); err != nil {
+		t.Fatalf("body %s: %v", create.Body, err)
+	}
+
+	// References are resolved, optional fields of nested objects are omitted
+	want := map[string]interface{}{
+		"name":    "alice",
+		"email":   "user@example.com",
+		"age":     18.0,
+		"score":   1.0,
+		"role":    "admin",
+		"tags":    []interface{}{"string"},
+		"address": map[string]interface{}{"city": "string"},
+	}
+
+	if !reflect.DeepEqual(body, want) {
+		t.Errorf("body = %v, want %v", body, want)
+	}
+
+	// The lowest declared 2xx status is expected
+	if create.ExpectStatus != 201 {
+		t.Errorf("create expect status = %d, want 201", create.ExpectStatus)
+	}
+
+	for name, status := range map[string]int{"getUser": 200, "deleteUser": 204, "PUT /orders": 0} {
+		if item := items[name]; item.ExpectStatus != status {
+			t.Errorf("%s expect status = %d, want %d", name, item.ExpectStatus, status)
+		}
+	}
+
+	put := items["PUT /orders"]
+	if put == nil || put.Headers["Content-Type"] != "application/x-www-form-urlencoded" || string(put.Body) != "item=3&note=string" {
+		t.Errorf("put = %+v, body = %s", put, put.Body)
+	}
+}
+
+func TestOpenAPIFilters(t *testing.T) {
+	tests := []struct {
+		filters []string
+		names   []string
+	}{
+		{nil, []string{"PUT /orders", "createUser", "deleteUser", "getUser"}},
+		{[]string{"getUser"}, []string{"getUser"}},
+		{[]string{"admin", "put"}, []string{"PUT /orders", "deleteUser"}},
+		{[]string{"users"}, []string{"createUser", "deleteUser", "getUser"}},
+		{[]string{"get /users/{id}"}, []string{"getUser"}},
+	}
+
+	for _, test := range tests {
+		var names []string
+		for name := range loadTestOpenAPI(t, "", test.filters...) {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		if !reflect.DeepEqual(names, test.names) {
+			t.Errorf("filters %v = %v, want %v", test.filters, names, test.names)
+		}
+	}
+
+	if _, err := LoadOpenAPI(writeTestOpenAPI(t, testOpenAPI), "", []string{"unknown"}); err == nil {
+		t.Error("LoadOpenAPI() without matched operation should fail")
+	}
+}
+
+func TestOpenAPIErrors(t *testing.T) {
+	tests := map[string]string{
+		"swagger: '2.0'":            "only OpenAPI 3",
+		"openapi: 3.0.0\npaths: {}": "testing target URL has not set",
+		"openapi: 3.0.0\nservers: [{url: 'http://a'}]\npaths:\n  /a/{id}/{name}:\n    get:\n      parameters:\n" +
+			"        - {name: id, in: path, required: true, schema: {type: integer}}": "path parameter {name} is not declared",
+		"openapi: 3.0.0\nservers: [{url: 'http://a'}]\npaths:\n  /a:\n    get:\n      parameters:\n" +
+			"        - $ref: 'other.yaml#/id'": "unsupported reference",
+		"openapi: 3.0.0\nservers: [{url: 'http://a'}]\npaths:\n  /a:\n    post:\n      requestBody:\n" +
+			"        content:\n          application/json:\n            schema: {$ref: '#/components/schemas/Missing'}": "schema not found",
+	}
+
+	for text, want := range tests {
+		_, err := LoadOpenAPI(writeTestOpenAPI(t, text), "", nil)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("LoadOpenAPI(%q) = %v, want %q", text, err, want)
+		}
+	}
+}
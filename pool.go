@@ -2,7 +2,7 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-package main
+package gobenchmark
 
 import (
 	"container/list"
@@ -12,9 +12,12 @@ import (
 
 type JobFunc func(args ...interface{}) interface{}
 
+// Job function which receives index of the worker coroutine running it
+type WorkerJobFunc func(worker int, args ...interface{}) interface{}
+
 type Job struct {
 	id   int64
-	fun  JobFunc
+	fun  WorkerJobFunc
 	args []interface{}
 	pipe chan interface{}
 }
@@ -25,11 +28,13 @@ type GoPool struct {
 	Cond     *sync.Cond
 	Queue    *list.List
 	JobPool  *sync.Pool
+	stopped  bool
 }
 
 // Coroutine pool worker process function
 // @param pool: coroutine pool object
-func routine(pool *GoPool) {
+// @param worker: index of worker coroutine, from 0 to pool size - 1
+func routine(pool *GoPool, worker int) {
 	for {
 		pool.Cond.L.Lock()
 
@@ -38,6 +43,11 @@ func routine(pool *GoPool) {
 	checkAgain:
 		elem := pool.Queue.Front()
 		if elem == nil {
+			// Exit after all queued jobs are processed
+			if pool.stopped {
+				pool.Cond.L.Unlock()
+				return
+			}
 			pool.Cond.Wait()
 			goto checkAgain
 		}
@@ -50,7 +60,7 @@ func routine(pool *GoPool) {
 
 		pool.Cond.L.Unlock()
 
-		job.pipe <- job.fun(job.args...) // Third: Call job process function and return value
+		job.pipe <- job.fun(worker, job.args...) // Third: Call job process function and return value
 
 		pool.JobPool.Put(job)
 	}
@@ -76,7 +86,7 @@ func NewGoPool(size int) *GoPool {
 	pool.Cond.L.Lock() // First: stop all worker coroutine
 
 	for i := 0; i < size; i++ {
-		go routine(pool)
+		go routine(pool, i)
 	}
 
 	pool.Cond.L.Unlock() // Second: start all worker coroutine
@@ -89,19 +99,43 @@ func NewGoPool(size int) *GoPool {
 // @param param: job process function parameter
 // @return: chan interface{}
 func (pool *GoPool) Do(fun JobFunc, args ...interface{}) <-chan interface{} {
+	return pool.DoWorker(func(_ int, args ...interface{}) interface{} {
+		return fun(args...)
+	}, args...)
+}
+
+// Send a job to coroutine pool, the job function receives index of
+// the worker coroutine which runs it (etc: to keep per worker state)
+// @param handler: job process function handler
+// @param param: job process function parameter
+// @return: chan interface{}
+func (pool *GoPool) DoWorker(fun WorkerJobFunc, args ...interface{}) <-chan interface{} {
 	job := pool.JobPool.Get().(*Job)
 
 	job.Init(atomic.AddInt64(&pool.LastID, 1), fun, args)
 
+	// Job would be reused by other callers after processed,
+	// so the pipe must be got before pushing to queue
+	pipe := job.pipe
+
 	pool.Cond.L.Lock()
 	pool.Queue.PushBack(job) // First: push job to queue
 	pool.Cond.Signal()       // Second: signal worker routine
 	pool.Cond.L.Unlock()
 
-	return job.pipe
+	return pipe
+}
+
+// Stop all worker coroutines after queued jobs are processed,
+// jobs sent after stopped would never be processed
+func (pool *GoPool) Stop() {
+	pool.Cond.L.Lock()
+	pool.stopped = true
+	pool.Cond.Broadcast()
+	pool.Cond.L.Unlock()
 }
 
-func (j *Job) Init(id int64, fun JobFunc, args []interface{}) {
+func (j *Job) Init(id int64, fun WorkerJobFunc, args []interface{}) {
 	j.id = id
 	j.fun = fun
 	j.args = args
@@ -0,0 +1,127 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGoPoolResults(t *testing.T) {
+	pool := NewGoPool(8)
+
+	double := func(args ...interface{}) interface{} {
+		return args[0].(int) * 2
+	}
+
+	// Jobs are reused after processed, each caller must
+	// still receive the result of its own job
+	var group sync.WaitGroup
+
+	for caller := 0; caller < 8; caller++ {
+		group.Add(1)
+		go func(caller int) {
+			defer group.Done()
+			for i := 0; i < 250; i++ {
+				value := caller*1000 + i
+				select {
+				case result := <-pool.Do(double, value):
+					if result.(int) != value*2 {
+						t.Errorf("result of job %d = %v, want %d", value, result, value*2)
+						return
+					}
+				case <-time.After(5 * time.Second):
+					t.Errorf("job %d timeout", value)
+					return
+				}
+			}
+		}(caller)
+	}
+
+	group.Wait()
+
+	if pool.LastID != 2000 {
+		t.Errorf("LastID = %d, want 2000", pool.LastID)
+	}
+}
+
+func TestGoPoolSize(t *testing.T) {
+	const size = 4
+
+	var (
+		running int64
+		maximum int64
+	)
+
+	pool := NewGoPool(size)
+
+	job := func(args ...interface{}) interface{} {
+		current := atomic.AddInt64(&running, 1)
+		for {
+			peak := atomic.LoadInt64(&maximum)
+			if current <= peak || atomic.CompareAndSwapInt64(&maximum, peak, current) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt64(&running, -1)
+		return nil
+	}
+
+	var pipes []<-chan interface{}
+	for i := 0; i < size*10; i++ {
+		pipes = append(pipes, pool.Do(job))
+	}
+
+	for _, pipe := range pipes {
+		<-pipe
+	}
+
+	if maximum > size {
+		t.Errorf("%d jobs ran concurrently, pool size is %d", maximum, size)
+	}
+
+	if maximum < 2 {
+		t.Errorf("jobs did not run concurrently, peak is %d", maximum)
+	}
+}
+
+func TestGoPoolWorker(t *testing.T) {
+	const size = 4
+
+	pool := NewGoPool(size)
+	defer pool.Stop()
+
+	var (
+		lock    sync.Mutex
+		workers = make(map[int]int)
+	)
+
+	// Jobs sleep so that all worker coroutines are used
+	job := func(worker int, args ...interface{}) interface{} {
+		lock.Lock()
+		workers[worker]++
+		lock.Unlock()
+		time.Sleep(time.Millisecond)
+		return worker
+	}
+
+	var pipes []<-chan interface{}
+	for i := 0; i < size*10; i++ {
+		pipes = append(pipes, pool.DoWorker(job))
+	}
+
+	for _, pipe := range pipes {
+		if worker := (<-pipe).(int); worker < 0 || worker >= size {
+			t.Errorf("worker = %d, want [0, %d)", worker, size)
+		}
+	}
+
+	if len(workers) != size {
+		t.Errorf("workers = %v, want %d workers", workers, size)
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+const (
+	ProtocolAuto  = "auto"
+	ProtocolHTTP1 = "http1"
+	ProtocolH2    = "h2"
+	ProtocolH2C   = "h2c"
+	ProtocolH3    = "h3"
+)
+
+// Options of HTTP client
+type HTTPOptions struct {
+	// Protocol of requests: auto (default, HTTP/2 is negotiated for HTTPS),
+	// http1, h2 (HTTP/2 over TLS), h2c (HTTP/2 over cleartext with prior
+	// knowledge) or h3 (HTTP/3 over QUIC)
+	Protocol string
+	// Max concurrent requests (streams) of each HTTP/2 or HTTP/3
+	// connection, more connections are opened for more workers, 0
+	// means no limit
+	MaxStreams int
+	// Send GET and HEAD requests in 0-RTT data of resumed HTTP/3
+	// connections, the requests may be replayed by attackers
+	ZeroRTT bool
+}
+
+// Clients of plain and secure URLs, they share transport except auto
+type httpClients struct {
+	client *http.Client
+	secure *http.Client
+}
+
+// Create clients of protocol, transports of auto are the default
+// ones if shared is true, all connections are dialed to Unix socket
+// if socket is not empty
+func newHTTPClients(protocol string, shared bool, socket string) (*httpClients, error) {
+	protocols := &http.Protocols{}
+
+	switch strings.ToLower(protocol) {
+	case "", ProtocolAuto:
+		if shared && len(socket) == 0 {
+			return &httpClients{
+				client: &http.Client{Transport: http.DefaultTransport},
+				secure: &http.Client{Transport: skipSSLTransport},
+			}, nil
+		}
+
+		plain := http.DefaultTransport.(*http.Transport).Clone()
+		secure := skipSSLTransport.Clone()
+
+		if len(socket) > 0 {
+			plain.DialContext = unixDialer(socket)
+			secure.DialContext = unixDialer(socket)
+		}
+
+		return &httpClients{
+			client: &http.Client{Transport: plain},
+			secure: &http.Client{Transport: secure},
+		}, nil
+
+	case ProtocolHTTP1:
+		protocols.SetHTTP1(true)
+	case ProtocolH2:
+		protocols.SetHTTP2(true)
+	case ProtocolH2C:
+		protocols.SetUnencryptedHTTP2(true)
+	case ProtocolH3:
+		if len(socket) > 0 {
+			return nil, fmt.Errorf("protocol h3 doesn't support Unix socket")
+		}
+
+		// Sessions are cached for resumption and 0-RTT
+		client := &http.Client{Transport: &http3.Transport{
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: true,
+				ClientSessionCache: tls.NewLRUClientSessionCache(0),
+			},
+		}}
+
+		return &httpClients{client: client, secure: client}, nil
+	default:
+		return nil, fmt.Errorf("unsupported HTTP protocol: %s", protocol)
+	}
+
+	// ALPN protocols of cloned TLS config may be set by HTTP/2
+	transport := skipSSLTransport.Clone()
+	transport.TLSClientConfig.NextProtos = nil
+	transport.ForceAttemptHTTP2 = false
+	transport.Protocols = protocols
+
+	if len(socket) > 0 {
+		transport.DialContext = unixDialer(socket)
+	}
+
+	client := &http.Client{Transport: transport}
+
+	return &httpClients{client: client, secure: client}, nil
+}
+
+// Close transports which are not shared (etc: UDP sockets of HTTP/3)
+func (c *httpClients) close() {
+	for _, client := range []*http.Client{c.client, c.secure} {
+		if closer, ok := client.Transport.(io.Closer); ok {
+			_ = closer.Close()
+		}
+	}
+}
+
+// Use 0-RTT methods of HTTP/3 for GET and HEAD requests
+func zeroRTTMethod(method string) string {
+	switch method {
+	case http.MethodGet:
+		return http3.MethodGet0RTT
+	case http.MethodHead:
+		return http3.MethodHead0RTT
+	}
+	return method
+}
+
+// Check scheme of URL is supported by protocol
+func checkHTTPProtocol(protocol, scheme string) error {
+	switch strings.ToLower(protocol) {
+	case ProtocolH2:
+		if scheme != "https" {
+			return fmt.Errorf("protocol h2 requires https URL, use h2c for http URL")
+		}
+	case ProtocolH3:
+		if scheme != "https" {
+			return fmt.Errorf("protocol h3 requires https URL")
+		}
+	case ProtocolH2C:
+		if scheme != "http" {
+			return fmt.Errorf("protocol h2c requires http URL, use h2 for https URL")
+		}
+	}
+	return nil
+}
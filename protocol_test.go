@@ -0,0 +1,281 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+// Start server of HTTP/1.1 and h2c, or HTTP/1.1 and h2 if secure
+func newTestProtocolServer(t *testing.T, secure bool, handler http.HandlerFunc) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewUnstartedServer(handler)
+
+	if secure {
+		server.EnableHTTP2 = true
+		server.StartTLS()
+	} else {
+		server.Config.Protocols = &http.Protocols{}
+		server.Config.Protocols.SetHTTP1(true)
+		server.Config.Protocols.SetUnencryptedHTTP2(true)
+		server.Start()
+	}
+
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// Start HTTP/3 server on loopback, it uses certificate of httptest
+func newTestHTTP3Server(t *testing.T, handler http.HandlerFunc) string {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only certificate of TLS server is used
+	tlsServer := httptest.NewTLSServer(http.NotFoundHandler())
+	tlsServer.Close()
+
+	server := &http3.Server{
+		Handler:   handler,
+		TLSConfig: http3.ConfigureTLSConfig(tlsServer.TLS.Clone()),
+	}
+
+	go func() {
+		_ = server.Serve(conn)
+	}()
+
+	t.Cleanup(func() {
+		_ = server.Close()
+		_ = conn.Close()
+	})
+
+	return "https://" + conn.LocalAddr().String()
+}
+
+func TestHTTPProtocols(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Proto))
+	}
+
+	plain := newTestProtocolServer(t, false, handler)
+	secure := newTestProtocolServer(t, true, handler)
+
+	tests := []struct {
+		protocol string
+		link     string
+		want     string
+	}{
+		{"", plain.URL, "HTTP/1.1"},
+		{ProtocolAuto, secure.URL, "HTTP/2.0"},
+		{ProtocolHTTP1, plain.URL, "HTTP/1.1"},
+		{ProtocolHTTP1, secure.URL, "HTTP/1.1"},
+		{ProtocolH2, secure.URL, "HTTP/2.0"},
+		{ProtocolH2C, plain.URL, "HTTP/2.0"},
+	}
+
+	for _, test := range tests {
+		config := NewConfig()
+		config.Connections = 2
+		config.Requests = 10
+		config.Items = []*BenchmarkItem{{URL: test.link, ExpectBody: []byte(test.want)}}
+		config.HTTP.Protocol = test.protocol
+
+		snapshot := runBenchmark(t, config).Stats.Snapshot()
+
+		if snapshot.Success != 10 || snapshot.Protocols[test.want] != 10 {
+			t.Errorf("%s of %s: success = %d, protocols = %v", test.protocol, test.link, snapshot.Success, snapshot.Protocols)
+		}
+	}
+}
+
+func TestHTTPMaxStreams(t *testing.T) {
+	var (
+		lock     sync.Mutex
+		inflight = make(map[string]int)
+		peak     = make(map[string]int)
+	)
+
+	server := newTestProtocolServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		inflight[r.RemoteAddr]++
+		if inflight[r.RemoteAddr] > peak[r.RemoteAddr] {
+			peak[r.RemoteAddr] = inflight[r.RemoteAddr]
+		}
+		lock.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		lock.Lock()
+		inflight[r.RemoteAddr]--
+		lock.Unlock()
+	})
+
+	config := NewConfig()
+	config.Connections = 6
+	config.Requests = 60
+	config.Items = []*BenchmarkItem{{URL: server.URL}}
+	config.HTTP = HTTPOptions{Protocol: ProtocolH2C, MaxStreams: 2}
+
+	snapshot := runBenchmark(t, config).Stats.Snapshot()
+
+	if snapshot.Success != 60 || snapshot.Protocols["HTTP/2.0"] != 60 {
+		t.Errorf("success = %d, protocols = %v", snapshot.Success, snapshot.Protocols)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if len(peak) < 3 {
+		t.Errorf("connections = %d, want at least 3 for 6 workers", len(peak))
+	}
+
+	for addr, streams := range peak {
+		if streams > 2 {
+			t.Errorf("concurrent streams of %s = %d, want at most 2", addr, streams)
+		}
+	}
+}
+
+func TestHTTPProtocolErrors(t *testing.T) {
+	for _, options := range []HTTPOptions{
+		{Protocol: "spdy"},
+		{MaxStreams: -1},
+		{Protocol: "h3x", MaxStreams: 2},
+		{Protocol: ProtocolH2, ZeroRTT: true},
+	} {
+		config := NewConfig()
+		config.HTTP = options
+
+		if _, err := NewHTTPExecutor(config); err == nil {
+			t.Errorf("NewHTTPExecutor(%+v) should fail", options)
+		}
+	}
+
+	for _, test := range []struct {
+		protocol string
+		link     string
+	}{
+		{ProtocolH2, "http://127.0.0.1"},
+		{ProtocolH2C, "https://127.0.0.1"},
+		{ProtocolH3, "http://127.0.0.1"},
+	} {
+		config := NewConfig()
+		config.HTTP.Protocol = test.protocol
+
+		executor, err := NewHTTPExecutor(config)
+		if err != nil {
+			t.Fatalf("NewHTTPExecutor(%s) failed: %v", test.protocol, err)
+		}
+
+		if _, err := executor.Prepare(NewRequest(URLOption(test.link))); err == nil {
+			t.Errorf("Prepare(%s) of %s should fail", test.link, test.protocol)
+		}
+	}
+}
+
+func TestHTTP3(t *testing.T) {
+	var (
+		lock  sync.Mutex
+		conns = make(map[string]int)
+	)
+
+	link := newTestHTTP3Server(t, func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		conns[r.RemoteAddr]++
+		lock.Unlock()
+
+		_, _ = w.Write([]byte(r.Proto))
+	})
+
+	config := NewConfig()
+	config.Connections = 4
+	config.Requests = 40
+	config.Items = []*BenchmarkItem{{URL: link, ExpectBody: []byte("HTTP/3.0")}}
+	config.HTTP = HTTPOptions{Protocol: ProtocolH3, MaxStreams: 2}
+
+	snapshot := runBenchmark(t, config).Stats.Snapshot()
+
+	if snapshot.Success != 40 || snapshot.Protocols["HTTP/3.0"] != 40 {
+		t.Errorf("success = %d, protocols = %v", snapshot.Success, snapshot.Protocols)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	// Connections are reused by each of 2 clients
+	if len(conns) != 2 {
+		t.Errorf("connections = %v, want 2", conns)
+	}
+}
+
+func TestHTTP3ZeroRTT(t *testing.T) {
+	var (
+		lock  sync.Mutex
+		early []bool
+	)
+
+	// Request in 0-RTT data is received before handshake completes
+	link := newTestHTTP3Server(t, func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		early = append(early, r.TLS.DidResume && !r.TLS.HandshakeComplete)
+		lock.Unlock()
+	})
+
+	for _, zeroRTT := range []bool{false, true} {
+		config := NewConfig()
+		config.HTTP = HTTPOptions{Protocol: ProtocolH3, ZeroRTT: zeroRTT}
+
+		executor, err := NewHTTPExecutor(config)
+		if err != nil {
+			t.Fatalf("NewHTTPExecutor() failed: %v", err)
+		}
+
+		lock.Lock()
+		early = nil
+		lock.Unlock()
+
+		// Connection is closed after each request, the later ones are resumed
+		for i := 0; i < 3; i++ {
+			prepared, err := executor.Prepare(NewRequest(URLOption(link)))
+			if err != nil {
+				t.Fatalf("Prepare() failed: %v", err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			rsp := executor.Execute(ctx, prepared)
+			cancel()
+
+			if rsp.Err != nil || rsp.Status != "200" || rsp.Protocol != "HTTP/3.0" {
+				t.Fatalf("0-RTT %v: Execute() = %+v", zeroRTT, rsp)
+			}
+
+			executor.(*httpExecutor).group.clients.client.CloseIdleConnections()
+
+			// Wait for session ticket sent after handshake
+			time.Sleep(50 * time.Millisecond)
+		}
+
+		_ = executor.Close()
+
+		lock.Lock()
+		if len(early) != 3 || early[0] || early[1] != zeroRTT || early[2] != zeroRTT {
+			t.Errorf("0-RTT %v: early requests = %v", zeroRTT, early)
+		}
+		lock.Unlock()
+	}
+}
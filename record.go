@@ -0,0 +1,369 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// Interval of saving recorded entries to HAR file
+	recordFlushInterval = time.Second
+)
+
+var (
+	// Hop-by-hop headers which should not be forwarded by proxy
+	recordHopHeaders = []string{
+		"Connection",
+		"Proxy-Connection",
+		"Keep-Alive",
+		"Proxy-Authenticate",
+		"Proxy-Authorization",
+		"Te",
+		"Trailer",
+		"Transfer-Encoding",
+		"Upgrade",
+	}
+)
+
+// Recorder is a HTTP proxy which forwards traffic to the real
+// target and saves requests as HAR file, the HAR file can be
+// replayed by benchmark with --har option
+type Recorder struct {
+	target    *url.URL
+	output    string
+	responses bool
+	transport *http.Transport
+
+	// Entries are appended in memory and saved by flush routine
+	mutex sync.Mutex
+	har   harLog
+	dirty bool
+
+	saveLock sync.Mutex
+	done     chan struct{}
+	stopped  chan struct{}
+	once     sync.Once
+}
+
+// Create recorder, it works as reverse proxy of target if target is
+// set, otherwise it works as forward proxy (etc: http_proxy)
+// @param target: URL of the real target, can be empty
+// @param output: HAR file path which requests are saved to
+// @param responses: whether save response bodies for validation
+// @return: recorder which must be closed to save all requests
+func NewRecorder(target string, output string, responses bool) (*Recorder, error) {
+	if len(output) == 0 {
+		return nil, errors.New("output file has not set")
+	}
+
+	recorder := &Recorder{
+		output:    output,
+		responses: responses,
+		done:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+		transport: &http.Transport{
+			Proxy:               nil,
+			DialContext:         (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
+			TLSClientConfig:     &tls.Config{InsecureSkipVerify: true},
+			TLSHandshakeTimeout: 10 * time.Second,
+			MaxIdleConnsPerHost: 16,
+			// Keep response body as it is, so the client decides the encoding
+			DisableCompression: true,
+		},
+	}
+
+	if len(target) > 0 {
+		if !HasScheme(target) {
+			target = "http://" + target
+		}
+
+		link, err := url.Parse(target)
+		if err != nil {
+			return nil, err
+		}
+
+		recorder.target = link
+	}
+
+	recorder.har.Log.Version = "1.2"
+	recorder.har.Log.Creator = &harCreator{Name: "gobenchmark", Version: Version}
+	recorder.har.Log.Entries = []harEntry{}
+
+	go recorder.flush()
+
+	return recorder, nil
+}
+
+// Save recorded entries periodically until recorder is closed
+func (r *Recorder) flush() {
+	defer close(r.stopped)
+
+	ticker := time.NewTicker(recordFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := r.save(); err != nil {
+				Errorf("Save HAR file failed: %s", err.Error())
+			}
+		case <-r.done:
+			return
+		}
+	}
+}
+
+// Stop flush routine and save all recorded entries,
+// requests recorded after closed are not saved
+func (r *Recorder) Close() error {
+	r.once.Do(func() {
+		close(r.done)
+	})
+
+	<-r.stopped
+
+	return r.save()
+}
+
+// Get URL of the real target which request is forwarded to
+func (r *Recorder) targetURL(req *http.Request) (*url.URL, error) {
+	if r.target == nil {
+		if !req.URL.IsAbs() {
+			return nil, errors.New("target has not set, only proxy requests are supported")
+		}
+		link := *req.URL
+		return &link, nil
+	}
+
+	base := r.target.Scheme + "://" + r.target.Host + strings.TrimRight(r.target.EscapedPath(), "/")
+
+	return url.Parse(base + req.URL.RequestURI())
+}
+
+func removeHopHeaders(header http.Header) {
+	for _, field := range strings.Split(header.Get("Connection"), ",") {
+		if field = strings.TrimSpace(field); len(field) > 0 {
+			header.Del(field)
+		}
+	}
+
+	for _, field := range recordHopHeaders {
+		header.Del(field)
+	}
+}
+
+func harHeaders(header http.Header) []harNameValue {
+	headers := make([]harNameValue, 0, len(header))
+
+	for field, values := range header {
+		for _, value := range values {
+			headers = append(headers, harNameValue{Name: field, Value: value})
+		}
+	}
+
+	return headers
+}
+
+func (r *Recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method == http.MethodConnect {
+		r.tunnel(w, req)
+		return
+	}
+
+	link, err := r.targetURL(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	forward, err := http.NewRequest(req.Method, link.String(), bytes.NewReader(body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	forward.Header = req.Header.Clone()
+	removeHopHeaders(forward.Header)
+
+	if r.target == nil {
+		forward.Host = req.Host
+	}
+
+	start := time.Now()
+
+	resp, err := r.transport.RoundTrip(forward)
+	if err != nil {
+		Errorf("Forward request failed: %s", err.Error())
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		Errorf("Read response failed: %s", err.Error())
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	elapsed := time.Since(start)
+
+	removeHopHeaders(resp.Header)
+
+	for field, values := range resp.Header {
+		w.Header()[field] = values
+	}
+
+	w.WriteHeader(resp.StatusCode)
+	_, _ = w.Write(content)
+
+	r.record(start, elapsed, forward, body, resp, content)
+}
+
+// Tunnel HTTPS traffic of forward proxy, encrypted requests can not be recorded
+func (r *Recorder) tunnel(w http.ResponseWriter, req *http.Request) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "tunnel is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	upstream, err := net.DialTimeout("tcp", req.Host, 30*time.Second)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		upstream.Close()
+		return
+	}
+
+	Debugf("HTTPS traffic of %s is tunneled without recording", req.Host)
+
+	_, _ = conn.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n"))
+
+	go func() {
+		_, _ = io.Copy(upstream, conn)
+		upstream.Close()
+	}()
+
+	_, _ = io.Copy(conn, upstream)
+	conn.Close()
+}
+
+func (r *Recorder) record(start time.Time, elapsed time.Duration, req *http.Request,
+	body []byte, resp *http.Response, content []byte) {
+
+	entry := harEntry{
+		StartedDateTime: start,
+		Time:            float64(elapsed) / float64(time.Millisecond),
+		Request: harRequest{
+			Method:      req.Method,
+			URL:         req.URL.String(),
+			HTTPVersion: "HTTP/1.1",
+			Headers:     harHeaders(req.Header),
+			QueryString: []harNameValue{},
+		},
+		Response: &harResponse{
+			Status:      resp.StatusCode,
+			StatusText:  http.StatusText(resp.StatusCode),
+			HTTPVersion: resp.Proto,
+			Headers:     harHeaders(resp.Header),
+			Content: harContent{
+				Size:     len(content),
+				MimeType: resp.Header.Get("Content-Type"),
+			},
+		},
+	}
+
+	for field, values := range req.URL.Query() {
+		for _, value := range values {
+			entry.Request.QueryString = append(entry.Request.QueryString, harNameValue{Name: field, Value: value})
+		}
+	}
+
+	if len(body) > 0 {
+		entry.Request.PostData = &harPostData{
+			MimeType: req.Header.Get("Content-Type"),
+			Text:     string(body),
+		}
+	}
+
+	if r.responses {
+		if utf8.Valid(content) {
+			entry.Response.Content.Text = string(content)
+		} else {
+			entry.Response.Content.Text = base64.StdEncoding.EncodeToString(content)
+			entry.Response.Content.Encoding = "base64"
+		}
+	}
+
+	r.mutex.Lock()
+	r.har.Log.Entries = append(r.har.Log.Entries, entry)
+	r.dirty = true
+	r.mutex.Unlock()
+}
+
+// Save all entries to output file if there are new entries, the
+// file is replaced so recording can be stopped at any time
+func (r *Recorder) save() error {
+	r.saveLock.Lock()
+	defer r.saveLock.Unlock()
+
+	// Entries are only appended, so the saved ones are not changed
+	// by requests which are recorded while marshaling
+	r.mutex.Lock()
+	if !r.dirty {
+		r.mutex.Unlock()
+		return nil
+	}
+	har := r.har
+	r.dirty = false
+	r.mutex.Unlock()
+
+	data, err := json.MarshalIndent(&har, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	temp := r.output + ".tmp"
+
+	if err := ioutil.WriteFile(temp, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(temp, r.output)
+}
+
+// Count of recorded entries
+func (r *Recorder) Count() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return len(r.har.Log.Entries)
+}
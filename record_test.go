@@ -0,0 +1,87 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Method + " " + r.URL.RequestURI()))
+	}))
+	defer backend.Close()
+
+	output := filepath.Join(t.TempDir(), "session.har")
+
+	recorder, err := NewRecorder(backend.URL, output, true)
+	if err != nil {
+		t.Fatalf("NewRecorder() failed: %v", err)
+	}
+
+	proxy := httptest.NewServer(recorder)
+	defer proxy.Close()
+
+	const total = 20
+
+	var group sync.WaitGroup
+
+	// Proxy requests are recorded concurrently
+	for i := 0; i < total; i++ {
+		group.Add(1)
+		go func(i int) {
+			defer group.Done()
+
+			rsp, err := http.Post(fmt.Sprintf("%s/items?id=%d", proxy.URL, i), "text/plain", strings.NewReader("body"))
+			if err != nil {
+				t.Errorf("request %d failed: %v", i, err)
+				return
+			}
+			defer rsp.Body.Close()
+
+			if body, _ := ioutil.ReadAll(rsp.Body); string(body) != fmt.Sprintf("POST /items?id=%d", i) {
+				t.Errorf("response %d = %q", i, body)
+			}
+		}(i)
+	}
+
+	group.Wait()
+
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	if err := recorder.Close(); err != nil {
+		t.Errorf("Close() again failed: %v", err)
+	}
+
+	if recorder.Count() != total {
+		t.Errorf("count = %d, want %d", recorder.Count(), total)
+	}
+
+	// All entries are saved after closed
+	items, err := LoadHAR(output, HAROptions{Validate: true})
+	if err != nil {
+		t.Fatalf("LoadHAR() failed: %v", err)
+	}
+
+	if len(items) != total {
+		t.Fatalf("saved items = %d, want %d", len(items), total)
+	}
+
+	for _, item := range items {
+		if item.Method != "POST" || string(item.Body) != "body" || item.ExpectStatus != 200 ||
+			!strings.HasSuffix(item.URL, strings.TrimPrefix(string(item.ExpectBody), "POST ")) {
+			t.Errorf("item = %s %s %q, expect %d %q", item.Method, item.URL, item.Body, item.ExpectStatus, item.ExpectBody)
+		}
+	}
+}
@@ -0,0 +1,519 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	redisDefaultPort = "6379"
+)
+
+// Options of Redis command mix, requests are generated for target
+// redis://[user:password@]host:port[/db] (rediss:// for TLS)
+type RedisOptions struct {
+	// Weighted commands (etc: GET:70,SET:20,INCR:5,LPUSH:5),
+	// body is sent as command lines if empty
+	Commands string
+	// Keys are chosen randomly in [1, keys], default is 100000
+	Keys int64
+	// Distribution of keys: uniform (default) or zipfian
+	Distribution string
+	// Size of values of write commands, default is 3, size is
+	// random in [ValueSize, MaxValueSize] if max is greater
+	ValueSize    int
+	MaxValueSize int
+	// Commands sent in one request, default is 1
+	Pipeline int
+}
+
+// Command lines of mix, {key} and {value} are replaced by random
+// key and value, keys of different types have different prefixes
+var redisCommands = map[string]string{
+	"PING":  "PING",
+	"GET":   "GET key:{key}",
+	"SET":   "SET key:{key} {value}",
+	"DEL":   "DEL key:{key}",
+	"INCR":  "INCR counter:{key}",
+	"LPUSH": "LPUSH list:{key} {value}",
+	"RPUSH": "RPUSH list:{key} {value}",
+	"LPOP":  "LPOP list:{key}",
+	"RPOP":  "RPOP list:{key}",
+	"SADD":  "SADD set:{key} {value}",
+	"SPOP":  "SPOP set:{key}",
+	"HSET":  "HSET hash:{key} field {value}",
+	"HGET":  "HGET hash:{key} field",
+}
+
+// Build benchmark items of command mix, each command is an item
+// named by command so latency is reported per command
+// @param target: URL of Redis server
+// @param options: command mix
+func BuildRedisItems(target string, options RedisOptions) ([]*BenchmarkItem, error) {
+	if len(target) == 0 {
+		return nil, errors.New("testing target URL has not set")
+	}
+
+	keys := options.Keys
+	if keys <= 0 {
+		keys = defaultKeys
+	}
+
+	size := options.ValueSize
+	if size <= 0 {
+		size = defaultValueSize
+	}
+
+	pipeline := options.Pipeline
+	if pipeline <= 0 {
+		pipeline = 1
+	}
+
+	key, err := keyTemplate(keys, options.Distribution)
+	if err != nil {
+		return nil, err
+	}
+
+	replacer := strings.NewReplacer("{key}", key, "{value}", valueTemplate(size, options.MaxValueSize))
+
+	commands, err := parseWeightedList(options.Commands)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []*BenchmarkItem
+
+	for _, command := range commands {
+		name := strings.ToUpper(command.name)
+
+		line, exists := redisCommands[name]
+		if !exists {
+			return nil, fmt.Errorf("unsupported Redis command: %s, supported: %s", name, redisCommandNames())
+		}
+
+		line = replacer.Replace(line)
+
+		items = append(items, &BenchmarkItem{
+			Name:   name,
+			Weight: command.weight,
+			URL:    target,
+			Body:   []byte(strings.TrimSpace(strings.Repeat(line+"\n", pipeline))),
+		})
+	}
+
+	if len(items) == 0 {
+		return nil, errors.New("no Redis command found")
+	}
+
+	return items, nil
+}
+
+func redisCommandNames() string {
+	var names []string
+
+	for name := range redisCommands {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return strings.Join(names, ",")
+}
+
+// Split command line into arguments, arguments can be quoted
+// by double quotes (with escape sequences) or single quotes
+func splitRedisArgs(line string) ([]string, error) {
+	var args []string
+
+	for {
+		line = strings.TrimLeft(line, " \t")
+		if len(line) == 0 {
+			return args, nil
+		}
+
+		switch line[0] {
+		case '"':
+			prefix, err := strconv.QuotedPrefix(line)
+			if err != nil {
+				return nil, fmt.Errorf("unbalanced quotes in command: %s", line)
+			}
+
+			arg, _ := strconv.Unquote(prefix)
+			args = append(args, arg)
+			line = line[len(prefix):]
+
+		case '\'':
+			end := strings.IndexByte(line[1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unbalanced quotes in command: %s", line)
+			}
+
+			args = append(args, line[1:end+1])
+			line = line[end+2:]
+
+		default:
+			end := strings.IndexAny(line, " \t")
+			if end < 0 {
+				end = len(line)
+			}
+
+			args = append(args, line[:end])
+			line = line[end:]
+		}
+	}
+}
+
+// Encode command as RESP array of bulk strings
+func appendRedisCommand(buf []byte, args []string) []byte {
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, '\r', '\n')
+
+	for _, arg := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, arg...)
+		buf = append(buf, '\r', '\n')
+	}
+
+	return buf
+}
+
+// Error reply of Redis (etc: ERR unknown command)
+type RedisError string
+
+func (e RedisError) Error() string {
+	return string(e)
+}
+
+// Prefix of error reply (etc: ERR, WRONGTYPE)
+func (e RedisError) Prefix() string {
+	if index := strings.IndexByte(string(e), ' '); index > 0 {
+		return string(e[:index])
+	}
+	return string(e)
+}
+
+type redisReader struct {
+	reader *bufio.Reader
+	// Received bytes
+	bytes int64
+}
+
+func (r *redisReader) line() ([]byte, error) {
+	line, err := r.reader.ReadSlice('\n')
+	r.bytes += int64(len(line))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("invalid RESP line: %q", line)
+	}
+
+	return line[:len(line)-2], nil
+}
+
+// Read reply and write it to body in text format of redis-cli,
+// error reply is returned as RedisError
+func (r *redisReader) read(body *bytes.Buffer, indent string) error {
+	line, err := r.line()
+	if err != nil {
+		return err
+	}
+
+	value := string(line[1:])
+
+	switch line[0] {
+	case '+':
+		body.WriteString(value)
+
+	case '-':
+		body.WriteString("(error) " + value)
+		return RedisError(value)
+
+	case ':':
+		body.WriteString("(integer) " + value)
+
+	case '$':
+		size, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid RESP bulk length: %s", value)
+		}
+
+		if size < 0 {
+			body.WriteString("(nil)")
+			return nil
+		}
+
+		data := make([]byte, size+2)
+		n, err := io.ReadFull(r.reader, data)
+		r.bytes += int64(n)
+		if err != nil {
+			return err
+		}
+
+		body.WriteString(strconv.Quote(string(data[:size])))
+
+	case '*':
+		count, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid RESP array length: %s", value)
+		}
+
+		if count < 0 {
+			body.WriteString("(nil)")
+			return nil
+		}
+
+		if count == 0 {
+			body.WriteString("(empty array)")
+			return nil
+		}
+
+		var replyErr error
+
+		for i := 0; i < count; i++ {
+			if i > 0 {
+				body.WriteString("\n" + indent)
+			}
+
+			prefix := strconv.Itoa(i+1) + ") "
+			body.WriteString(prefix)
+
+			// Errors in array are returned after whole array read
+			if err := r.read(body, indent+strings.Repeat(" ", len(prefix))); err != nil {
+				if _, ok := err.(RedisError); !ok {
+					return err
+				}
+				if replyErr == nil {
+					replyErr = err
+				}
+			}
+		}
+
+		return replyErr
+
+	default:
+		return fmt.Errorf("unsupported RESP type: %q", line[0])
+	}
+
+	return nil
+}
+
+type redisCall struct {
+	conn     *socketConn
+	commands []byte
+	count    int
+	connect  time.Duration
+}
+
+type redisExecutor struct {
+	tls  *tls.Config
+	pool *connPool
+	// Unix socket dialed instead of address of URL
+	socket string
+}
+
+func init() {
+	RegisterExecutor("redis", NewRedisExecutor)
+	RegisterExecutor("rediss", NewRedisExecutor)
+}
+
+// Create executor of Redis protocol, body of request is command lines
+// which are sent in pipeline, at most connections sockets are opened
+func NewRedisExecutor(config *Config) (Executor, error) {
+	if config.Connections <= 0 {
+		return nil, fmt.Errorf("connections must be greater than 0")
+	}
+
+	executor := &redisExecutor{
+		tls:    &tls.Config{InsecureSkipVerify: true},
+		pool:   newConnPool(config.Connections),
+		socket: config.UnixSocket,
+	}
+
+	return executor, nil
+}
+
+func (e *redisExecutor) Prepare(req *Request) (interface{}, error) {
+	info, err := url.Parse(req.GetURL())
+	if err != nil {
+		return nil, err
+	}
+
+	call := &redisCall{}
+
+	for _, line := range strings.Split(string(req.GetBody()), "\n") {
+		args, err := splitRedisArgs(strings.TrimSpace(line))
+		if err != nil {
+			return nil, err
+		}
+
+		if len(args) > 0 {
+			call.commands = appendRedisCommand(call.commands, args)
+			call.count++
+		}
+	}
+
+	if call.count == 0 {
+		return nil, errors.New("no Redis command in body")
+	}
+
+	addr := info.Host
+	if len(info.Port()) == 0 {
+		addr = net.JoinHostPort(info.Hostname(), redisDefaultPort)
+	}
+
+	key := strings.ToLower(info.Scheme) + "://" + info.User.String() + "@" + addr + info.Path
+
+	conn, connect, err := e.pool.get(key, func() (net.Conn, error) {
+		return e.dial(info, addr, req.GetTimeout())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if connect > 0 {
+		if err := e.setup(conn, info, req.GetTimeout()); err != nil {
+			e.pool.put(conn, false)
+			return nil, err
+		}
+		call.connect = connect
+	}
+
+	call.conn = conn
+
+	return call, nil
+}
+
+func (e *redisExecutor) dial(info *url.URL, addr string, timeout time.Duration) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+
+	network := "tcp"
+	if len(e.socket) > 0 {
+		network, addr = "unix", e.socket
+	}
+
+	if strings.ToLower(info.Scheme) == "rediss" {
+		return tls.DialWithDialer(dialer, network, addr, e.tls)
+	}
+
+	return dialer.Dial(network, addr)
+}
+
+// Authenticate and select database of URL on new connection
+func (e *redisExecutor) setup(c *socketConn, info *url.URL, timeout time.Duration) error {
+	var commands [][]string
+
+	if password, exists := info.User.Password(); exists {
+		if username := info.User.Username(); len(username) > 0 {
+			commands = append(commands, []string{"AUTH", username, password})
+		} else {
+			commands = append(commands, []string{"AUTH", password})
+		}
+	}
+
+	if db := strings.Trim(info.Path, "/"); len(db) > 0 && db != "0" {
+		commands = append(commands, []string{"SELECT", db})
+	}
+
+	if timeout > 0 {
+		_ = c.conn.SetDeadline(time.Now().Add(timeout))
+	}
+
+	reader := &redisReader{reader: c.reader}
+
+	for _, args := range commands {
+		_, err := c.conn.Write(appendRedisCommand(nil, args))
+		if err == nil {
+			err = reader.read(&bytes.Buffer{}, "")
+		}
+
+		if err != nil {
+			return fmt.Errorf("redis %s failed: %s", args[0], err.Error())
+		}
+	}
+
+	return nil
+}
+
+func (e *redisExecutor) Execute(ctx context.Context, prepared interface{}) *Response {
+	call := prepared.(*redisCall)
+	c := call.conn
+
+	rsp := &Response{Connect: call.connect}
+
+	// Replies of pipeline may be left in reader after error
+	defer func() {
+		e.pool.put(c, rsp.Err == nil)
+	}()
+
+	deadline, _ := ctx.Deadline()
+	_ = c.conn.SetDeadline(deadline)
+
+	reader := &redisReader{reader: c.reader}
+
+	if _, err := c.conn.Write(call.commands); err != nil {
+		rsp.Err = err
+		rsp.Disconnected = isDisconnected(err)
+		return rsp
+	}
+
+	var (
+		body     bytes.Buffer
+		replyErr error
+	)
+
+	for i := 0; i < call.count; i++ {
+		if i > 0 {
+			body.WriteByte('\n')
+		}
+
+		if err := reader.read(&body, ""); err != nil {
+			if _, ok := err.(RedisError); !ok {
+				rsp.Err = err
+				rsp.Disconnected = isDisconnected(err)
+				return rsp
+			}
+			if replyErr == nil {
+				replyErr = err
+			}
+		}
+	}
+
+	rsp.Body = body.Bytes()
+	rsp.Bytes = reader.bytes
+
+	// Status is prefix of the first error reply
+	if replyErr != nil {
+		rsp.Status = replyErr.(RedisError).Prefix()
+		return rsp
+	}
+
+	rsp.Status = "OK"
+	rsp.OK = true
+
+	return rsp
+}
+
+func (e *redisExecutor) Close() error {
+	e.pool.close()
+	return nil
+}
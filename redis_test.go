@@ -0,0 +1,335 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Redis compatible server for test, it supports a few commands
+type testRedisServer struct {
+	password string
+
+	lock     sync.Mutex
+	data     map[string]string
+	lists    map[string][]string
+	commands map[string]int
+	dbs      map[string]int
+}
+
+// Read command of RESP array
+func readTestRedisCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil || line[0] != '*' {
+		return nil, fmt.Errorf("invalid command: %q", line)
+	}
+
+	args := make([]string, count)
+
+	for i := range args {
+		if line, err = reader.ReadString('\n'); err != nil {
+			return nil, err
+		}
+
+		size, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+
+		args[i] = string(data[:size])
+	}
+
+	return args, nil
+}
+
+func (s *testRedisServer) execute(args []string, authed *bool) string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	name := strings.ToUpper(args[0])
+	s.commands[name]++
+
+	if name == "AUTH" {
+		if args[len(args)-1] != s.password {
+			return "-WRONGPASS invalid password\r\n"
+		}
+		*authed = true
+		return "+OK\r\n"
+	}
+
+	if len(s.password) > 0 && !*authed {
+		return "-NOAUTH Authentication required.\r\n"
+	}
+
+	switch name {
+	case "PING":
+		return "+PONG\r\n"
+	case "SELECT":
+		s.dbs[args[1]]++
+		return "+OK\r\n"
+	case "SET":
+		s.data[args[1]] = args[2]
+		return "+OK\r\n"
+	case "GET":
+		value, exists := s.data[args[1]]
+		if !exists {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+	case "INCR":
+		value, _ := strconv.Atoi(s.data[args[1]])
+		s.data[args[1]] = strconv.Itoa(value + 1)
+		return ":" + s.data[args[1]] + "\r\n"
+	case "LPUSH":
+		for _, value := range args[2:] {
+			s.lists[args[1]] = append([]string{value}, s.lists[args[1]]...)
+		}
+		return ":" + strconv.Itoa(len(s.lists[args[1]])) + "\r\n"
+	case "LRANGE":
+		reply := "*" + strconv.Itoa(len(s.lists[args[1]])) + "\r\n"
+		for _, value := range s.lists[args[1]] {
+			reply += fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+		}
+		return reply
+	}
+
+	return "-ERR unknown command '" + args[0] + "'\r\n"
+}
+
+func newTestRedisServer(t *testing.T, password string) (*testRedisServer, string) {
+	t.Helper()
+
+	server := &testRedisServer{
+		password: password,
+		data:     make(map[string]string),
+		lists:    make(map[string][]string),
+		commands: make(map[string]int),
+		dbs:      make(map[string]int),
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+
+				reader := bufio.NewReader(conn)
+				authed := false
+
+				for {
+					args, err := readTestRedisCommand(reader)
+					if err != nil {
+						return
+					}
+
+					if _, err := conn.Write([]byte(server.execute(args, &authed))); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+
+	return server, listener.Addr().String()
+}
+
+func TestRedisCommandMix(t *testing.T) {
+	server, addr := newTestRedisServer(t, "secret")
+
+	config := NewConfig()
+	config.Connections = 4
+	config.Requests = 200
+	config.Timeout = 5 * time.Second
+	config.Target = "redis://:secret@" + addr + "/2"
+	config.Redis = RedisOptions{
+		Commands:  "get:5, SET:3,INCR:1,LPUSH",
+		Keys:      10,
+		ValueSize: 16,
+		Pipeline:  3,
+	}
+
+	snapshot := runBenchmark(t, config).Stats.Snapshot()
+
+	if snapshot.Total != 200 || snapshot.Success != 200 {
+		t.Errorf("total = %d, success = %d, status = %v", snapshot.Total, snapshot.Success, snapshot.Status)
+	}
+
+	if names := snapshot.GroupNames(); !reflect.DeepEqual(names, []string{"GET", "INCR", "LPUSH", "SET"}) {
+		t.Errorf("groups = %v", names)
+	}
+
+	if snapshot.Connects == 0 || snapshot.Connects > 4 {
+		t.Errorf("connects = %d, want at most 4", snapshot.Connects)
+	}
+
+	server.lock.Lock()
+	defer server.lock.Unlock()
+
+	total := server.commands["GET"] + server.commands["SET"] + server.commands["INCR"] + server.commands["LPUSH"]
+	if total != 600 {
+		t.Errorf("commands = %v, want 600 commands in pipelines", server.commands)
+	}
+
+	if int64(server.commands["AUTH"]) != snapshot.Connects || int64(server.dbs["2"]) != snapshot.Connects {
+		t.Errorf("AUTH = %d, SELECT = %v, want once for each connection", server.commands["AUTH"], server.dbs)
+	}
+
+	for key, value := range server.data {
+		if strings.HasPrefix(key, "key:") && value != strings.Repeat("x", 16) {
+			t.Errorf("value of %s = %s", key, value)
+		}
+
+		id, _ := strconv.Atoi(key[strings.IndexByte(key, ':')+1:])
+		if id < 1 || id > 10 {
+			t.Errorf("key %s out of key space", key)
+		}
+	}
+}
+
+func TestRedisBody(t *testing.T) {
+	_, addr := newTestRedisServer(t, "")
+
+	config := NewConfig()
+	config.Connections = 1
+
+	executor, err := NewRedisExecutor(config)
+	if err != nil {
+		t.Fatalf("NewRedisExecutor() failed: %v", err)
+	}
+	defer executor.Close()
+
+	tests := []struct {
+		body   string
+		status string
+		reply  string
+	}{
+		{"SET \"a b\" 'c d'\n\nGET \"a b\"\nGET none", "OK", "OK\n\"c d\"\n(nil)"},
+		{"LPUSH list x y\nLRANGE list 0 -1\nINCR n", "OK", "(integer) 2\n1) \"y\"\n2) \"x\"\n(integer) 1"},
+		{"PING\nUNKNOWN 1\nPING", "ERR", "PONG\n(error) ERR unknown command 'UNKNOWN'\nPONG"},
+	}
+
+	for _, test := range tests {
+		req := NewRequest(URLOption("redis://"+addr), BodyOption([]byte(test.body)))
+
+		prepared, err := executor.Prepare(req)
+		if err != nil {
+			t.Fatalf("Prepare(%q) failed: %v", test.body, err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		rsp := executor.Execute(ctx, prepared)
+		cancel()
+
+		if rsp.Err != nil || rsp.Status != test.status || rsp.OK != (test.status == "OK") {
+			t.Errorf("Execute(%q) = %+v", test.body, rsp)
+		}
+
+		if string(rsp.Body) != test.reply {
+			t.Errorf("reply of %q = %q, want %q", test.body, rsp.Body, test.reply)
+		}
+
+		if rsp.Bytes == 0 {
+			t.Errorf("received bytes of %q are not counted", test.body)
+		}
+	}
+
+	// Errors of preparing request
+	for _, test := range []struct {
+		link, body string
+	}{
+		{"redis://:wrong@" + addr, "PING"},
+		{"redis://" + addr, "  \n"},
+		{"redis://" + addr, `GET "a`},
+	} {
+		req := NewRequest(URLOption(test.link), BodyOption([]byte(test.body)))
+		if _, err := executor.Prepare(req); err == nil {
+			t.Errorf("Prepare(%s, %q) should fail", test.link, test.body)
+		}
+	}
+}
+
+func TestRedisNoAuth(t *testing.T) {
+	_, addr := newTestRedisServer(t, "secret")
+
+	config := NewConfig()
+	config.Connections = 2
+	config.Requests = 10
+	config.Timeout = 5 * time.Second
+	config.Items = []*BenchmarkItem{{URL: "redis://" + addr, Body: []byte("PING")}}
+
+	if snapshot := runBenchmark(t, config).Stats.Snapshot(); snapshot.Failure != 10 || snapshot.Status["NOAUTH"] != 10 {
+		t.Errorf("failure = %d, status = %v", snapshot.Failure, snapshot.Status)
+	}
+}
+
+func TestRedisItems(t *testing.T) {
+	items, err := BuildRedisItems("redis://127.0.0.1", RedisOptions{Commands: "SET:2,PING", Pipeline: 2})
+	if err != nil {
+		t.Fatalf("BuildRedisItems() failed: %v", err)
+	}
+
+	if len(items) != 2 || items[0].Name != "SET" || items[0].Weight != 2 || items[1].Weight != 1 {
+		t.Fatalf("items = %+v", items)
+	}
+
+	want := "SET key:{{randInt 1 100000}} xxx\nSET key:{{randInt 1 100000}} xxx"
+	if string(items[0].Body) != want {
+		t.Errorf("body = %q, want %q", items[0].Body, want)
+	}
+
+	for _, commands := range []string{"FLUSHALL", "GET:0", "GET:x", " , "} {
+		if _, err := BuildRedisItems("redis://127.0.0.1", RedisOptions{Commands: commands}); err == nil {
+			t.Errorf("BuildRedisItems(%q) should fail", commands)
+		}
+	}
+
+	args, err := splitRedisArgs(`SET  "a\tb\"" 'c "d' e`)
+	if err != nil || !reflect.DeepEqual(args, []string{"SET", "a\tb\"", `c "d`, "e"}) {
+		t.Errorf("splitRedisArgs() = %q, %v", args, err)
+	}
+
+	reader := &redisReader{reader: bufio.NewReader(bytes.NewBufferString("*2\r\n*2\r\n:1\r\n-ERR x\r\n*0\r\n"))}
+
+	var body bytes.Buffer
+
+	if err := reader.read(&body, ""); err == nil || err.Error() != "ERR x" {
+		t.Errorf("error of nested array = %v", err)
+	}
+
+	if want := "1) 1) (integer) 1\n   2) (error) ERR x\n2) (empty array)"; body.String() != want {
+		t.Errorf("nested array = %q, want %q", body.String(), want)
+	}
+}
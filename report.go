@@ -0,0 +1,255 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+)
+
+type ReportSummary struct {
+	Total         int64            `json:"total"`
+	Success       int64            `json:"success"`
+	Failure       int64            `json:"failure"`
+	SuccessRate   float64          `json:"success_rate"`
+	RecvBytes     int64            `json:"recv_bytes"`
+	MinTime       int64            `json:"min_time"`
+	MaxTime       int64            `json:"max_time"`
+	AvgTime       int64            `json:"avg_time"`
+	RPS           float64          `json:"requests_per_sec"`
+	TransferPS    float64          `json:"transfer_per_sec"`
+	Connects      int64            `json:"connects,omitempty"`
+	ConnectTime   int64            `json:"avg_connect_time,omitempty"`
+	Disconnects   int64            `json:"disconnects,omitempty"`
+	Dropped       int64            `json:"dropped_messages,omitempty"`
+	Streams       int64            `json:"streams,omitempty"`
+	FirstByteTime int64            `json:"avg_first_byte_time,omitempty"`
+	Events        int64            `json:"events,omitempty"`
+	EventGap      int64            `json:"avg_event_gap,omitempty"`
+	Percentiles   map[string]int64 `json:"percentiles"`
+	Status        map[string]int64 `json:"status"`
+	Protocols     map[string]int64 `json:"protocols,omitempty"`
+}
+
+type Report struct {
+	Name        string                   `json:"name,omitempty"`
+	Connections int                      `json:"connections"`
+	Dropped     int64                    `json:"dropped,omitempty"`
+	Summary     ReportSummary            `json:"summary"`
+	Tags        map[string]ReportSummary `json:"tags,omitempty"`
+}
+
+// Result of finished benchmark
+type Result struct {
+	Connections int
+	Stats       *Stats
+	// Requests not sent because rows of data file are used up
+	Dropped int64
+}
+
+// Get name of percentile in reports (etc: p99)
+func PercentileName(percent float64) string {
+	return "p" + strconv.FormatFloat(percent, 'f', -1, 64)
+}
+
+func newReportSummary(snapshot Snapshot) ReportSummary {
+	summary := ReportSummary{
+		Total:         snapshot.Total,
+		Success:       snapshot.Success,
+		Failure:       snapshot.Failure,
+		RecvBytes:     snapshot.RecvBytes,
+		MinTime:       snapshot.MinTime,
+		MaxTime:       snapshot.MaxTime,
+		AvgTime:       snapshot.AvgTime,
+		RPS:           snapshot.RequestsPerSec,
+		TransferPS:    snapshot.TransferPerSec,
+		Connects:      snapshot.Connects,
+		ConnectTime:   snapshot.AvgConnectTime,
+		Disconnects:   snapshot.Disconnects,
+		Dropped:       snapshot.DroppedMessages,
+		Streams:       snapshot.Streams,
+		FirstByteTime: snapshot.AvgFirstByteTime,
+		Events:        snapshot.Events,
+		EventGap:      snapshot.AvgEventGap,
+		Percentiles:   make(map[string]int64),
+		Status:        make(map[string]int64),
+	}
+
+	if snapshot.Total > 0 {
+		summary.SuccessRate = float64(snapshot.Success) * 100 / float64(snapshot.Total)
+	}
+
+	for percent, elapsed := range snapshot.Percentiles {
+		summary.Percentiles[PercentileName(percent)] = elapsed
+	}
+
+	for code, count := range snapshot.Status {
+		summary.Status[code] = count
+	}
+
+	if len(snapshot.Protocols) > 0 {
+		summary.Protocols = make(map[string]int64, len(snapshot.Protocols))
+		for protocol, count := range snapshot.Protocols {
+			summary.Protocols[protocol] = count
+		}
+	}
+
+	return summary
+}
+
+// Create report from benchmark result
+// @param name: scenario name, can be empty
+// @param result: result of finished benchmark
+func NewReport(name string, result *Result) *Report {
+	snapshot := result.Stats.Snapshot()
+
+	report := &Report{
+		Name:        name,
+		Connections: result.Connections,
+		Dropped:     result.Dropped,
+		Summary:     newReportSummary(snapshot),
+	}
+
+	if len(snapshot.Groups) > 0 {
+		report.Tags = make(map[string]ReportSummary, len(snapshot.Groups))
+		for name, group := range snapshot.Groups {
+			report.Tags[name] = newReportSummary(group)
+		}
+	}
+
+	return report
+}
+
+// Create report of result, the same as NewReport(name, r)
+func (r *Result) Report(name string) *Report {
+	return NewReport(name, r)
+}
+
+// Write result in text format
+func (r *Result) WriteText(w io.Writer) {
+	snapshot := r.Stats.Snapshot()
+
+	fmt.Fprintf(w, "  Connections(Routines): %d\n", r.Connections)
+
+	if r.Dropped > 0 {
+		fmt.Fprintf(w, "  Dropped Requests: %d (data file used up)\n", r.Dropped)
+	}
+
+	writeSnapshot(w, snapshot)
+
+	if len(snapshot.Groups) <= 1 {
+		return
+	}
+
+	for _, name := range snapshot.GroupNames() {
+		fmt.Fprintf(w, "\n  Tag(%s):\n", name)
+		writeSnapshot(w, snapshot.Groups[name])
+	}
+}
+
+func writeSnapshot(w io.Writer, snapshot Snapshot) {
+	// Make sure dividend not zero
+	totalReqs := snapshot.Total
+	if totalReqs == 0 {
+		totalReqs = 1
+	}
+
+	fmt.Fprintf(w, "  Success Total: %d reqs\n", snapshot.Success)
+	fmt.Fprintf(w, "  Failure Total: %d reqs\n", snapshot.Failure)
+	fmt.Fprintf(w, "  Success Rate: %d%%\n", snapshot.Success*100/totalReqs)
+	fmt.Fprintf(w, "  Receive Data %s\n", formatBytes(float64(snapshot.RecvBytes)))
+	fmt.Fprintf(w, "  Fastest Request: %d(MS)\n", snapshot.MinTime)
+	fmt.Fprintf(w, "  Slowest Request: %d(MS)\n", snapshot.MaxTime)
+	fmt.Fprintf(w, "  Average Request Time: %d(MS)\n", snapshot.AvgTime)
+
+	for _, percent := range Percentiles {
+		fmt.Fprintf(w, "  %v%% Request Time: %d(MS)\n", percent, snapshot.Percentiles[percent])
+	}
+
+	fmt.Fprintf(w, "  Requests/sec: %0.2f\n", snapshot.RequestsPerSec)
+	fmt.Fprintf(w, "  Transfer/sec: %s\n", formatBytes(snapshot.TransferPerSec))
+
+	if snapshot.Connects > 0 || snapshot.Disconnects > 0 {
+		fmt.Fprintf(w, "  Connects: %d\n", snapshot.Connects)
+		fmt.Fprintf(w, "  Average Connect Time: %d(MS)\n", snapshot.AvgConnectTime)
+		fmt.Fprintf(w, "  Slowest Connect: %d(MS)\n", snapshot.MaxConnectTime)
+		fmt.Fprintf(w, "  Disconnects: %d\n", snapshot.Disconnects)
+	}
+
+	if snapshot.DroppedMessages > 0 {
+		fmt.Fprintf(w, "  Dropped Messages: %d\n", snapshot.DroppedMessages)
+	}
+
+	if snapshot.Streams > 0 {
+		fmt.Fprintf(w, "  Average Time To First Byte: %d(MS)\n", snapshot.AvgFirstByteTime)
+		fmt.Fprintf(w, "  Slowest Time To First Byte: %d(MS)\n", snapshot.MaxFirstByteTime)
+		fmt.Fprintf(w, "  Events: %d\n", snapshot.Events)
+		fmt.Fprintf(w, "  Average Event Gap: %d(MS)\n", snapshot.AvgEventGap)
+		fmt.Fprintf(w, "  Slowest Event Gap: %d(MS)\n", snapshot.MaxEventGap)
+	}
+
+	fmt.Fprintf(w, "----------------------------\n")
+
+	var codes []string
+
+	for code := range snapshot.Status {
+		codes = append(codes, code)
+	}
+
+	sort.Strings(codes)
+
+	for _, code := range codes {
+		fmt.Fprintf(w, "Status %s: %d reqs\n", code, snapshot.Status[code])
+	}
+
+	var protocols []string
+
+	for protocol := range snapshot.Protocols {
+		protocols = append(protocols, protocol)
+	}
+
+	sort.Strings(protocols)
+
+	for _, protocol := range protocols {
+		fmt.Fprintf(w, "Protocol %s: %d reqs\n", protocol, snapshot.Protocols[protocol])
+	}
+}
+
+func formatBytes(bytes float64) string {
+	switch {
+	case bytes > 1024*1024*1024:
+		return fmt.Sprintf("%0.3f(GB)", bytes/1024/1024/1024)
+	case bytes > 1024*1024:
+		return fmt.Sprintf("%0.3f(MB)", bytes/1024/1024)
+	case bytes > 1024:
+		return fmt.Sprintf("%0.3f(KB)", bytes/1024)
+	}
+	return fmt.Sprintf("%0.3f(B)", bytes)
+}
+
+func LoadReport(path string) (*Report, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{}
+
+	if err := json.Unmarshal(data, report); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err.Error())
+	}
+
+	return report, nil
+}
+
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
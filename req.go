@@ -2,17 +2,18 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-package main
+package gobenchmark
 
 import (
 	"bytes"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
-	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
+	"net/url"
 	"strings"
 	"sync"
 	"time"
@@ -26,23 +27,41 @@ type Options struct {
 	Body        []byte
 	ContentType string
 	Timeout     time.Duration
+	Tag         string
 }
 
 type Request struct {
 	opts    *Options
 	Elapsed int64
 	Status  int
+	// Called for each event of streaming response
+	onEvent func(event *StreamEvent) bool
 }
 
 type Option func(*Options)
 
 const (
-	MethodGet  = 1
-	MethodPost = 2
-	MethodNone = 3
+	MethodGet     = 1
+	MethodPost    = 2
+	MethodPut     = 3
+	MethodDelete  = 4
+	MethodPatch   = 5
+	MethodHead    = 6
+	MethodOptions = 7
+	MethodNone    = 8
 )
 
 var (
+	methodNames = map[int]string{
+		MethodGet:     "GET",
+		MethodPost:    "POST",
+		MethodPut:     "PUT",
+		MethodDelete:  "DELETE",
+		MethodPatch:   "PATCH",
+		MethodHead:    "HEAD",
+		MethodOptions: "OPTIONS",
+	}
+
 	clientPool = sync.Pool{
 		New: func() interface{} {
 			return &http.Client{}
@@ -85,10 +104,9 @@ func CaseCompare(src, dst string) int {
 			c2 -= offset
 		}
 
-		ret := c1 - c2
-		if ret > 0 {
+		if c1 > c2 {
 			return 1
-		} else if ret < 0 {
+		} else if c1 < c2 {
 			return -1
 		}
 	}
@@ -96,15 +114,27 @@ func CaseCompare(src, dst string) int {
 	return len(src) - len(dst)
 }
 
+// Whether URL starts with a scheme (etc: http://, redis://, unix://)
 func HasScheme(url string) bool {
-	size := len(url)
+	index := schemeIndex(url)
+	return index > 0 && len(url) > index+3
+}
+
+// Get method by name (case insensitive), MethodNone if unsupported
+func ParseMethod(name string) int {
+	name = strings.ToUpper(name)
 
-	if (size > 8 && strings.ToLower(url[0:8]) == "https://") ||
-		(size > 7 && strings.ToLower(url[0:7]) == "http://") {
-		return true
+	for method, methodName := range methodNames {
+		if methodName == name {
+			return method
+		}
 	}
 
-	return false
+	return MethodNone
+}
+
+func MethodName(method int) string {
+	return methodNames[method]
 }
 
 func NewRequest(opts ...Option) *Request {
@@ -195,27 +225,43 @@ func TimeoutOption(timeout time.Duration) Option {
 	}
 }
 
+func TagOption(tag string) Option {
+	return func(opt *Options) {
+		opt.Tag = tag
+	}
+}
+
+// Encode params as URL query, fields and values are escaped
+// and sorted by field to keep the same order
 func (req *Request) encodeURI() string {
-	var uri string
+	values := url.Values{}
 
-	if len(req.opts.Params) > 0 {
-		for field, value := range req.opts.Params {
-			uri = fmt.Sprintf("%s&%s=%s", uri, field, value)
-		}
-		uri = strings.TrimLeft(uri, "&")
+	for field, value := range req.opts.Params {
+		values.Set(field, value)
 	}
 
-	return uri
+	return values.Encode()
 }
 
-func (req *Request) get(client *http.Client) (*http.Response, error) {
-	url := req.opts.URL
+// Build request without body, params are encoded into URL
+// (etc: GET, HEAD, OPTIONS, DELETE)
+func (req *Request) get() (*http.Request, error) {
+	link := req.opts.URL
 
 	if len(req.opts.Params) > 0 {
-		url = fmt.Sprintf("%s?%s", url, req.encodeURI())
+		separator := "?"
+		if strings.Contains(link, "?") {
+			separator = "&"
+		}
+		link += separator + req.encodeURI()
+	}
+
+	var body io.Reader
+	if req.opts.Body != nil {
+		body = bytes.NewBuffer(req.opts.Body)
 	}
 
-	request, err := http.NewRequest("GET", url, nil)
+	request, err := http.NewRequest(MethodName(req.opts.Method), link, body)
 	if err != nil {
 		return nil, err
 	}
@@ -224,17 +270,12 @@ func (req *Request) get(client *http.Client) (*http.Response, error) {
 		request.Header[field] = []string{value}
 	}
 
-	rsp, err := client.Do(request)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Status = rsp.StatusCode
-
-	return rsp, nil
+	return request, nil
 }
 
-func (req *Request) post(client *http.Client) (*http.Response, error) {
+// Build request with body, params are encoded into body
+// if body has not set (etc: POST, PUT, PATCH)
+func (req *Request) post() (*http.Request, error) {
 	var body []byte
 
 	if req.opts.Body != nil {
@@ -245,7 +286,7 @@ func (req *Request) post(client *http.Client) (*http.Response, error) {
 		body = []byte(req.encodeURI())
 	}
 
-	request, err := http.NewRequest("POST", req.opts.URL, bytes.NewBuffer(body))
+	request, err := http.NewRequest(MethodName(req.opts.Method), req.opts.URL, bytes.NewBuffer(body))
 	if err != nil {
 		return nil, err
 	}
@@ -254,14 +295,27 @@ func (req *Request) post(client *http.Client) (*http.Response, error) {
 		request.Header[field] = []string{value}
 	}
 
-	rsp, err := client.Do(request)
-	if err != nil {
-		return nil, err
+	return request, nil
+}
+
+// Build HTTP request of options
+func (req *Request) httpRequest() (*http.Request, error) {
+	switch req.opts.Method {
+	case MethodGet, MethodDelete, MethodHead, MethodOptions:
+		return req.get()
+	case MethodPost, MethodPut, MethodPatch:
+		return req.post()
 	}
 
-	req.Status = rsp.StatusCode
+	return nil, errors.New("unsupported method")
+}
 
-	return rsp, nil
+// Get transport of URL, certificate is not verified for HTTPS
+func httpTransport(link string) http.RoundTripper {
+	if len(link) >= 5 && strings.ToLower(link[0:5]) == "https" {
+		return skipSSLTransport
+	}
+	return http.DefaultTransport
 }
 
 func getTimestampMs() int64 {
@@ -269,37 +323,35 @@ func getTimestampMs() int64 {
 }
 
 func (req *Request) Do() ([]byte, error) {
-	if len(req.opts.URL) < 7 {
+	if len(req.opts.URL) == 0 {
 		return nil, errors.New("request URL cannot be empty")
 	}
 
-	client := clientPool.Get().(*http.Client)
-
-	if req.opts.Timeout > 0 {
-		client.Timeout = req.opts.Timeout
+	request, err := req.httpRequest()
+	if err != nil {
+		return nil, err
 	}
 
-	if strings.ToLower(req.opts.URL[0:5]) == "https" {
-		client.Transport = skipSSLTransport
-	} else {
-		client.Transport = http.DefaultTransport
+	transport := httpTransport(req.opts.URL)
+
+	if request.URL.Scheme == "unix" {
+		socket, err := rewriteUnixRequest(request)
+		if err != nil {
+			return nil, err
+		}
+		transport = unixTransport(socket)
 	}
 
-	var (
-		rsp *http.Response
-		err error
-	)
+	client := clientPool.Get().(*http.Client)
+	defer clientPool.Put(client)
+
+	// Client is reused, so timeout must be reset
+	client.Timeout = req.opts.Timeout
+	client.Transport = transport
 
 	sTime := getTimestampMs()
 
-	switch req.opts.Method {
-	case MethodGet:
-		rsp, err = req.get(client)
-	case MethodPost:
-		rsp, err = req.post(client)
-	default:
-		err = errors.New("unsupported method")
-	}
+	rsp, err := client.Do(request)
 
 	req.Elapsed = getTimestampMs() - sTime
 
@@ -309,10 +361,10 @@ func (req *Request) Do() ([]byte, error) {
 
 	defer func() {
 		_ = rsp.Body.Close()
-
-		clientPool.Put(client)
 	}()
 
+	req.Status = rsp.StatusCode
+
 	return ioutil.ReadAll(rsp.Body)
 }
 
@@ -350,14 +402,43 @@ func (req *Request) SetBody(body []byte) {
 }
 
 func (req *Request) SetMethod(method string) {
-	switch method {
-	case "POST":
-		req.opts.Method = MethodPost
-	case "GET":
-		req.opts.Method = MethodGet
+	if value := ParseMethod(method); value != MethodNone {
+		req.opts.Method = value
 	}
 }
 
 func (req *Request) SetTimeout(ms int64) {
 	req.opts.Timeout = time.Duration(ms) * time.Millisecond
 }
+
+func (req *Request) SetTag(tag string) {
+	req.opts.Tag = strings.TrimSpace(tag)
+}
+
+func (req *Request) GetTag() string {
+	return req.opts.Tag
+}
+
+func (req *Request) GetURL() string {
+	return req.opts.URL
+}
+
+func (req *Request) GetMethod() string {
+	return MethodName(req.opts.Method)
+}
+
+func (req *Request) GetHeaders() map[string]string {
+	return req.opts.Headers
+}
+
+func (req *Request) GetParams() map[string]string {
+	return req.opts.Params
+}
+
+func (req *Request) GetBody() []byte {
+	return req.opts.Body
+}
+
+func (req *Request) GetTimeout() time.Duration {
+	return req.opts.Timeout
+}
@@ -0,0 +1,291 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+type echoResult struct {
+	Method string              `json:"method"`
+	Query  map[string][]string `json:"query"`
+	Header http.Header         `json:"header"`
+	Body   string              `json:"body"`
+}
+
+func newEchoServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		_ = json.NewEncoder(w).Encode(&echoResult{
+			Method: r.Method,
+			Query:  r.URL.Query(),
+			Header: r.Header,
+			Body:   string(body),
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func doEcho(t *testing.T, opts ...Option) *echoResult {
+	t.Helper()
+
+	req := NewRequest(opts...)
+
+	body, err := req.Do()
+	if err != nil {
+		t.Fatalf("Do() failed: %v", err)
+	}
+
+	if req.GetLastStatus() != http.StatusOK {
+		t.Fatalf("status = %d, want 200", req.GetLastStatus())
+	}
+
+	result := &echoResult{}
+	if err := json.Unmarshal(body, result); err != nil {
+		t.Fatalf("invalid echo response %q: %v", body, err)
+	}
+
+	return result
+}
+
+func TestRequestMethods(t *testing.T) {
+	server := newEchoServer(t)
+
+	for method, name := range methodNames {
+		if method == MethodHead {
+			req := NewRequest(URLOption(server.URL), MethodOption(method))
+			if body, err := req.Do(); err != nil || len(body) > 0 || req.GetLastStatus() != http.StatusOK {
+				t.Errorf("HEAD = (%q, %v, %d), want empty 200", body, err, req.GetLastStatus())
+			}
+			continue
+		}
+
+		result := doEcho(t, URLOption(server.URL), MethodOption(method), ParamOption("id", "1"))
+
+		if result.Method != name {
+			t.Errorf("method = %s, want %s", result.Method, name)
+		}
+
+		switch method {
+		case MethodPost, MethodPut, MethodPatch:
+			if result.Body != "id=1" {
+				t.Errorf("%s body = %q, want %q", name, result.Body, "id=1")
+			}
+		default:
+			if got := result.Query["id"]; len(got) != 1 || got[0] != "1" {
+				t.Errorf("%s query id = %v, want [1]", name, got)
+			}
+		}
+	}
+}
+
+func TestRequestUnknownMethod(t *testing.T) {
+	server := newEchoServer(t)
+
+	result := doEcho(t, URLOption(server.URL), MethodOption(MethodNone))
+	if result.Method != "GET" {
+		t.Errorf("method = %s, want GET", result.Method)
+	}
+}
+
+func TestRequestEncodeParams(t *testing.T) {
+	server := newEchoServer(t)
+
+	params := map[string]string{
+		"q":       "a b&c=d",
+		"name":    "中文",
+		"a+b":     "1+1",
+		"percent": "100%",
+	}
+
+	result := doEcho(t, URLOption(server.URL+"/search?page=2"), ParamsOption(params))
+
+	for field, value := range params {
+		if got := result.Query[field]; len(got) != 1 || got[0] != value {
+			t.Errorf("query %s = %v, want [%s]", field, got, value)
+		}
+	}
+
+	if got := result.Query["page"]; len(got) != 1 || got[0] != "2" {
+		t.Errorf("existing query page = %v, want [2]", got)
+	}
+
+	req := NewRequest(ParamsOption(map[string]string{"b": "2", "a": "1 1"}))
+	if uri := req.encodeURI(); uri != "a=1+1&b=2" {
+		t.Errorf("encodeURI() = %q, want %q", uri, "a=1+1&b=2")
+	}
+}
+
+func TestRequestPostBody(t *testing.T) {
+	server := newEchoServer(t)
+
+	result := doEcho(t, URLOption(server.URL), MethodOption(MethodPost),
+		ParamsOption(map[string]string{"msg": "a&b"}))
+
+	values, err := url.ParseQuery(result.Body)
+	if err != nil || values.Get("msg") != "a&b" {
+		t.Errorf("form body = %q, want msg=a&b", result.Body)
+	}
+
+	result = doEcho(t, URLOption(server.URL), MethodOption(MethodPost),
+		HeaderOption("Content-Type", "application/json"), ParamOption("id", "1"))
+
+	if result.Body != `{"id":"1"}` {
+		t.Errorf("json body = %q, want %q", result.Body, `{"id":"1"}`)
+	}
+
+	result = doEcho(t, URLOption(server.URL), MethodOption(MethodPut),
+		BodyOption([]byte("raw")), ParamOption("id", "1"))
+
+	if result.Body != "raw" {
+		t.Errorf("raw body = %q, want %q", result.Body, "raw")
+	}
+}
+
+func TestRequestHeaders(t *testing.T) {
+	server := newEchoServer(t)
+
+	result := doEcho(t, URLOption(server.URL),
+		HeadersOption(map[string]string{"X-Token": "abc"}), HeaderOption("X-Id", "1"))
+
+	if got := result.Header.Get("X-Token"); got != "abc" {
+		t.Errorf("header X-Token = %q, want abc", got)
+	}
+
+	if got := result.Header.Get("X-Id"); got != "1" {
+		t.Errorf("header X-Id = %q, want 1", got)
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	req := NewRequest(URLOption(server.URL), TimeoutOption(50*time.Millisecond))
+	if _, err := req.Do(); err == nil {
+		t.Fatal("Do() should fail when timeout")
+	}
+
+	// Pooled client must not keep timeout of previous request
+	req = NewRequest(URLOption(server.URL), TimeoutOption(0))
+	if _, err := req.Do(); err != nil {
+		t.Fatalf("Do() without timeout failed: %v", err)
+	}
+}
+
+func TestRequestStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer server.Close()
+
+	req := NewRequest(URLOption(server.URL))
+	if _, err := req.Do(); err != nil {
+		t.Fatalf("Do() failed: %v", err)
+	}
+
+	if req.GetLastStatus() != http.StatusTeapot {
+		t.Errorf("status = %d, want %d", req.GetLastStatus(), http.StatusTeapot)
+	}
+
+	req = NewRequest()
+	if _, err := req.Do(); err == nil {
+		t.Error("Do() should fail without URL")
+	}
+}
+
+func TestRequestSetters(t *testing.T) {
+	req := NewRequest()
+
+	req.SetURL(" example.com/path ")
+	req.SetMethod("post")
+	req.SetMethod("unknown")
+	req.SetTimeout(1500)
+	req.SetTag(" api ")
+
+	if req.opts.URL != "http://example.com/path" {
+		t.Errorf("URL = %q", req.opts.URL)
+	}
+
+	if req.opts.Method != MethodPost {
+		t.Errorf("method = %d, want %d", req.opts.Method, MethodPost)
+	}
+
+	if req.opts.Timeout != 1500*time.Millisecond {
+		t.Errorf("timeout = %v", req.opts.Timeout)
+	}
+
+	if req.GetTag() != "api" {
+		t.Errorf("tag = %q, want api", req.GetTag())
+	}
+}
+
+func TestHasScheme(t *testing.T) {
+	tests := map[string]bool{
+		"http://a":             true,
+		"HTTPS://a":            true,
+		"http://":              false,
+		"ftp://a":              true,
+		"redis://127.0.0.1":    true,
+		"unix:///run/app.sock": true,
+		"example.com":          false,
+		"https:/a.com":         false,
+		"1http://a":            false,
+		"/path?u=http://a":     false,
+	}
+
+	for link, want := range tests {
+		if got := HasScheme(link); got != want {
+			t.Errorf("HasScheme(%q) = %v, want %v", link, got, want)
+		}
+	}
+}
+
+func TestParseMethod(t *testing.T) {
+	for method, name := range methodNames {
+		if got := ParseMethod(name); got != method {
+			t.Errorf("ParseMethod(%q) = %d, want %d", name, got, method)
+		}
+		if MethodName(method) != name {
+			t.Errorf("MethodName(%d) = %q, want %q", method, MethodName(method), name)
+		}
+	}
+
+	if got := ParseMethod("patch"); got != MethodPatch {
+		t.Errorf("ParseMethod(patch) = %d, want %d", got, MethodPatch)
+	}
+
+	if got := ParseMethod("TRACE"); got != MethodNone {
+		t.Errorf("ParseMethod(TRACE) = %d, want MethodNone", got)
+	}
+}
+
+func TestCaseCompare(t *testing.T) {
+	tests := []struct {
+		src, dst string
+		sign     int
+	}{
+		{"Content-Type", "content-type", 0},
+		{"abc", "abd", -1},
+		{"b", "A", 1},
+		{"ab", "abc", -1},
+	}
+
+	for _, test := range tests {
+		got := CaseCompare(test.src, test.dst)
+		if (got > 0 && test.sign <= 0) || (got < 0 && test.sign >= 0) || (got == 0 && test.sign != 0) {
+			t.Errorf("CaseCompare(%q, %q) = %d, want sign %d", test.src, test.dst, got, test.sign)
+		}
+	}
+}
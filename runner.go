@@ -0,0 +1,398 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Runner sends requests of config concurrently and collects stats
+type Runner struct {
+	config     *Config
+	items      []*BenchmarkItem
+	sequential bool
+	feeder     *Feeder
+	script     *Script
+	log        *Log
+
+	// Stop dispatching of running benchmark, requests dropped
+	// because rows of data file are used up are counted
+	stop    context.CancelFunc
+	dropped int64
+
+	// Executors of URL schemes, created when first used
+	executorLock sync.Mutex
+	executors    map[string]Executor
+}
+
+// Create runner, request items, data file and script are loaded
+// @param config: benchmark config, should not be changed after created
+func NewRunner(config *Config) (*Runner, error) {
+	if config.Connections <= 0 {
+		return nil, errors.New("connections must be greater than 0")
+	}
+
+	if config.Requests < 0 {
+		return nil, errors.New("requests cannot be negative")
+	}
+
+	runner := &Runner{
+		config:    config,
+		executors: make(map[string]Executor),
+	}
+
+	if err := runner.load(); err != nil {
+		runner.Close()
+		return nil, err
+	}
+
+	return runner, nil
+}
+
+// Open log file, load items, data file and script of config
+func (r *Runner) load() error {
+	config := r.config
+
+	// Each runner has its own log, so runners of library do not
+	// overwrite logs of each other
+	if len(config.Log) > 0 {
+		logger, err := NewLog(config.Log, DebugLevel)
+		if err != nil {
+			return err
+		}
+
+		r.log = logger
+	}
+
+	if err := r.loadItems(); err != nil {
+		return err
+	}
+
+	for _, item := range r.items {
+		if err := item.Compile(); err != nil {
+			return err
+		}
+
+		// Unsupported protocols should fail before benchmark
+		if _, err := r.executor(item.URL); err != nil {
+			return err
+		}
+	}
+
+	if len(config.Data) > 0 {
+		mode, err := ParseFeedMode(config.DataMode)
+		if err != nil {
+			return err
+		}
+
+		r.feeder, err = NewFeeder(config.Data, mode, config.DataOnce, config.Connections)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(config.Script) > 0 {
+		script, err := LoadScript(config.Script)
+		if err != nil {
+			return err
+		}
+
+		r.script = script
+	}
+
+	return nil
+}
+
+// Load benchmark items from the first source of config
+func (r *Runner) loadItems() error {
+	config := r.config
+
+	switch {
+	case len(config.Items) > 0:
+		r.items = r.mergeDefaults(config.Items)
+		r.sequential = config.Sequential
+
+	case len(config.Curl) > 0:
+		item, err := ParseCurl(config.Curl)
+		if err != nil {
+			return err
+		}
+
+		r.items = r.mergeDefaults([]*BenchmarkItem{item})
+
+	case len(config.AccessLog) > 0:
+		items, err := loadAccessLog(config.AccessLog, config.AccessLogFormat, config.Target, r.log)
+		if err != nil {
+			return err
+		}
+
+		r.items = r.mergeDefaults(items)
+		r.sequential = true
+
+	case len(config.HAR) > 0:
+		items, err := LoadHAR(config.HAR, config.HAROptions)
+		if err != nil {
+			return err
+		}
+
+		r.items = r.mergeDefaults(items)
+		r.sequential = !config.HAROptions.Mix
+
+	case len(config.OpenAPI) > 0:
+		items, err := LoadOpenAPI(config.OpenAPI, config.Target, config.OpenAPIOperations)
+		if err != nil {
+			return err
+		}
+
+		// Only common headers are applied (etc: Authorization)
+		for _, item := range items {
+			item.Headers = mergeStringMap(config.Headers, item.Headers)
+		}
+
+		r.items = items
+
+	case len(config.scenarioRequests) > 0 && len(config.Endpoints) == 0:
+		items, err := buildEndpoints(config.scenarioRequests, config.Target, config.scenarioPath)
+		if err != nil {
+			return err
+		}
+
+		r.items = r.mergeDefaults(items)
+
+	case len(config.Endpoints) > 0:
+		items, err := LoadEndpoints(config.Endpoints, config.Target)
+		if err != nil {
+			return err
+		}
+
+		r.items = r.mergeDefaults(items)
+
+	case len(config.Redis.Commands) > 0:
+		items, err := BuildRedisItems(config.Target, config.Redis)
+		if err != nil {
+			return err
+		}
+
+		r.items = items
+
+	case len(config.Memcache.Commands) > 0:
+		items, err := BuildMemcacheItems(config.Target, config.Memcache)
+		if err != nil {
+			return err
+		}
+
+		r.items = items
+
+	default:
+		if len(config.Target) == 0 {
+			return errors.New("testing target URL has not set")
+		}
+
+		r.items = []*BenchmarkItem{{
+			URL:     config.Target,
+			Headers: config.Headers,
+			Params:  config.Params,
+			Method:  config.Method,
+			Body:    config.Body,
+		}}
+	}
+
+	return nil
+}
+
+// Get copies of items whose unset fields are filled with default
+// request options, items of config are not changed
+func (r *Runner) mergeDefaults(items []*BenchmarkItem) []*BenchmarkItem {
+	merged := make([]*BenchmarkItem, 0, len(items))
+
+	for _, item := range items {
+		item := *item
+
+		item.Headers = mergeStringMap(r.config.Headers, item.Headers)
+		item.Params = mergeStringMap(r.config.Params, item.Params)
+		if len(item.Method) == 0 {
+			item.Method = r.config.Method
+		}
+		if item.Body == nil {
+			item.Body = r.config.Body
+		}
+
+		merged = append(merged, &item)
+	}
+
+	return merged
+}
+
+// Merge two string maps, values in override map take precedence
+func mergeStringMap(base, override map[string]string) map[string]string {
+	merged := make(map[string]string, len(base)+len(override))
+
+	for field, value := range base {
+		merged[field] = value
+	}
+
+	for field, value := range override {
+		merged[field] = value
+	}
+
+	return merged
+}
+
+// Run benchmark until all requests are sent or context is done,
+// requests which have been sent are waited for
+// @param ctx: context to stop benchmark early
+func (r *Runner) Run(ctx context.Context) (*Result, error) {
+	var picker Picker = NewItemPicker(r.items)
+	if r.sequential {
+		picker = NewSequencePicker(r.items, r.config.KeepTiming, r.config.Speed)
+	}
+
+	// Default is one pass of sequence or one request
+	total := r.config.Requests
+	if total <= 0 {
+		total = 1
+		if r.sequential {
+			total = len(r.items)
+		}
+	}
+
+	connections := r.config.Connections
+
+	// Dispatching is stopped when rows of data file are used up
+	parent := ctx
+	ctx, r.stop = context.WithCancel(parent)
+	defer r.stop()
+
+	atomic.StoreInt64(&r.dropped, 0)
+
+	group := &sync.WaitGroup{}
+	stats := NewStats()
+	pool := NewGoPool(connections)
+	start := time.Now()
+
+	defer pool.Stop()
+
+	var (
+		done     = make(chan struct{})
+		progress sync.WaitGroup
+	)
+
+	if hook := r.config.Hooks.OnProgress; hook != nil && r.config.ProgressInterval > 0 {
+		ticker := time.NewTicker(r.config.ProgressInterval)
+		progress.Add(1)
+		go func() {
+			defer progress.Done()
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					stats.SetDuration(time.Since(start))
+					hook(stats.Snapshot())
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+
+	var (
+		err        error
+		dispatched int
+	)
+
+dispatch:
+	for ; dispatched < total; dispatched++ {
+		simple, offset := picker.Pick()
+
+		if offset > 0 {
+			if wait := time.Until(start.Add(offset)); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+					timer.Stop()
+					err = ctx.Err()
+					break dispatch
+				}
+			}
+		}
+
+		if ctx.Err() != nil {
+			err = ctx.Err()
+			break
+		}
+
+		group.Add(1)
+		pool.DoWorker(r.benchmark, NewBenchmarkArgs(ctx, simple, group, stats))
+	}
+
+	group.Wait()
+
+	close(done)
+	progress.Wait()
+
+	stats.SetDuration(time.Since(start))
+
+	result := &Result{Connections: connections, Stats: stats}
+
+	// Stopped because data is used up instead of parent context
+	if err != nil && parent.Err() == nil {
+		err = nil
+		result.Dropped = int64(total - dispatched)
+	}
+
+	result.Dropped += atomic.LoadInt64(&r.dropped)
+
+	if result.Dropped > 0 {
+		r.log.Errorf("%d requests are dropped, rows of data file are used up", result.Dropped)
+	}
+
+	return result, err
+}
+
+// Get executor of URL scheme, create it if not exists
+func (r *Runner) executor(link string) (Executor, error) {
+	scheme := URLScheme(link)
+
+	r.executorLock.Lock()
+	defer r.executorLock.Unlock()
+
+	executor, exists := r.executors[scheme]
+	if !exists {
+		var err error
+
+		executor, err = NewExecutor(scheme, r.config)
+		if err != nil {
+			return nil, err
+		}
+
+		r.executors[scheme] = executor
+	}
+
+	return executor, nil
+}
+
+// Release resources of runner (etc: Lua state, connections)
+func (r *Runner) Close() {
+	if r.script != nil {
+		r.script.Close()
+	}
+
+	r.executorLock.Lock()
+	for scheme, executor := range r.executors {
+		if err := executor.Close(); err != nil {
+			r.log.Errorf("Close %s executor failed: %s", scheme, err.Error())
+		}
+	}
+	r.executors = make(map[string]Executor)
+	r.executorLock.Unlock()
+
+	_ = r.log.Close()
+	r.log = nil
+}
@@ -0,0 +1,279 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Run benchmark of config, the runner is closed before returned
+func runBenchmark(t *testing.T, config *Config) *Result {
+	t.Helper()
+
+	runner, err := NewRunner(config)
+	if err != nil {
+		t.Fatalf("NewRunner() failed: %v", err)
+	}
+	defer runner.Close()
+
+	result, err := runner.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+
+	return result
+}
+
+func TestRunnerTarget(t *testing.T) {
+	server := httptest.NewServer(NewMockServer(MockOptions{}))
+	defer server.Close()
+
+	config := NewConfig()
+	config.Target = server.URL + "/?size=10"
+	config.Connections = 4
+	config.Requests = 20
+
+	result := runBenchmark(t, config)
+
+	snapshot := result.Stats.Snapshot()
+
+	if snapshot.Total != 20 || snapshot.Success != 20 || snapshot.RecvBytes != 200 {
+		t.Errorf("total = %d, success = %d, bytes = %d", snapshot.Total, snapshot.Success, snapshot.RecvBytes)
+	}
+
+	if snapshot.Status["200"] != 20 || snapshot.Duration <= 0 {
+		t.Errorf("status = %v, duration = %v", snapshot.Status, snapshot.Duration)
+	}
+
+	report := result.Report("target")
+	if report.Connections != 4 || report.Summary.Total != 20 {
+		t.Errorf("report connections = %d, total = %d", report.Connections, report.Summary.Total)
+	}
+}
+
+func TestRunnerHooks(t *testing.T) {
+	server := newEchoServer(t)
+
+	var sent, checked int64
+
+	config := NewConfig()
+	config.Items = []*BenchmarkItem{{Name: "echo", URL: server.URL}}
+	config.Requests = 30
+	config.Hooks.BeforeRequest = func(req *Request, row map[string]string) bool {
+		if atomic.AddInt64(&sent, 1)%3 == 0 {
+			return false
+		}
+		req.SetHeader("X-Hook", req.GetURL())
+		return true
+	}
+	config.Hooks.AfterResponse = func(req *Request, rsp *Response) bool {
+		atomic.AddInt64(&checked, 1)
+		return strings.Contains(string(rsp.Body), "X-Hook")
+	}
+
+	snapshot := runBenchmark(t, config).Stats.Snapshot()
+
+	// Skipped requests are not counted
+	if sent != 30 || checked != 20 || snapshot.Total != 20 || snapshot.Success != 20 {
+		t.Errorf("sent = %d, checked = %d, total = %d, success = %d",
+			sent, checked, snapshot.Total, snapshot.Success)
+	}
+
+	if group, exists := snapshot.Groups["echo"]; !exists || group.Success != 20 {
+		t.Errorf("group echo = %+v", group)
+	}
+}
+
+func TestRunnerCancel(t *testing.T) {
+	server := httptest.NewServer(NewMockServer(MockOptions{}))
+	defer server.Close()
+
+	var progress int64
+
+	config := NewConfig()
+	config.Target = server.URL + "/?latency=20ms"
+	config.Connections = 2
+	config.Requests = 1000000
+	config.ProgressInterval = 20 * time.Millisecond
+	config.Hooks.OnProgress = func(snapshot Snapshot) {
+		atomic.AddInt64(&progress, 1)
+	}
+
+	runner, err := NewRunner(config)
+	if err != nil {
+		t.Fatalf("NewRunner() failed: %v", err)
+	}
+	defer runner.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	result, err := runner.Run(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Run() error = %v, want deadline exceeded", err)
+	}
+
+	snapshot := result.Stats.Snapshot()
+
+	if snapshot.Total == 0 || snapshot.Total >= 1000 {
+		t.Errorf("total = %d, want stopped early", snapshot.Total)
+	}
+
+	if atomic.LoadInt64(&progress) == 0 {
+		t.Error("OnProgress() has not been called")
+	}
+}
+
+func TestRunnerDataOnce(t *testing.T) {
+	server := httptest.NewServer(NewMockServer(MockOptions{}))
+	defer server.Close()
+
+	config := NewConfig()
+	config.Target = server.URL + "/?id={{.id}}"
+	config.Connections = 2
+	config.Requests = 1000000
+	config.Data = writeTestData(t, "ids.csv", []string{"id", "1", "2", "3"})
+	config.DataOnce = true
+
+	start := time.Now()
+
+	result := runBenchmark(t, config)
+
+	// Dispatching is stopped after rows are used up
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("benchmark is not stopped in %v", elapsed)
+	}
+
+	if snapshot := result.Stats.Snapshot(); snapshot.Success != 3 || snapshot.Total != 3 {
+		t.Errorf("success = %d, total = %d, want 3", snapshot.Success, snapshot.Total)
+	}
+
+	if result.Dropped != 1000000-3 {
+		t.Errorf("dropped = %d, want %d", result.Dropped, 1000000-3)
+	}
+
+	var text bytes.Buffer
+	result.WriteText(&text)
+
+	if !strings.Contains(text.String(), "Dropped Requests: 999997") {
+		t.Errorf("text = %s", text.String())
+	}
+}
+
+func TestRunnerInvalidConfig(t *testing.T) {
+	config := NewConfig()
+
+	if _, err := NewRunner(config); err == nil {
+		t.Error("NewRunner() should fail without target")
+	}
+
+	config.Target = "http://127.0.0.1"
+	config.Connections = 0
+
+	if _, err := NewRunner(config); err == nil {
+		t.Error("NewRunner() should fail without connections")
+	}
+}
+
+func TestRunnerLog(t *testing.T) {
+	server := httptest.NewServer(NewMockServer(MockOptions{}))
+	defer server.Close()
+
+	dir := t.TempDir()
+
+	// Runners write their own log files, the items of config are not changed
+	var runners []*Runner
+
+	for _, size := range []string{"1", "2"} {
+		config := NewConfig()
+		config.Log = filepath.Join(dir, size+".log")
+		config.Items = []*BenchmarkItem{{URL: server.URL + "/?size=" + size, ExpectBody: []byte("-")}}
+		config.Headers = map[string]string{"X-Size": size}
+
+		runner, err := NewRunner(config)
+		if err != nil {
+			t.Fatalf("NewRunner() failed: %v", err)
+		}
+
+		if item := config.Items[0]; item.Headers != nil || len(item.Method) > 0 || item.template != nil {
+			t.Errorf("item of config is changed: %+v", item)
+		}
+
+		runners = append(runners, runner)
+	}
+
+	for _, runner := range runners {
+		if _, err := runner.Run(context.Background()); err != nil {
+			t.Fatalf("Run() failed: %v", err)
+		}
+		runner.Close()
+	}
+
+	for _, size := range []string{"1", "2"} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, size+".log"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if lines := strings.Count(string(data), "\n"); lines != 1 || !strings.Contains(string(data), "size="+size) {
+			t.Errorf("log of %s = %q", size, data)
+		}
+	}
+
+	if runners[0].log != nil {
+		t.Error("log is not closed by Close()")
+	}
+}
+
+func TestRunnerImportDefaults(t *testing.T) {
+	server := newEchoServer(t)
+
+	dir := t.TempDir()
+
+	accessLog := filepath.Join(dir, "access.log")
+	if err := ioutil.WriteFile(accessLog, []byte(`127.0.0.1 - - [10/Oct/2000:13:55:36 +0000] "GET /a HTTP/1.1" 200 1`+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	har := filepath.Join(dir, "page.har")
+	if err := ioutil.WriteFile(har, []byte(`{"log": {"entries": [{"startedDateTime": "2020-01-01T00:00:00Z",
+		"request": {"method": "GET", "url": "`+server.URL+`/b", "headers": []}}]}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Headers and params of config are sent with imported requests
+	for _, source := range []string{"access log", "HAR"} {
+		var checked int64
+
+		config := NewConfig()
+		config.Requests = 2
+		config.Headers = map[string]string{"X-Token": "t1"}
+		config.Params = map[string]string{"page": "2"}
+		config.Hooks.AfterResponse = func(req *Request, rsp *Response) bool {
+			atomic.AddInt64(&checked, 1)
+			return strings.Contains(string(rsp.Body), `"X-Token":["t1"]`) && strings.Contains(string(rsp.Body), `"page":["2"]`)
+		}
+
+		if source == "HAR" {
+			config.HAR = har
+		} else {
+			config.AccessLog = accessLog
+			config.Target = server.URL
+		}
+
+		if snapshot := runBenchmark(t, config).Stats.Snapshot(); checked != 2 || snapshot.Success != 2 {
+			t.Errorf("%s: checked = %d, success = %d", source, checked, snapshot.Success)
+		}
+	}
+}
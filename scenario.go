@@ -0,0 +1,266 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type ScenarioLoad struct {
+	Connections int  `yaml:"connections"`
+	Requests    int  `yaml:"requests"`
+	GroupByPath bool `yaml:"group_by_path"`
+}
+
+type ScenarioData struct {
+	File string `yaml:"file"`
+	Mode string `yaml:"mode"`
+	Once bool   `yaml:"once"`
+}
+
+type ScenarioThresholds struct {
+	SuccessRate float64          `yaml:"success_rate"`
+	AvgTime     int64            `yaml:"avg_time"`
+	MaxTime     int64            `yaml:"max_time"`
+	Percentiles map[string]int64 `yaml:"percentiles"`
+}
+
+type ScenarioOutput struct {
+	Format string `yaml:"format"`
+	Path   string `yaml:"path"`
+}
+
+type Scenario struct {
+	Name       string             `yaml:"name"`
+	Target     string             `yaml:"target"`
+	Script     string             `yaml:"script"`
+	Log        string             `yaml:"log"`
+	Method     string             `yaml:"method"`
+	Headers    map[string]string  `yaml:"headers"`
+	Params     map[string]string  `yaml:"params"`
+	Body       string             `yaml:"body"`
+	Requests   []endpointConfig   `yaml:"requests"`
+	Load       ScenarioLoad       `yaml:"load"`
+	Data       *ScenarioData      `yaml:"data"`
+	Thresholds ScenarioThresholds `yaml:"thresholds"`
+	Outputs    []ScenarioOutput   `yaml:"outputs"`
+
+	path string
+}
+
+// Load scenario from YAML or JSON file, relative script, data file and
+// output paths are resolved against the scenario file directory
+// @param path: scenario file path
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	scenario := &Scenario{path: path}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	if err := decoder.Decode(scenario); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, strings.TrimPrefix(err.Error(), "yaml: "))
+	}
+
+	if err := scenario.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err.Error())
+	}
+
+	dir := filepath.Dir(path)
+
+	if len(scenario.Script) > 0 && !filepath.IsAbs(scenario.Script) {
+		scenario.Script = filepath.Join(dir, scenario.Script)
+	}
+
+	if scenario.Data != nil && !filepath.IsAbs(scenario.Data.File) {
+		scenario.Data.File = filepath.Join(dir, scenario.Data.File)
+	}
+
+	for i, output := range scenario.Outputs {
+		if len(output.Path) > 0 && !filepath.IsAbs(output.Path) {
+			scenario.Outputs[i].Path = filepath.Join(dir, output.Path)
+		}
+	}
+
+	return scenario, nil
+}
+
+func validMethod(method string) bool {
+	return len(method) == 0 || ParseMethod(method) != MethodNone
+}
+
+func (s *Scenario) Validate() error {
+	if len(s.Target) == 0 && len(s.Requests) == 0 {
+		return fmt.Errorf("target: is required when no requests defined")
+	}
+
+	if !validMethod(s.Method) {
+		return fmt.Errorf("method: unsupported method %q", s.Method)
+	}
+
+	for i, request := range s.Requests {
+		if len(request.URL) == 0 {
+			return fmt.Errorf("requests[%d].url: is required", i)
+		}
+		if strings.HasPrefix(request.URL, "/") && len(s.Target) == 0 {
+			return fmt.Errorf("requests[%d].url: relative URL requires target", i)
+		}
+		if !validMethod(request.Method) {
+			return fmt.Errorf("requests[%d].method: unsupported method %q", i, request.Method)
+		}
+		if request.Weight < 0 {
+			return fmt.Errorf("requests[%d].weight: cannot be negative", i)
+		}
+	}
+
+	if s.Load.Connections < 0 {
+		return fmt.Errorf("load.connections: cannot be negative")
+	}
+
+	if s.Load.Requests < 0 {
+		return fmt.Errorf("load.requests: cannot be negative")
+	}
+
+	if s.Data != nil {
+		if len(s.Data.File) == 0 {
+			return fmt.Errorf("data.file: is required")
+		}
+		if _, err := ParseFeedMode(s.Data.Mode); err != nil {
+			return fmt.Errorf("data.mode: %s", err.Error())
+		}
+	}
+
+	if s.Thresholds.SuccessRate < 0 || s.Thresholds.SuccessRate > 100 {
+		return fmt.Errorf("thresholds.success_rate: must be between 0 and 100")
+	}
+
+	for name := range s.Thresholds.Percentiles {
+		found := false
+		for _, percent := range Percentiles {
+			if PercentileName(percent) == name {
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("thresholds.percentiles.%s: unknown percentile", name)
+		}
+	}
+
+	for i, output := range s.Outputs {
+		switch output.Format {
+		case "", "text", "json":
+		default:
+			return fmt.Errorf("outputs[%d].format: unknown format %q", i, output.Format)
+		}
+	}
+
+	return nil
+}
+
+// Apply scenario settings to config,
+// command line options should be parsed after this
+func (s *Scenario) Apply(config *Config) {
+	if len(s.Target) > 0 {
+		config.Target = s.Target
+		if !strings.Contains(config.Target, "://") {
+			config.Target = "http://" + config.Target
+		}
+	}
+
+	if len(s.Script) > 0 {
+		config.Script = s.Script
+	}
+
+	if len(s.Log) > 0 {
+		config.Log = s.Log
+	}
+
+	if len(s.Method) > 0 {
+		config.Method = strings.ToUpper(s.Method)
+	}
+
+	if s.Headers != nil {
+		config.Headers = s.Headers
+	}
+
+	if s.Params != nil {
+		config.Params = s.Params
+	}
+
+	if len(s.Body) > 0 {
+		config.Body = []byte(s.Body)
+	}
+
+	if s.Load.Connections > 0 {
+		config.Connections = s.Load.Connections
+	}
+
+	if s.Load.Requests > 0 {
+		config.Requests = s.Load.Requests
+	}
+
+	if s.Load.GroupByPath {
+		config.GroupByPath = true
+	}
+
+	if s.Data != nil {
+		config.Data = s.Data.File
+		config.DataMode = s.Data.Mode
+		config.DataOnce = s.Data.Once
+	}
+
+	config.scenarioRequests = s.Requests
+	config.scenarioPath = s.path
+}
+
+// Check benchmark report against thresholds
+// @return: descriptions of failed thresholds
+func (t *ScenarioThresholds) Check(report *Report) []string {
+	var failures []string
+
+	summary := report.Summary
+
+	if t.SuccessRate > 0 && summary.SuccessRate < t.SuccessRate {
+		failures = append(failures, fmt.Sprintf("success rate %0.2f%% is lower than %0.2f%%",
+			summary.SuccessRate, t.SuccessRate))
+	}
+
+	if t.AvgTime > 0 && summary.AvgTime > t.AvgTime {
+		failures = append(failures, fmt.Sprintf("average request time %d(MS) is greater than %d(MS)",
+			summary.AvgTime, t.AvgTime))
+	}
+
+	if t.MaxTime > 0 && summary.MaxTime > t.MaxTime {
+		failures = append(failures, fmt.Sprintf("slowest request time %d(MS) is greater than %d(MS)",
+			summary.MaxTime, t.MaxTime))
+	}
+
+	var names []string
+	for name := range t.Percentiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		limit := t.Percentiles[name]
+		if elapsed := summary.Percentiles[name]; limit > 0 && elapsed > limit {
+			failures = append(failures, fmt.Sprintf("%s request time %d(MS) is greater than %d(MS)",
+				name, elapsed, limit))
+		}
+	}
+
+	return failures
+}
@@ -0,0 +1,143 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestScenario(t *testing.T, text string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "scenario.yaml")
+	if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoadScenario(t *testing.T) {
+	path := writeTestScenario(t, `
+name: items
+target: 127.0.0.1:8080
+method: post
+script: script.lua
+requests:
+  - name: list
+    url: /items
+    weight: 3
+load:
+  connections: 10
+  requests: 100
+data:
+  file: data/users.csv
+  mode: partition
+thresholds:
+  success_rate: 99
+  percentiles:
+    p99: 500
+outputs:
+  - format: json
+    path: result.json
+  - format: text
+    path: /tmp/result.txt
+  - format: text
+`)
+
+	scenario, err := LoadScenario(path)
+	if err != nil {
+		t.Fatalf("LoadScenario() failed: %v", err)
+	}
+
+	dir := filepath.Dir(path)
+
+	if scenario.Script != filepath.Join(dir, "script.lua") || scenario.Data.File != filepath.Join(dir, "data/users.csv") {
+		t.Errorf("script = %q, data = %q", scenario.Script, scenario.Data.File)
+	}
+
+	// Outputs are written next to scenario file, not working directory
+	outputs := []string{filepath.Join(dir, "result.json"), "/tmp/result.txt", ""}
+	for i, output := range scenario.Outputs {
+		if output.Path != outputs[i] {
+			t.Errorf("outputs[%d].path = %q, want %q", i, output.Path, outputs[i])
+		}
+	}
+
+	config := NewConfig()
+	scenario.Apply(config)
+
+	if config.Target != "http://127.0.0.1:8080" || config.Method != "POST" || config.Connections != 10 || config.Requests != 100 {
+		t.Errorf("config = %+v", config)
+	}
+
+	if config.DataMode != "partition" || len(config.scenarioRequests) != 1 {
+		t.Errorf("data mode = %q, requests = %v", config.DataMode, config.scenarioRequests)
+	}
+}
+
+func TestScenarioValidate(t *testing.T) {
+	tests := []struct {
+		text string
+		err  string
+	}{
+		{"name: x", "target: is required"},
+		{"target: a\nunknown: 1", "field unknown not found"},
+		{"target: a\nmethod: fetch", "method: unsupported method"},
+		{"requests:\n  - url: /a", "requests[0].url: relative URL requires target"},
+		{"target: a\nrequests:\n  - name: a", "requests[0].url: is required"},
+		{"target: a\nrequests:\n  - url: /a\n    weight: -1", "requests[0].weight: cannot be negative"},
+		{"target: a\nload:\n  connections: -1", "load.connections: cannot be negative"},
+		{"target: a\nload:\n  requests: -1", "load.requests: cannot be negative"},
+		{"target: a\ndata:\n  mode: random", "data.file: is required"},
+		{"target: a\ndata:\n  file: a.csv\n  mode: shuffle", "data.mode:"},
+		{"target: a\nthresholds:\n  success_rate: 101", "thresholds.success_rate:"},
+		{"target: a\nthresholds:\n  percentiles:\n    p95: 10", "thresholds.percentiles.p95: unknown percentile"},
+		{"target: a\noutputs:\n  - format: xml", "outputs[0].format: unknown format"},
+	}
+
+	for _, test := range tests {
+		_, err := LoadScenario(writeTestScenario(t, test.text))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("LoadScenario(%q) = %v, want %q", test.text, err, test.err)
+		}
+	}
+}
+
+func TestThresholdsCheck(t *testing.T) {
+	report := &Report{Summary: ReportSummary{
+		SuccessRate: 98.5,
+		AvgTime:     120,
+		MaxTime:     900,
+		Percentiles: map[string]int64{"p90": 300, "p99": 800},
+	}}
+
+	thresholds := &ScenarioThresholds{
+		SuccessRate: 99,
+		AvgTime:     100,
+		MaxTime:     1000,
+		Percentiles: map[string]int64{"p99": 500, "p90": 200, "p50": 100},
+	}
+
+	failures := thresholds.Check(report)
+
+	want := []string{"success rate", "average request time", "p90 request time", "p99 request time"}
+	if len(failures) != len(want) {
+		t.Fatalf("failures = %v, want %d failures", failures, len(want))
+	}
+
+	for i, prefix := range want {
+		if !strings.HasPrefix(failures[i], prefix) {
+			t.Errorf("failures[%d] = %q, want prefix %q", i, failures[i], prefix)
+		}
+	}
+
+	if failures := (&ScenarioThresholds{SuccessRate: 98, AvgTime: 120}).Check(report); len(failures) != 0 {
+		t.Errorf("failures = %v, want none", failures)
+	}
+}
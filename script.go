@@ -2,7 +2,7 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-package main
+package gobenchmark
 
 import (
 	"errors"
@@ -17,14 +17,37 @@ const (
 	reqMeta = "benchmark_req"
 )
 
-var (
-	L         *lua.LState
-	stateLock sync.Mutex
-
-	enableLua bool
+// Script is Lua state of script file, functions of script
+// are called with lock since Lua state is not thread safe
+type Script struct {
+	state *lua.LState
+	lock  sync.Mutex
+	// Whether event() function is defined, it is optional
+	hasEvent bool
+}
 
+var (
 	exports = map[string]lua.LGFunction{
-		"curl": CURL,
+		"curl":          CURL,
+		"json_encode":   JSONEncode,
+		"json_decode":   JSONDecode,
+		"base64_encode": Base64Encode,
+		"base64_decode": Base64Decode,
+		"hex_encode":    HexEncode,
+		"hex_decode":    HexDecode,
+		"url_encode":    URLEncode,
+		"url_decode":    URLDecode,
+		"md5":           scriptMD5,
+		"sha1":          scriptSHA1,
+		"sha256":        scriptSHA256,
+		"hmac_md5":      scriptHMACMD5,
+		"hmac_sha1":     scriptHMACSHA1,
+		"hmac_sha256":   scriptHMACSHA256,
+		"uuid":          UUID,
+		"seed":          Seed,
+		"random":        Random,
+		"now_ms":        NowMs,
+		"sleep":         Sleep,
 	}
 )
 
@@ -34,14 +57,17 @@ func LoadModule(L *lua.LState) int {
 	return 1
 }
 
-func InitScript(script string) error {
-	L = lua.NewState()
+// Load script file and call its init() function
+// @param path: Lua script file path
+func LoadScript(path string) (*Script, error) {
+	L := lua.NewState()
 
 	L.PreloadModule("benchmark", LoadModule)
 
-	err := L.DoFile(script)
+	err := L.DoFile(path)
 	if err != nil {
-		return err
+		L.Close()
+		return nil, err
 	}
 
 	RegisterReqMeta(L)
@@ -54,19 +80,26 @@ func InitScript(script string) error {
 	})
 
 	if err != nil {
-		return err
+		L.Close()
+		return nil, err
 	}
 
 	result := L.Get(-1)
 	L.Pop(1)
 
-	if ok := result.(lua.LBool); !ok {
-		return errors.New("call script init() function return false")
+	if result != lua.LTrue {
+		L.Close()
+		return nil, errors.New("call script init() function return false")
 	}
 
-	enableLua = true
+	return &Script{state: L, hasEvent: L.GetGlobal("event") != lua.LNil}, nil
+}
 
-	return nil
+// Close Lua state of script
+func (s *Script) Close() {
+	s.lock.Lock()
+	s.state.Close()
+	s.lock.Unlock()
 }
 
 // Helper functions:
@@ -95,11 +128,8 @@ func CURL(L *lua.LState) int {
 		target = "http://" + target
 	}
 
-	switch strings.ToUpper(method) {
-	case "GET":
-		methodOpt = MethodGet
-	case "POST":
-		methodOpt = MethodPost
+	if value := ParseMethod(method); value != MethodNone {
+		methodOpt = value
 	}
 
 	headers.ForEach(func(field lua.LValue, value lua.LValue) {
@@ -153,9 +183,11 @@ func checkReq(L *lua.LState) *Request {
 	return nil
 }
 
+// Setters of request, extra arguments are ignored
+// (etc: error result of req:set_body(mark.json_encode(data)))
 func ReqSetHeader(L *lua.LState) int {
 	req := checkReq(L)
-	if L.GetTop() == 3 {
+	if L.GetTop() >= 3 {
 		req.SetHeader(L.CheckString(2), L.CheckString(3))
 	}
 	return 0
@@ -163,7 +195,7 @@ func ReqSetHeader(L *lua.LState) int {
 
 func ReqSetParam(L *lua.LState) int {
 	req := checkReq(L)
-	if L.GetTop() == 3 {
+	if L.GetTop() >= 3 {
 		req.SetParam(L.CheckString(2), L.CheckString(3))
 	}
 	return 0
@@ -171,7 +203,7 @@ func ReqSetParam(L *lua.LState) int {
 
 func ReqSetBody(L *lua.LState) int {
 	req := checkReq(L)
-	if L.GetTop() == 2 {
+	if L.GetTop() >= 2 {
 		req.SetBody([]byte(L.CheckString(2)))
 	}
 	return 0
@@ -179,7 +211,7 @@ func ReqSetBody(L *lua.LState) int {
 
 func ReqSetMethod(L *lua.LState) int {
 	req := checkReq(L)
-	if L.GetTop() == 2 {
+	if L.GetTop() >= 2 {
 		req.SetMethod(L.CheckString(2))
 	}
 	return 0
@@ -187,7 +219,7 @@ func ReqSetMethod(L *lua.LState) int {
 
 func ReqSetURL(L *lua.LState) int {
 	req := checkReq(L)
-	if L.GetTop() == 2 {
+	if L.GetTop() >= 2 {
 		req.SetURL(L.CheckString(2))
 	}
 	return 0
@@ -195,12 +227,20 @@ func ReqSetURL(L *lua.LState) int {
 
 func ReqSetTimeout(L *lua.LState) int {
 	req := checkReq(L)
-	if L.GetTop() == 2 {
+	if L.GetTop() >= 2 {
 		req.SetTimeout(L.CheckInt64(2))
 	}
 	return 0
 }
 
+func ReqSetTag(L *lua.LState) int {
+	req := checkReq(L)
+	if L.GetTop() >= 2 {
+		req.SetTag(L.CheckString(2))
+	}
+	return 0
+}
+
 var reqMethods = map[string]lua.LGFunction{
 	"set_header":  ReqSetHeader,
 	"set_param":   ReqSetParam,
@@ -208,64 +248,104 @@ var reqMethods = map[string]lua.LGFunction{
 	"set_method":  ReqSetMethod,
 	"set_url":     ReqSetURL,
 	"set_timeout": ReqSetTimeout,
+	"set_tag":     ReqSetTag,
 }
 
-func ReqRunScript(req *Request) bool {
-	if !enableLua {
-		return true
+// Call script request() function to modify request
+// @param req: request to be sent
+// @param row: current row of data file, empty if not set
+func (s *Script) Request(req *Request, row map[string]string) bool {
+	result := false
+
+	s.lock.Lock()
+
+	L := s.state
+
+	data := L.NewTable()
+	for field, value := range row {
+		data.RawSetString(field, lua.LString(value))
 	}
 
+	err := L.CallByParam(lua.P{
+		Fn:      L.GetGlobal("request"),
+		NRet:    1,
+		Protect: true,
+		Handler: nil,
+	}, GetReqMeta(L, req), data)
+
+	if err == nil {
+		ret := L.Get(-1)
+		L.Pop(1)
+
+		if ret == lua.LTrue {
+			result = true
+		}
+	}
+
+	s.lock.Unlock()
+
+	return result
+}
+
+// Call script check() function with response body and status
+func (s *Script) Check(rsp *Response) bool {
 	result := false
 
-	stateLock.Lock()
+	s.lock.Lock()
+
+	L := s.state
 
 	err := L.CallByParam(lua.P{
-		Fn:      L.GetGlobal("request"),
+		Fn:      L.GetGlobal("check"),
 		NRet:    1,
 		Protect: true,
 		Handler: nil,
-	}, GetReqMeta(L, req))
+	}, lua.LString(string(rsp.Body)), lua.LString(rsp.Status))
 
 	if err == nil {
 		ret := L.Get(-1)
 		L.Pop(1)
 
-		if ok := ret.(lua.LBool); ok {
+		if ret == lua.LTrue {
 			result = true
 		}
 	}
 
-	stateLock.Unlock()
+	s.lock.Unlock()
 
 	return result
 }
 
-func CheckRunScript(body []byte) bool {
-	if !enableLua {
+// Call script event() function with data, name and index of
+// streaming response event, true if function is not defined
+func (s *Script) Event(event *StreamEvent) bool {
+	if !s.hasEvent {
 		return true
 	}
 
 	result := false
 
-	stateLock.Lock()
+	s.lock.Lock()
+
+	L := s.state
 
 	err := L.CallByParam(lua.P{
-		Fn:      L.GetGlobal("check"),
+		Fn:      L.GetGlobal("event"),
 		NRet:    1,
 		Protect: true,
 		Handler: nil,
-	}, lua.LString(string(body)))
+	}, lua.LString(string(event.Data)), lua.LString(event.Name), lua.LNumber(event.Index))
 
 	if err == nil {
 		ret := L.Get(-1)
 		L.Pop(1)
 
-		if ok := ret.(lua.LBool); ok {
+		if ret == lua.LTrue {
 			result = true
 		}
 	}
 
-	stateLock.Unlock()
+	s.lock.Unlock()
 
 	return result
 }
@@ -1,5 +1,4 @@
 mark = require "benchmark"
-json = require "./script/json"
 
 -- 启动测试时会被调用一次(可以初始化一些请求参数)
 function init()
@@ -7,16 +6,23 @@ function init()
 end
 
 -- 每个请求都会被调用一次(请求前: 可以设置请求的一些参数)
-function request(req)
+-- row: 使用 -d 参数加载数据文件时, 为当前请求消费的数据行
+function request(req, row)
     req:set_timeout(10000)                 -- 设置超时时间(毫秒)
     req:set_header("host", "yourhost.com") -- 设置header
     req:set_url("www.baidu.com")
+    req:set_tag("baidu")                   -- 设置统计分组的标签
+
+    -- 使用HMAC-SHA256签名请求
+    -- local ts = tostring(mark.now_ms())
+    -- req:set_header("X-Timestamp", ts)
+    -- req:set_header("X-Signature", mark.hmac_sha256("secret", ts))
     return true
 end
 
 -- 每个请求都会被调用一次(请求后: 检测返回数据是否正确)
 function check(rsp)
-    -- result = json.decode(rsp)
+    -- result, err = mark.json_decode(rsp)
     -- 检测返回数据是否正确
     return true
 end
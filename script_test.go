@@ -0,0 +1,185 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// Load script for test, the Lua state is closed after test
+func loadTestScript(t *testing.T, script string) (*Script, error) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "script.lua")
+	if err := ioutil.WriteFile(path, []byte(script), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadScript(path)
+	if err == nil {
+		t.Cleanup(loaded.Close)
+	}
+
+	return loaded, err
+}
+
+func TestScriptHooks(t *testing.T) {
+	script, err := loadTestScript(t, `
+mark = require "benchmark"
+
+function init()
+    return true
+end
+
+function request(req, row)
+    req:set_url("example.com/users/" .. row.id)
+    req:set_method("post")
+    req:set_header("X-Sign", mark.md5(row.id))
+    req:set_param("name", row.name)
+    req:set_body(mark.json_encode({id = row.id}))
+    req:set_timeout(1500)
+    req:set_tag("users")
+    return row.id ~= "0"
+end
+
+function check(rsp, status)
+    local data = mark.json_decode(rsp)
+    return status == "200" and data ~= nil and data.ok == true
+end
+`)
+	if err != nil {
+		t.Fatalf("LoadScript() failed: %v", err)
+	}
+
+	req := NewRequest()
+
+	if !script.Request(req, map[string]string{"id": "1", "name": "a b"}) {
+		t.Fatal("request() should return true")
+	}
+
+	if req.opts.URL != "http://example.com/users/1" || req.opts.Method != MethodPost {
+		t.Errorf("URL = %q, method = %d", req.opts.URL, req.opts.Method)
+	}
+
+	if req.opts.Headers["X-Sign"] != "c4ca4238a0b923820dcc509a6f75849b" {
+		t.Errorf("X-Sign = %q, want md5 of 1", req.opts.Headers["X-Sign"])
+	}
+
+	if req.opts.Params["name"] != "a b" || string(req.opts.Body) != `{"id":"1"}` {
+		t.Errorf("params = %v, body = %q", req.opts.Params, req.opts.Body)
+	}
+
+	if req.GetTag() != "users" {
+		t.Errorf("tag = %q, want users", req.GetTag())
+	}
+
+	if script.Request(NewRequest(), map[string]string{"id": "0"}) {
+		t.Error("request() should return false")
+	}
+
+	if !script.Check(&Response{Status: "200", Body: []byte(`{"ok":true}`)}) {
+		t.Error("check() should pass")
+	}
+
+	if script.Check(&Response{Status: "200", Body: []byte(`{"ok":false}`)}) ||
+		script.Check(&Response{Status: "200", Body: []byte("not json")}) ||
+		script.Check(&Response{Status: "500", Body: []byte(`{"ok":true}`)}) {
+		t.Error("check() should fail")
+	}
+}
+
+func TestScriptInitFailed(t *testing.T) {
+	if _, err := loadTestScript(t, `function init() return false end`); err == nil {
+		t.Error("LoadScript() should fail when init() returns false")
+	}
+
+	if _, err := loadTestScript(t, `function init() end`); err == nil {
+		t.Error("LoadScript() should fail when init() returns nothing")
+	}
+
+	if _, err := loadTestScript(t, `function init(`); err == nil {
+		t.Error("LoadScript() should fail with syntax error")
+	}
+}
+
+func TestScriptNotBoolean(t *testing.T) {
+	script, err := loadTestScript(t, `
+function init() return true end
+function request(req, row) end
+function check(rsp) return "yes" end
+`)
+	if err != nil {
+		t.Fatalf("LoadScript() failed: %v", err)
+	}
+
+	if script.Request(NewRequest(), nil) {
+		t.Error("request() without result should be false")
+	}
+
+	if script.Check(&Response{}) {
+		t.Error("check() with non-boolean result should be false")
+	}
+}
+
+func TestScriptConcurrent(t *testing.T) {
+	script, err := loadTestScript(t, `
+mark = require "benchmark"
+count = 0
+function init() return true end
+function request(req, row)
+    count = count + 1
+    req:set_header("X-Id", mark.uuid())
+    return true
+end
+function check(rsp) return rsp == "ok" end
+`)
+	if err != nil {
+		t.Fatalf("LoadScript() failed: %v", err)
+	}
+
+	var group sync.WaitGroup
+
+	for i := 0; i < 8; i++ {
+		group.Add(1)
+		go func() {
+			defer group.Done()
+			for j := 0; j < 100; j++ {
+				if !script.Request(NewRequest(), nil) || !script.Check(&Response{Body: []byte("ok")}) {
+					t.Error("script hooks should pass")
+					return
+				}
+			}
+		}()
+	}
+
+	group.Wait()
+
+	if count := script.state.GetGlobal("count").String(); count != "800" {
+		t.Errorf("request() called %s times, want 800", count)
+	}
+}
+
+func TestScriptCurl(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Method + " " + r.URL.Query().Get("q")))
+	}))
+	defer server.Close()
+
+	_, err := loadTestScript(t, `
+mark = require "benchmark"
+function init()
+    local body, ok = mark.curl("`+server.URL+`", "DELETE", {}, {q = "a b"})
+    return ok and body == "DELETE a b"
+end
+`)
+	if err != nil {
+		t.Errorf("curl() in init() failed: %v", err)
+	}
+}
@@ -0,0 +1,248 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"math/rand"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+var (
+	// Shared by Lua states of all scripts
+	scriptRand     = rand.New(rand.NewSource(time.Now().UnixNano()))
+	scriptRandLock sync.Mutex
+
+	// Example: benchmark.sha256(data)
+	scriptMD5    = hashFunction(md5.New)
+	scriptSHA1   = hashFunction(sha1.New)
+	scriptSHA256 = hashFunction(sha256.New)
+
+	// Example: benchmark.hmac_sha256(key, data)
+	scriptHMACMD5    = hmacFunction(md5.New)
+	scriptHMACSHA1   = hmacFunction(sha1.New)
+	scriptHMACSHA256 = hmacFunction(sha256.New)
+)
+
+// Convert Lua value to Go value which can be encoded to JSON,
+// table with continuous integer keys would be converted to array
+func luaToGo(value lua.LValue) interface{} {
+	switch v := value.(type) {
+	case lua.LBool:
+		return bool(v)
+	case lua.LNumber:
+		return float64(v)
+	case lua.LString:
+		return string(v)
+	case *lua.LTable:
+		size := v.MaxN()
+		count := 0
+		v.ForEach(func(lua.LValue, lua.LValue) {
+			count++
+		})
+
+		if size > 0 && size == count {
+			array := make([]interface{}, 0, size)
+			for i := 1; i <= size; i++ {
+				array = append(array, luaToGo(v.RawGetInt(i)))
+			}
+			return array
+		}
+
+		object := make(map[string]interface{}, count)
+		v.ForEach(func(key lua.LValue, value lua.LValue) {
+			object[key.String()] = luaToGo(value)
+		})
+		return object
+	}
+
+	return nil
+}
+
+// Convert Go value decoded from JSON to Lua value
+func goToLua(L *lua.LState, value interface{}) lua.LValue {
+	switch v := value.(type) {
+	case bool:
+		return lua.LBool(v)
+	case float64:
+		return lua.LNumber(v)
+	case string:
+		return lua.LString(v)
+	case []interface{}:
+		table := L.CreateTable(len(v), 0)
+		for _, item := range v {
+			table.Append(goToLua(L, item))
+		}
+		return table
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		table := L.CreateTable(0, len(v))
+		for _, key := range keys {
+			table.RawSetString(key, goToLua(L, v[key]))
+		}
+		return table
+	}
+
+	return lua.LNil
+}
+
+// Push result and error message, Lua convention is (value, nil) or (nil, error)
+func pushResult(L *lua.LState, value lua.LValue, err error) int {
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+	} else {
+		L.Push(value)
+		L.Push(lua.LNil)
+	}
+	return 2
+}
+
+// Helper functions:
+// Encode Lua value to JSON string
+// Example: benchmark.json_encode({id = 1})
+func JSONEncode(L *lua.LState) int {
+	data, err := json.Marshal(luaToGo(L.CheckAny(1)))
+	return pushResult(L, lua.LString(string(data)), err)
+}
+
+// Decode JSON string to Lua value
+// Example: benchmark.json_decode('{"id": 1}')
+func JSONDecode(L *lua.LState) int {
+	var value interface{}
+
+	if err := json.Unmarshal([]byte(L.CheckString(1)), &value); err != nil {
+		return pushResult(L, lua.LNil, err)
+	}
+
+	return pushResult(L, goToLua(L, value), nil)
+}
+
+// Example: benchmark.base64_encode(data)
+func Base64Encode(L *lua.LState) int {
+	L.Push(lua.LString(base64.StdEncoding.EncodeToString([]byte(L.CheckString(1)))))
+	return 1
+}
+
+// Example: benchmark.base64_decode(data)
+func Base64Decode(L *lua.LState) int {
+	data, err := base64.StdEncoding.DecodeString(L.CheckString(1))
+	return pushResult(L, lua.LString(string(data)), err)
+}
+
+// Example: benchmark.hex_encode(data)
+func HexEncode(L *lua.LState) int {
+	L.Push(lua.LString(hex.EncodeToString([]byte(L.CheckString(1)))))
+	return 1
+}
+
+// Example: benchmark.hex_decode(data)
+func HexDecode(L *lua.LState) int {
+	data, err := hex.DecodeString(L.CheckString(1))
+	return pushResult(L, lua.LString(string(data)), err)
+}
+
+// Example: benchmark.url_encode("a b&c")
+func URLEncode(L *lua.LState) int {
+	L.Push(lua.LString(url.QueryEscape(L.CheckString(1))))
+	return 1
+}
+
+// Example: benchmark.url_decode("a+b%26c")
+func URLDecode(L *lua.LState) int {
+	data, err := url.QueryUnescape(L.CheckString(1))
+	return pushResult(L, lua.LString(data), err)
+}
+
+func hashFunction(fun func() hash.Hash) lua.LGFunction {
+	return func(L *lua.LState) int {
+		h := fun()
+		h.Write([]byte(L.CheckString(1)))
+		L.Push(lua.LString(hex.EncodeToString(h.Sum(nil))))
+		return 1
+	}
+}
+
+func hmacFunction(fun func() hash.Hash) lua.LGFunction {
+	return func(L *lua.LState) int {
+		h := hmac.New(fun, []byte(L.CheckString(1)))
+		h.Write([]byte(L.CheckString(2)))
+		L.Push(lua.LString(hex.EncodeToString(h.Sum(nil))))
+		return 1
+	}
+}
+
+// Example: benchmark.uuid()
+func UUID(L *lua.LState) int {
+	L.Push(lua.LString(newUUID()))
+	return 1
+}
+
+// Set seed of random number generator
+// Example: benchmark.seed(42)
+func Seed(L *lua.LState) int {
+	scriptRandLock.Lock()
+	defer scriptRandLock.Unlock()
+
+	scriptRand.Seed(L.CheckInt64(1))
+	return 0
+}
+
+// Get random number, the same as math.random() of Lua
+// Example: benchmark.random(), benchmark.random(10), benchmark.random(1, 10)
+func Random(L *lua.LState) int {
+	scriptRandLock.Lock()
+	defer scriptRandLock.Unlock()
+
+	switch L.GetTop() {
+	case 0:
+		L.Push(lua.LNumber(scriptRand.Float64()))
+	case 1:
+		max := L.CheckInt64(1)
+		if max < 1 {
+			L.ArgError(1, "interval is empty")
+		}
+		L.Push(lua.LNumber(1 + scriptRand.Int63n(max)))
+	default:
+		min, max := L.CheckInt64(1), L.CheckInt64(2)
+		if max < min {
+			L.ArgError(2, "interval is empty")
+		}
+		L.Push(lua.LNumber(min + scriptRand.Int63n(max-min+1)))
+	}
+	return 1
+}
+
+// Get current timestamp in milliseconds
+// Example: benchmark.now_ms()
+func NowMs(L *lua.LState) int {
+	L.Push(lua.LNumber(getTimestampMs()))
+	return 1
+}
+
+// Sleep for milliseconds, notice that other requests
+// would be blocked while calling in request() or check()
+// Example: benchmark.sleep(100)
+func Sleep(L *lua.LState) int {
+	time.Sleep(time.Duration(L.CheckInt64(1)) * time.Millisecond)
+	return 0
+}
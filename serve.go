@@ -0,0 +1,383 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	LatencyFixed       = "fixed"
+	LatencyUniform     = "uniform"
+	LatencyNormal      = "normal"
+	LatencyExponential = "exp"
+)
+
+// Latency distribution of mock server
+type Latency struct {
+	Kind   string
+	Min    time.Duration
+	Max    time.Duration
+	Mean   time.Duration
+	Stddev time.Duration
+}
+
+// Parse latency distribution
+// Example: "50ms" (fixed), "10ms-100ms" (uniform), "normal:50ms,10ms", "exp:50ms"
+func ParseLatency(text string) (*Latency, error) {
+	text = strings.TrimSpace(text)
+
+	invalid := fmt.Errorf("invalid latency: %s", text)
+
+	switch {
+	case strings.HasPrefix(text, LatencyNormal+":"):
+		parts := strings.Split(strings.TrimPrefix(text, LatencyNormal+":"), ",")
+		if len(parts) != 2 {
+			return nil, invalid
+		}
+		mean, err := time.ParseDuration(strings.TrimSpace(parts[0]))
+		if err != nil || mean < 0 {
+			return nil, invalid
+		}
+		stddev, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+		if err != nil || stddev < 0 {
+			return nil, invalid
+		}
+		return &Latency{Kind: LatencyNormal, Mean: mean, Stddev: stddev}, nil
+
+	case strings.HasPrefix(text, LatencyExponential+":"):
+		mean, err := time.ParseDuration(strings.TrimPrefix(text, LatencyExponential+":"))
+		if err != nil || mean < 0 {
+			return nil, invalid
+		}
+		return &Latency{Kind: LatencyExponential, Mean: mean}, nil
+
+	case strings.Contains(text, "-"):
+		index := strings.Index(text, "-")
+		min, err := time.ParseDuration(strings.TrimSpace(text[:index]))
+		if err != nil || min < 0 {
+			return nil, invalid
+		}
+		max, err := time.ParseDuration(strings.TrimSpace(text[index+1:]))
+		if err != nil || max < min {
+			return nil, invalid
+		}
+		return &Latency{Kind: LatencyUniform, Min: min, Max: max}, nil
+	}
+
+	fixed, err := time.ParseDuration(text)
+	if err != nil || fixed < 0 {
+		return nil, invalid
+	}
+
+	return &Latency{Kind: LatencyFixed, Min: fixed, Max: fixed, Mean: fixed}, nil
+}
+
+// Get next latency of distribution, never negative
+func (l *Latency) Next(random *rand.Rand) time.Duration {
+	var latency time.Duration
+
+	switch l.Kind {
+	case LatencyUniform:
+		latency = l.Min
+		if l.Max > l.Min {
+			latency += time.Duration(random.Int63n(int64(l.Max - l.Min + 1)))
+		}
+	case LatencyNormal:
+		latency = time.Duration(random.NormFloat64()*float64(l.Stddev) + float64(l.Mean))
+	case LatencyExponential:
+		latency = time.Duration(random.ExpFloat64() * float64(l.Mean))
+	default:
+		latency = l.Mean
+	}
+
+	if latency < 0 {
+		latency = 0
+	}
+
+	return latency
+}
+
+type MockStatus struct {
+	Code   int
+	Weight int
+}
+
+// Parse weighted status code mix
+// Example: "200:90,500:8,503:2"
+func ParseStatusMix(text string) ([]MockStatus, error) {
+	var statuses []MockStatus
+
+	for _, part := range strings.Split(text, ",") {
+		if part = strings.TrimSpace(part); len(part) == 0 {
+			continue
+		}
+
+		status := MockStatus{Weight: 1}
+
+		fields := strings.SplitN(part, ":", 2)
+
+		code, err := strconv.Atoi(fields[0])
+		if err != nil || code < 100 || code > 999 {
+			return nil, fmt.Errorf("invalid status code: %s", part)
+		}
+		status.Code = code
+
+		if len(fields) == 2 {
+			weight, err := strconv.Atoi(fields[1])
+			if err != nil || weight <= 0 {
+				return nil, fmt.Errorf("invalid status weight: %s", part)
+			}
+			status.Weight = weight
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	if len(statuses) == 0 {
+		return nil, errors.New("status mix is empty")
+	}
+
+	return statuses, nil
+}
+
+type MockOptions struct {
+	// Latency before response, no latency if nil
+	Latency *Latency
+	// Size of response body in bytes
+	Size int
+	// Weighted status codes, always 200 if empty
+	Statuses []MockStatus
+	// Percent of requests which connection is aborted without response
+	ErrorRate float64
+	// Send body in chunks with Transfer-Encoding: chunked if greater than 0
+	Chunks int
+	// Delay between chunks
+	ChunkDelay time.Duration
+}
+
+// MockServer is a configurable HTTP server for calibrating benchmark
+// and testing, options can be overridden by query arguments of each
+// request (etc: /?status=500&size=1024&latency=10ms&chunks=4), path
+// /echo responds request method, URL, headers and body as JSON
+type MockServer struct {
+	opts     MockOptions
+	bounds   []int
+	total    int
+	random   *rand.Rand
+	lock     sync.Mutex
+	requests int64
+	failures int64
+}
+
+// Create mock server
+// @param opts: response options
+func NewMockServer(opts MockOptions) *MockServer {
+	server := &MockServer{
+		opts:   opts,
+		random: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+
+	for _, status := range opts.Statuses {
+		server.total += status.Weight
+		server.bounds = append(server.bounds, server.total)
+	}
+
+	return server
+}
+
+func (s *MockServer) status() int {
+	if s.total == 0 {
+		return http.StatusOK
+	}
+
+	s.lock.Lock()
+	n := s.random.Intn(s.total)
+	s.lock.Unlock()
+
+	return s.opts.Statuses[sort.SearchInts(s.bounds, n+1)].Code
+}
+
+func (s *MockServer) latency(latency *Latency) time.Duration {
+	if latency == nil {
+		return 0
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return latency.Next(s.random)
+}
+
+func (s *MockServer) injectError(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.random.Float64()*100 < rate
+}
+
+// Get options of request, query arguments override server options
+func (s *MockServer) requestOptions(req *http.Request) (MockOptions, int, error) {
+	opts := s.opts
+	status := 0
+
+	query := req.URL.Query()
+
+	if value := query.Get("status"); len(value) > 0 {
+		statuses, err := ParseStatusMix(value)
+		if err != nil {
+			return opts, 0, err
+		}
+		status = statuses[0].Code
+	}
+
+	if value := query.Get("size"); len(value) > 0 {
+		size, err := strconv.Atoi(value)
+		if err != nil || size < 0 {
+			return opts, 0, fmt.Errorf("invalid size: %s", value)
+		}
+		opts.Size = size
+	}
+
+	if value := query.Get("latency"); len(value) > 0 {
+		latency, err := ParseLatency(value)
+		if err != nil {
+			return opts, 0, err
+		}
+		opts.Latency = latency
+	}
+
+	if value := query.Get("error_rate"); len(value) > 0 {
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil || rate < 0 || rate > 100 {
+			return opts, 0, fmt.Errorf("invalid error rate: %s", value)
+		}
+		opts.ErrorRate = rate
+	}
+
+	if value := query.Get("chunks"); len(value) > 0 {
+		chunks, err := strconv.Atoi(value)
+		if err != nil || chunks < 0 {
+			return opts, 0, fmt.Errorf("invalid chunks: %s", value)
+		}
+		opts.Chunks = chunks
+	}
+
+	if value := query.Get("chunk_delay"); len(value) > 0 {
+		delay, err := time.ParseDuration(value)
+		if err != nil || delay < 0 {
+			return opts, 0, fmt.Errorf("invalid chunk delay: %s", value)
+		}
+		opts.ChunkDelay = delay
+	}
+
+	if status == 0 {
+		status = s.status()
+	}
+
+	return opts, status, nil
+}
+
+func (s *MockServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	atomic.AddInt64(&s.requests, 1)
+
+	opts, status, err := s.requestOptions(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if latency := s.latency(opts.Latency); latency > 0 {
+		time.Sleep(latency)
+	}
+
+	if s.injectError(opts.ErrorRate) {
+		atomic.AddInt64(&s.failures, 1)
+		// Abort connection without response
+		panic(http.ErrAbortHandler)
+	}
+
+	var content []byte
+
+	if req.URL.Path == "/echo" {
+		headers := make(map[string]string, len(req.Header))
+		for field := range req.Header {
+			headers[field] = req.Header.Get(field)
+		}
+
+		content, _ = json.Marshal(map[string]interface{}{
+			"method":  req.Method,
+			"url":     req.URL.String(),
+			"headers": headers,
+			"body":    string(body),
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+	} else {
+		content = bytes.Repeat([]byte("x"), opts.Size)
+		w.Header().Set("Content-Type", "text/plain")
+	}
+
+	if opts.Chunks <= 0 {
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		w.WriteHeader(status)
+		_, _ = w.Write(content)
+		return
+	}
+
+	w.WriteHeader(status)
+
+	flusher, _ := w.(http.Flusher)
+
+	size := int(math.Ceil(float64(len(content)) / float64(opts.Chunks)))
+
+	for i := 0; i < opts.Chunks; i++ {
+		if i > 0 && opts.ChunkDelay > 0 {
+			time.Sleep(opts.ChunkDelay)
+		}
+
+		start, end := i*size, (i+1)*size
+		if start > len(content) {
+			start = len(content)
+		}
+		if end > len(content) {
+			end = len(content)
+		}
+
+		_, _ = w.Write(content[start:end])
+
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+// Count of served requests and aborted requests
+func (s *MockServer) Count() (int64, int64) {
+	return atomic.LoadInt64(&s.requests), atomic.LoadInt64(&s.failures)
+}
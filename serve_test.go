@@ -0,0 +1,133 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLatency(t *testing.T) {
+	tests := map[string]Latency{
+		"50ms":             {Kind: LatencyFixed, Min: 50 * time.Millisecond, Max: 50 * time.Millisecond, Mean: 50 * time.Millisecond},
+		"10ms-20ms":        {Kind: LatencyUniform, Min: 10 * time.Millisecond, Max: 20 * time.Millisecond},
+		"normal:50ms,10ms": {Kind: LatencyNormal, Mean: 50 * time.Millisecond, Stddev: 10 * time.Millisecond},
+		"exp:5ms":          {Kind: LatencyExponential, Mean: 5 * time.Millisecond},
+	}
+
+	random := rand.New(rand.NewSource(1))
+
+	for text, want := range tests {
+		latency, err := ParseLatency(text)
+		if err != nil {
+			t.Errorf("ParseLatency(%q) failed: %v", text, err)
+			continue
+		}
+
+		if *latency != want {
+			t.Errorf("ParseLatency(%q) = %+v, want %+v", text, *latency, want)
+		}
+
+		for i := 0; i < 100; i++ {
+			value := latency.Next(random)
+			if value < 0 || (latency.Kind == LatencyUniform && (value < latency.Min || value > latency.Max)) {
+				t.Errorf("latency %q out of range: %v", text, value)
+			}
+		}
+	}
+
+	for _, text := range []string{"", "abc", "20ms-10ms", "normal:50ms", "exp:-1s", "-5ms"} {
+		if _, err := ParseLatency(text); err == nil {
+			t.Errorf("ParseLatency(%q) should fail", text)
+		}
+	}
+}
+
+func TestParseStatusMix(t *testing.T) {
+	statuses, err := ParseStatusMix("200:90, 500:10,503")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []MockStatus{{200, 90}, {500, 10}, {503, 1}}
+
+	if len(statuses) != len(want) {
+		t.Fatalf("statuses = %v, want %v", statuses, want)
+	}
+
+	for i := range want {
+		if statuses[i] != want[i] {
+			t.Errorf("statuses[%d] = %v, want %v", i, statuses[i], want[i])
+		}
+	}
+
+	for _, text := range []string{"", "abc", "200:0", "99", "200:x"} {
+		if _, err := ParseStatusMix(text); err == nil {
+			t.Errorf("ParseStatusMix(%q) should fail", text)
+		}
+	}
+}
+
+func TestMockServer(t *testing.T) {
+	statuses, _ := ParseStatusMix("201")
+	mock := NewMockServer(MockOptions{Size: 10, Statuses: statuses})
+
+	server := httptest.NewServer(mock)
+	defer server.Close()
+
+	tests := []struct {
+		query   string
+		status  int
+		size    int
+		chunked bool
+	}{
+		{"", 201, 10, false},
+		{"?status=503&size=5", 503, 5, false},
+		{"?chunks=3&chunk_delay=1ms&size=7", 201, 7, true},
+	}
+
+	for _, test := range tests {
+		rsp, err := http.Get(server.URL + test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body, _ := ioutil.ReadAll(rsp.Body)
+		rsp.Body.Close()
+
+		if rsp.StatusCode != test.status || len(body) != test.size {
+			t.Errorf("%q: status = %d, size = %d", test.query, rsp.StatusCode, len(body))
+		}
+
+		chunked := len(rsp.TransferEncoding) > 0 && rsp.TransferEncoding[0] == "chunked"
+		if chunked != test.chunked {
+			t.Errorf("%q: chunked = %v, want %v", test.query, chunked, test.chunked)
+		}
+	}
+
+	rsp, err := http.Get(server.URL + "?size=abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid size: status = %d, want 400", rsp.StatusCode)
+	}
+
+	// Client retries idempotent requests on reused connection
+	if _, err := http.Post(server.URL+"?error_rate=100", "text/plain", strings.NewReader("x")); err == nil {
+		t.Error("request should fail when connection aborted")
+	}
+
+	if requests, failures := mock.Count(); requests != 5 || failures != 1 {
+		t.Errorf("count = %d/%d, want 5/1", requests, failures)
+	}
+}
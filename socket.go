@@ -0,0 +1,450 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	SocketText = "text"
+	SocketHex  = "hex"
+
+	// Buffer size of socket reader, large enough for UDP datagram
+	socketBufferSize = 64 * 1024
+)
+
+// Options of TCP and UDP executors, each request sends payload (body)
+// to tcp://host:port or udp://host:port and reads response. Response
+// is read until delimiter, length or read timeout, a single read
+// (etc: a UDP datagram) is the response if none of them set
+type SocketOptions struct {
+	// Encoding of payload, delimiter and expect: text or hex (etc: 0d0a),
+	// escape sequences (etc: \r\n) of delimiter and expect are unquoted
+	// in text encoding
+	Encoding string
+	// Response ends with delimiter
+	Delimiter string
+	// Response is fixed length bytes
+	Length int
+	// Response ends when no more data received in read timeout
+	ReadTimeout time.Duration
+	// Response must contain expect, status is Mismatch if not
+	Expect string
+	// Close connection after each response, connections are reused if false
+	Close bool
+}
+
+type socketConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	addr   string
+}
+
+type socketCall struct {
+	conn    *socketConn
+	payload []byte
+	connect time.Duration
+}
+
+type socketExecutor struct {
+	network   string
+	options   SocketOptions
+	delimiter []byte
+	expect    []byte
+	pool      *connPool
+	// Unix socket dialed instead of address of URL
+	socket string
+}
+
+// Connection managed by connPool
+type pooledConn interface {
+	// Connection can be reused for requests of addr
+	reusable(addr string) bool
+	close()
+}
+
+// Pool of at most size connections shared by workers of executor
+type connPool struct {
+	// Idle connections, nil means connection can be opened
+	idle chan pooledConn
+
+	lock  sync.Mutex
+	conns map[pooledConn]struct{}
+}
+
+func init() {
+	RegisterExecutor("tcp", NewTCPExecutor)
+	RegisterExecutor("udp", NewUDPExecutor)
+}
+
+// Create executor of TCP, at most connections sockets are opened
+func NewTCPExecutor(config *Config) (Executor, error) {
+	return newSocketExecutor("tcp", config)
+}
+
+// Create executor of UDP, at most connections sockets are opened
+func NewUDPExecutor(config *Config) (Executor, error) {
+	return newSocketExecutor("udp", config)
+}
+
+func newSocketExecutor(network string, config *Config) (Executor, error) {
+	options := config.Socket
+
+	if config.Connections <= 0 {
+		return nil, fmt.Errorf("connections must be greater than 0")
+	}
+
+	if options.Length < 0 {
+		return nil, fmt.Errorf("invalid response length: %d", options.Length)
+	}
+
+	if len(config.UnixSocket) > 0 && network != "tcp" {
+		return nil, fmt.Errorf("unix socket is not supported by %s", network)
+	}
+
+	executor := &socketExecutor{
+		network: network,
+		options: options,
+		pool:    newConnPool(config.Connections),
+		socket:  config.UnixSocket,
+	}
+
+	if _, err := decodePayload(options.Encoding, nil); err != nil {
+		return nil, err
+	}
+
+	var err error
+
+	if executor.delimiter, err = decodeSocketOption(options.Encoding, options.Delimiter); err != nil {
+		return nil, fmt.Errorf("invalid delimiter: %s", err.Error())
+	}
+
+	if executor.expect, err = decodeSocketOption(options.Encoding, options.Expect); err != nil {
+		return nil, fmt.Errorf("invalid expect: %s", err.Error())
+	}
+
+	return executor, nil
+}
+
+func (c *socketConn) reusable(addr string) bool {
+	return c.addr == addr
+}
+
+func (c *socketConn) close() {
+	_ = c.conn.Close()
+}
+
+func newConnPool(size int) *connPool {
+	pool := &connPool{
+		idle:  make(chan pooledConn, size),
+		conns: make(map[pooledConn]struct{}),
+	}
+
+	for i := 0; i < size; i++ {
+		pool.idle <- nil
+	}
+
+	return pool
+}
+
+// Take idle socket of addr or open new one by dial, the time of
+// opening connection is returned, it is zero if connection is reused
+func (p *connPool) get(addr string, dial func() (net.Conn, error)) (*socketConn, time.Duration, error) {
+	c, connect, err := p.open(addr, func() (pooledConn, error) {
+		conn, err := dial()
+		if err != nil {
+			return nil, err
+		}
+
+		return &socketConn{
+			conn:   conn,
+			reader: bufio.NewReaderSize(conn, socketBufferSize),
+			addr:   addr,
+		}, nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return c.(*socketConn), connect, nil
+}
+
+// Take idle connection reusable for addr or open new one by dial
+func (p *connPool) open(addr string, dial func() (pooledConn, error)) (pooledConn, time.Duration, error) {
+	c := <-p.idle
+
+	if c != nil && !c.reusable(addr) {
+		p.remove(c)
+		c = nil
+	}
+
+	if c != nil {
+		return c, 0, nil
+	}
+
+	start := time.Now()
+
+	c, err := dial()
+	if err != nil {
+		p.idle <- nil
+		return nil, 0, err
+	}
+
+	p.lock.Lock()
+	p.conns[c] = struct{}{}
+	p.lock.Unlock()
+
+	return c, time.Since(start), nil
+}
+
+// Release connection, it is closed if it can't be reused
+func (p *connPool) put(c pooledConn, reuse bool) {
+	if reuse {
+		p.idle <- c
+		return
+	}
+
+	p.remove(c)
+	p.idle <- nil
+}
+
+func (p *connPool) remove(c pooledConn) {
+	c.close()
+
+	p.lock.Lock()
+	delete(p.conns, c)
+	p.lock.Unlock()
+}
+
+// Close all connections
+func (p *connPool) close() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for c := range p.conns {
+		c.close()
+		delete(p.conns, c)
+	}
+}
+
+// Decode payload of encoding, spaces between hex digits are ignored
+func decodePayload(encoding string, payload []byte) ([]byte, error) {
+	switch strings.ToLower(encoding) {
+	case "", SocketText:
+		return payload, nil
+	case SocketHex:
+		return hex.DecodeString(strings.Join(strings.Fields(string(payload)), ""))
+	}
+	return nil, fmt.Errorf("unsupported encoding: %s", encoding)
+}
+
+// Decode delimiter or expect, escape sequences of text are unquoted
+func decodeSocketOption(encoding, value string) ([]byte, error) {
+	if len(value) == 0 {
+		return nil, nil
+	}
+
+	if strings.ToLower(encoding) == SocketHex {
+		return decodePayload(encoding, []byte(value))
+	}
+
+	var text []byte
+
+	for len(value) > 0 {
+		c, multibyte, tail, err := strconv.UnquoteChar(value, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		// Escape sequences like \xff are single bytes
+		if multibyte {
+			text = append(text, string(c)...)
+		} else {
+			text = append(text, byte(c))
+		}
+
+		value = tail
+	}
+
+	return decodePayload(encoding, text)
+}
+
+// Take idle connection or open new one
+func (e *socketExecutor) Prepare(req *Request) (interface{}, error) {
+	info, err := url.Parse(req.GetURL())
+	if err != nil {
+		return nil, err
+	}
+
+	if len(info.Port()) == 0 && len(e.socket) == 0 {
+		return nil, fmt.Errorf("port of %s is required", req.GetURL())
+	}
+
+	payload, err := decodePayload(e.options.Encoding, req.GetBody())
+	if err != nil {
+		return nil, err
+	}
+
+	conn, connect, err := e.pool.get(info.Host, func() (net.Conn, error) {
+		if len(e.socket) > 0 {
+			return net.DialTimeout("unix", e.socket, req.GetTimeout())
+		}
+		return net.DialTimeout(e.network, info.Host, req.GetTimeout())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &socketCall{conn: conn, payload: payload, connect: connect}, nil
+}
+
+func (e *socketExecutor) Execute(ctx context.Context, prepared interface{}) *Response {
+	call := prepared.(*socketCall)
+	c := call.conn
+
+	rsp := &Response{Connect: call.connect}
+
+	// Connection is not reused after error since data of
+	// response may be left in reader
+	defer func() {
+		e.pool.put(c, rsp.Err == nil && !rsp.Disconnected && !e.options.Close)
+	}()
+
+	deadline, _ := ctx.Deadline()
+	_ = c.conn.SetDeadline(deadline)
+
+	start := time.Now()
+
+	if _, err := c.conn.Write(call.payload); err != nil {
+		rsp.Err = err
+		rsp.Disconnected = isDisconnected(err)
+		return rsp
+	}
+
+	data, err := e.read(c, deadline)
+
+	rsp.Elapsed = time.Since(start)
+	rsp.Body = data
+
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		rsp.Disconnected = true
+
+		// Response is read until connection closed
+		if e.options.ReadTimeout > 0 && len(data) > 0 {
+			err = nil
+		} else {
+			rsp.Status = "Closed"
+		}
+	}
+
+	if err != nil {
+		rsp.Err = err
+		return rsp
+	}
+
+	if len(e.expect) > 0 && !bytes.Contains(data, e.expect) {
+		rsp.Status = "Mismatch"
+		return rsp
+	}
+
+	rsp.Status = "OK"
+	rsp.OK = true
+
+	return rsp
+}
+
+// Read response by delimiter, length or read timeout
+func (e *socketExecutor) read(c *socketConn, deadline time.Time) ([]byte, error) {
+	options := e.options
+
+	switch {
+	case options.Length > 0:
+		data := make([]byte, options.Length)
+		n, err := io.ReadFull(c.reader, data)
+		return data[:n], err
+
+	case len(e.delimiter) > 0:
+		var data []byte
+
+		last := e.delimiter[len(e.delimiter)-1]
+
+		for !bytes.HasSuffix(data, e.delimiter) {
+			line, err := c.reader.ReadBytes(last)
+			data = append(data, line...)
+			if err != nil {
+				return data, err
+			}
+		}
+
+		return data, nil
+
+	case options.ReadTimeout > 0:
+		var (
+			data   []byte
+			buffer = make([]byte, socketBufferSize)
+		)
+
+		for {
+			// Wait for the first data until request timeout
+			idle := false
+			if len(data) > 0 {
+				next := time.Now().Add(options.ReadTimeout)
+				if deadline.IsZero() || next.Before(deadline) {
+					_ = c.conn.SetReadDeadline(next)
+					idle = true
+				}
+			}
+
+			n, err := c.reader.Read(buffer)
+			data = append(data, buffer[:n]...)
+
+			if err != nil {
+				var netErr net.Error
+				if idle && errors.As(err, &netErr) && netErr.Timeout() {
+					return data, nil
+				}
+				return data, err
+			}
+		}
+
+	default:
+		buffer := make([]byte, socketBufferSize)
+		n, err := c.reader.Read(buffer)
+		return buffer[:n], err
+	}
+}
+
+// Whether error means connection is closed by peer or broken
+func isDisconnected(err error) bool {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return false
+	}
+
+	var opErr *net.OpError
+	return errors.As(err, &opErr)
+}
+
+func (e *socketExecutor) Close() error {
+	e.pool.close()
+	return nil
+}
@@ -0,0 +1,280 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Start TCP server, handle is called for each connection
+func newTestTCPServer(t *testing.T, handle func(conn net.Conn)) (string, *int64) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		accepts int64
+		group   sync.WaitGroup
+	)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			atomic.AddInt64(&accepts, 1)
+
+			group.Add(1)
+			go func() {
+				defer group.Done()
+				defer conn.Close()
+				handle(conn)
+			}()
+		}
+	}()
+
+	t.Cleanup(func() {
+		_ = listener.Close()
+		group.Wait()
+	})
+
+	return listener.Addr().String(), &accepts
+}
+
+func TestSocketTCPDelimiter(t *testing.T) {
+	addr, accepts := newTestTCPServer(t, func(conn net.Conn) {
+		reader := bufio.NewReader(conn)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			// Reply in two writes to test reading until delimiter
+			_, _ = conn.Write([]byte("PONG "))
+			_, _ = conn.Write([]byte(strings.TrimPrefix(line, "PING ")[:len(line)-6] + "\r\n"))
+		}
+	})
+
+	var bodies int64
+
+	config := NewConfig()
+	config.Connections = 4
+	config.Requests = 200
+	config.Timeout = 5 * time.Second
+	config.Items = []*BenchmarkItem{{URL: "tcp://" + addr, Body: []byte("PING {{seq}}\n")}}
+	config.Socket.Delimiter = `\r\n`
+	config.Socket.Expect = "PONG"
+	config.Hooks.AfterResponse = func(req *Request, rsp *Response) bool {
+		if bytes.Equal(rsp.Body, []byte("PONG "+strings.TrimSpace(string(req.GetBody())[5:])+"\r\n")) {
+			atomic.AddInt64(&bodies, 1)
+		}
+		return true
+	}
+
+	snapshot := runBenchmark(t, config).Stats.Snapshot()
+
+	if snapshot.Success != 200 || snapshot.Status["OK"] != 200 || bodies != 200 {
+		t.Errorf("success = %d, status = %v, bodies = %d", snapshot.Success, snapshot.Status, bodies)
+	}
+
+	if snapshot.Connects == 0 || snapshot.Connects > 4 || snapshot.Connects != atomic.LoadInt64(accepts) {
+		t.Errorf("connects = %d, accepts = %d, want at most 4", snapshot.Connects, atomic.LoadInt64(accepts))
+	}
+
+	if snapshot.RecvBytes == 0 {
+		t.Error("received bytes are not counted")
+	}
+}
+
+func TestSocketTCPLength(t *testing.T) {
+	// Reply length prefixed payload of 4 bytes request
+	addr, _ := newTestTCPServer(t, func(conn net.Conn) {
+		request := make([]byte, 4)
+		for {
+			if _, err := io.ReadFull(conn, request); err != nil {
+				return
+			}
+			reply := make([]byte, 2, 6)
+			binary.BigEndian.PutUint16(reply, 4)
+			_, _ = conn.Write(append(reply, request...))
+		}
+	})
+
+	config := NewConfig()
+	config.Connections = 1
+	config.Requests = 10
+	config.Timeout = 5 * time.Second
+	config.Items = []*BenchmarkItem{{URL: "tcp://" + addr, Body: []byte("ca fe 00 01")}}
+	config.Socket.Encoding = SocketHex
+	config.Socket.Length = 6
+	config.Socket.Expect = "0004 cafe0001"
+
+	var body []byte
+
+	config.Hooks.AfterResponse = func(req *Request, rsp *Response) bool {
+		body = rsp.Body
+		return true
+	}
+
+	snapshot := runBenchmark(t, config).Stats.Snapshot()
+
+	if snapshot.Success != 10 || snapshot.Connects != 1 || snapshot.RecvBytes != 60 {
+		t.Errorf("success = %d, connects = %d, bytes = %d", snapshot.Success, snapshot.Connects, snapshot.RecvBytes)
+	}
+
+	if !bytes.Equal(body, []byte{0, 4, 0xca, 0xfe, 0, 1}) {
+		t.Errorf("body = %x", body)
+	}
+}
+
+func TestSocketTCPReadTimeout(t *testing.T) {
+	// Reply in two parts, then close connection after every 3 replies
+	addr, accepts := newTestTCPServer(t, func(conn net.Conn) {
+		buffer := make([]byte, 64)
+		for i := 0; i < 3; i++ {
+			if _, err := conn.Read(buffer); err != nil {
+				return
+			}
+			_, _ = conn.Write([]byte("part1;"))
+			time.Sleep(10 * time.Millisecond)
+			_, _ = conn.Write([]byte("part2;"))
+		}
+	})
+
+	config := NewConfig()
+	config.Connections = 1
+	config.Requests = 6
+	config.Timeout = 5 * time.Second
+	config.Items = []*BenchmarkItem{{URL: "tcp://" + addr, Body: []byte("hello")}}
+	config.Socket.ReadTimeout = 100 * time.Millisecond
+
+	var bodies []string
+
+	config.Hooks.AfterResponse = func(req *Request, rsp *Response) bool {
+		bodies = append(bodies, string(rsp.Body))
+		return true
+	}
+
+	snapshot := runBenchmark(t, config).Stats.Snapshot()
+
+	// The 3rd response of each connection is read until closed
+	if snapshot.Success != 6 || snapshot.Disconnects != 2 || snapshot.Connects != 2 || atomic.LoadInt64(accepts) != 2 {
+		t.Errorf("success = %d, disconnects = %d, connects = %d, accepts = %d",
+			snapshot.Success, snapshot.Disconnects, snapshot.Connects, atomic.LoadInt64(accepts))
+	}
+
+	for _, body := range bodies {
+		if body != "part1;part2;" {
+			t.Errorf("body = %q, want both parts", body)
+		}
+	}
+
+	// Connection is opened for each request
+	config.Requests = 4
+	config.Socket.Close = true
+	bodies = nil
+
+	snapshot = runBenchmark(t, config).Stats.Snapshot()
+
+	if snapshot.Success != 4 || snapshot.Connects != 4 || snapshot.Disconnects != 0 {
+		t.Errorf("close: success = %d, connects = %d, disconnects = %d",
+			snapshot.Success, snapshot.Connects, snapshot.Disconnects)
+	}
+}
+
+func TestSocketUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	go func() {
+		buffer := make([]byte, 1024)
+		for {
+			n, addr, err := conn.ReadFrom(buffer)
+			if err != nil {
+				return
+			}
+			_, _ = conn.WriteTo(bytes.ToUpper(buffer[:n]), addr)
+		}
+	}()
+
+	for _, test := range []struct {
+		expect string
+		status string
+	}{
+		{"PING", "OK"},
+		{"PONG", "Mismatch"},
+	} {
+		config := NewConfig()
+		config.Connections = 2
+		config.Requests = 20
+		config.Timeout = 5 * time.Second
+		config.Items = []*BenchmarkItem{{URL: "udp://" + conn.LocalAddr().String(), Body: []byte("ping {{seq}}")}}
+		config.Socket.Expect = test.expect
+
+		snapshot := runBenchmark(t, config).Stats.Snapshot()
+
+		if snapshot.Total != 20 || snapshot.Status[test.status] != 20 {
+			t.Errorf("expect %s: total = %d, status = %v", test.expect, snapshot.Total, snapshot.Status)
+		}
+	}
+}
+
+func TestSocketOptions(t *testing.T) {
+	tests := []struct {
+		encoding string
+		value    string
+		want     []byte
+		fail     bool
+	}{
+		{"", `\r\n`, []byte("\r\n"), false},
+		{"text", `a"b\x00\xff`, []byte{'a', '"', 'b', 0, 0xff}, false},
+		{"hex", "0d 0A", []byte("\r\n"), false},
+		{"hex", "zz", nil, true},
+		{"base32", "a", nil, true},
+		{"text", `\q`, nil, true},
+	}
+
+	for _, test := range tests {
+		got, err := decodeSocketOption(test.encoding, test.value)
+		if (err != nil) != test.fail || !bytes.Equal(got, test.want) {
+			t.Errorf("decodeSocketOption(%s, %s) = %q, %v", test.encoding, test.value, got, err)
+		}
+	}
+
+	config := NewConfig()
+	config.Socket.Encoding = "hex"
+
+	executor, err := NewTCPExecutor(config)
+	if err != nil {
+		t.Fatalf("NewTCPExecutor() failed: %v", err)
+	}
+	defer executor.Close()
+
+	for _, req := range []*Request{
+		NewRequest(URLOption("tcp://127.0.0.1"), BodyOption([]byte("00"))),
+		NewRequest(URLOption("tcp://127.0.0.1:1"), BodyOption([]byte("0g"))),
+	} {
+		if _, err := executor.Prepare(req); err == nil {
+			t.Errorf("Prepare(%s, %s) should fail", req.GetURL(), req.GetBody())
+		}
+	}
+}
@@ -2,11 +2,14 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-package main
+package gobenchmark
 
 import (
+	"math/bits"
+	"sort"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 type Stats struct {
@@ -16,45 +19,201 @@ type Stats struct {
 	elapsedMutex  sync.Mutex
 	maxReqElapsed int64
 	minReqElapsed int64
+	elapsedCounts histogram
 
 	totalTimes int64
 	totalReqs  int64
 
 	totalRecvBytes int64
-	totalPreReqs   int64
 
-	statusMutex sync.Mutex
-	statusStats map[int]int64
+	// Connections opened by executors (etc: WebSocket)
+	connects     int64
+	connectTimes int64
+	disconnects  int64
+	maxConnect   int64
+	droppedMsgs  int64
+
+	// Streaming responses (etc: SSE), gaps are between events
+	streams        int64
+	firstByteTimes int64
+	maxFirstByte   int64
+	events         int64
+	eventGaps      int64
+	gapTimes       int64
+	maxEventGap    int64
+
+	// Wall time of benchmark in nanoseconds
+	duration int64
+
+	statusMutex   sync.Mutex
+	statusStats   map[string]int64
+	protocolStats map[string]int64
+
+	// Stats of named group would also be added to parent
+	parent     *Stats
+	groupMutex sync.Mutex
+	groups     map[string]*Stats
 }
 
 func NewStats() *Stats {
 	return &Stats{
-		statusStats: make(map[int]int64),
+		statusStats:   make(map[string]int64),
+		protocolStats: make(map[string]int64),
+		groups:        make(map[string]*Stats),
 	}
 }
 
+// Get stats of named group (etc: endpoint), create it if not exists
+func (s *Stats) Group(name string) *Stats {
+	s.groupMutex.Lock()
+	defer s.groupMutex.Unlock()
+
+	group, exists := s.groups[name]
+	if !exists {
+		group = NewStats()
+		group.parent = s
+		s.groups[name] = group
+	}
+
+	return group
+}
+
+// Get names of all groups in sorted order
+func (s *Stats) GroupNames() []string {
+	s.groupMutex.Lock()
+	defer s.groupMutex.Unlock()
+
+	var names []string
+
+	for name := range s.groups {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
 func (s *Stats) AddSuccess() {
 	atomic.AddInt64(&s.success, 1)
+	if s.parent != nil {
+		s.parent.AddSuccess()
+	}
 }
 
 func (s *Stats) AddFailure() {
 	atomic.AddInt64(&s.failure, 1)
+	if s.parent != nil {
+		s.parent.AddFailure()
+	}
 }
 
 func (s *Stats) AddTotalTime(ts int64) {
 	atomic.AddInt64(&s.totalTimes, ts)
+	if s.parent != nil {
+		s.parent.AddTotalTime(ts)
+	}
+}
+
+// Set wall time of benchmark, groups share the duration of parent
+func (s *Stats) SetDuration(duration time.Duration) {
+	atomic.StoreInt64(&s.duration, int64(duration))
+}
+
+func (s *Stats) Duration() time.Duration {
+	if s.parent != nil {
+		return s.parent.Duration()
+	}
+	return time.Duration(atomic.LoadInt64(&s.duration))
+}
+
+// Requests per second of wall time, zero if duration has not set
+func (s *Stats) RequestsPerSec() float64 {
+	seconds := s.Duration().Seconds()
+	if seconds <= 0 {
+		return 0
+	}
+	return float64(atomic.LoadInt64(&s.totalReqs)) / seconds
 }
 
-func (s *Stats) AddTotalPreReqs(reqs int64) {
-	atomic.AddInt64(&s.totalPreReqs, reqs)
+// Received bytes per second of wall time, zero if duration has not set
+func (s *Stats) TransferPerSec() float64 {
+	seconds := s.Duration().Seconds()
+	if seconds <= 0 {
+		return 0
+	}
+	return float64(atomic.LoadInt64(&s.totalRecvBytes)) / seconds
 }
 
 func (s *Stats) AddTotalReqs() {
 	atomic.AddInt64(&s.totalReqs, 1)
+	if s.parent != nil {
+		s.parent.AddTotalReqs()
+	}
+}
+
+// Add connection opened in elapsed milliseconds
+func (s *Stats) AddConnect(elapsed int64) {
+	atomic.AddInt64(&s.connects, 1)
+	atomic.AddInt64(&s.connectTimes, elapsed)
+	storeMax(&s.maxConnect, elapsed)
+	if s.parent != nil {
+		s.parent.AddConnect(elapsed)
+	}
+}
+
+// Add streaming response with time to first byte and count of events
+func (s *Stats) AddStream(firstByte int64, events int64) {
+	atomic.AddInt64(&s.streams, 1)
+	atomic.AddInt64(&s.firstByteTimes, firstByte)
+	atomic.AddInt64(&s.events, events)
+	storeMax(&s.maxFirstByte, firstByte)
+	if s.parent != nil {
+		s.parent.AddStream(firstByte, events)
+	}
+}
+
+// Add time between two events of streaming response
+func (s *Stats) AddEventGap(gap int64) {
+	atomic.AddInt64(&s.eventGaps, 1)
+	atomic.AddInt64(&s.gapTimes, gap)
+	storeMax(&s.maxEventGap, gap)
+	if s.parent != nil {
+		s.parent.AddEventGap(gap)
+	}
+}
+
+// Store value to addr if it is greater than current value
+func storeMax(addr *int64, value int64) {
+	for {
+		max := atomic.LoadInt64(addr)
+		if value <= max || atomic.CompareAndSwapInt64(addr, max, value) {
+			break
+		}
+	}
+}
+
+// Add connection closed by peer or broken
+func (s *Stats) AddDisconnect() {
+	atomic.AddInt64(&s.disconnects, 1)
+	if s.parent != nil {
+		s.parent.AddDisconnect()
+	}
+}
+
+// Add received messages which no request waited for
+func (s *Stats) AddDroppedMessages(count int64) {
+	atomic.AddInt64(&s.droppedMsgs, count)
+	if s.parent != nil {
+		s.parent.AddDroppedMessages(count)
+	}
 }
 
 func (s *Stats) AddTotalRecvBytes(bytes int64) {
 	atomic.AddInt64(&s.totalRecvBytes, bytes)
+	if s.parent != nil {
+		s.parent.AddTotalRecvBytes(bytes)
+	}
 }
 
 func (s *Stats) UpdateReqElapsed(elapsed int64) {
@@ -65,14 +224,258 @@ func (s *Stats) UpdateReqElapsed(elapsed int64) {
 	if s.minReqElapsed == 0 || elapsed < s.minReqElapsed {
 		s.minReqElapsed = elapsed
 	}
+	s.elapsedCounts.Add(elapsed)
 	s.elapsedMutex.Unlock()
+
+	if s.parent != nil {
+		s.parent.UpdateReqElapsed(elapsed)
+	}
 }
 
-func (s *Stats) AddStatusCount(status int) {
+// Add count of protocol status (etc: 200 of HTTP)
+func (s *Stats) AddStatusCount(status string) {
 	s.statusMutex.Lock()
 	if _, exists := s.statusStats[status]; !exists {
 		s.statusStats[status] = 0
 	}
 	s.statusStats[status]++
 	s.statusMutex.Unlock()
+
+	if s.parent != nil {
+		s.parent.AddStatusCount(status)
+	}
+}
+
+// Add count of negotiated protocol (etc: HTTP/2.0)
+func (s *Stats) AddProtocolCount(protocol string) {
+	s.statusMutex.Lock()
+	s.protocolStats[protocol]++
+	s.statusMutex.Unlock()
+
+	if s.parent != nil {
+		s.parent.AddProtocolCount(protocol)
+	}
+}
+
+// Get request elapsed percentiles
+// @param percents: percentiles to calculate (etc: 50, 90, 99)
+// @return: elapsed of each percentile
+func (s *Stats) Percentiles(percents ...float64) []int64 {
+	s.elapsedMutex.Lock()
+	defer s.elapsedMutex.Unlock()
+
+	results := make([]int64, len(percents))
+
+	for i, percent := range percents {
+		results[i] = s.elapsedCounts.Percentile(percent)
+		if results[i] > s.maxReqElapsed {
+			results[i] = s.maxReqElapsed
+		}
+	}
+
+	return results
+}
+
+const (
+	// Values less than histogramExact are counted exactly, larger
+	// values are counted in log buckets, each power of two range has
+	// histogramSubBuckets buckets, so the error is less than 1/64
+	histogramExact      = 128
+	histogramSubBuckets = 64
+)
+
+// Histogram of request time, memory is bounded by the largest value
+// instead of the count of values
+type histogram struct {
+	counts []int64
+	total  int64
+}
+
+// Get index of bucket which counts value
+func histogramIndex(value int64) int {
+	if value < histogramExact {
+		if value < 0 {
+			return 0
+		}
+		return int(value)
+	}
+
+	// value >> shift is in [histogramSubBuckets, histogramExact)
+	shift := bits.Len64(uint64(value)) - 7
+
+	return histogramExact + (shift-1)*histogramSubBuckets + int(value>>shift) - histogramSubBuckets
+}
+
+// Get the largest value counted by bucket of index
+func histogramValue(index int) int64 {
+	if index < histogramExact {
+		return int64(index)
+	}
+
+	index -= histogramExact
+
+	shift := uint(index/histogramSubBuckets + 1)
+	sub := int64(index%histogramSubBuckets + histogramSubBuckets)
+
+	return (sub+1)<<shift - 1
+}
+
+func (h *histogram) Add(value int64) {
+	index := histogramIndex(value)
+	if index >= len(h.counts) {
+		counts := make([]int64, index+1)
+		copy(counts, h.counts)
+		h.counts = counts
+	}
+
+	h.counts[index]++
+	h.total++
+}
+
+// Get value of percentile, zero if histogram is empty
+func (h *histogram) Percentile(percent float64) int64 {
+	if h.total == 0 {
+		return 0
+	}
+
+	rank := int64(float64(h.total)*percent/100 + 0.5)
+	if rank < 1 {
+		rank = 1
+	} else if rank > h.total {
+		rank = h.total
+	}
+
+	var count int64
+
+	for index, n := range h.counts {
+		if count += n; count >= rank {
+			return histogramValue(index)
+		}
+	}
+
+	return histogramValue(len(h.counts) - 1)
+}
+
+// Snapshot is a consistent copy of stats, it is safe to be read
+// while benchmark is running
+type Snapshot struct {
+	Total     int64
+	Success   int64
+	Failure   int64
+	RecvBytes int64
+
+	// Request time in milliseconds
+	MinTime int64
+	MaxTime int64
+	AvgTime int64
+
+	Duration       time.Duration
+	RequestsPerSec float64
+	TransferPerSec float64
+
+	// Connections opened by executors, time in milliseconds
+	Connects       int64
+	AvgConnectTime int64
+	MaxConnectTime int64
+	Disconnects    int64
+	// Received messages which no request waited for
+	DroppedMessages int64
+
+	// Streaming responses, time in milliseconds
+	Streams          int64
+	AvgFirstByteTime int64
+	MaxFirstByteTime int64
+	Events           int64
+	AvgEventGap      int64
+	MaxEventGap      int64
+
+	// Request time of each percentile in Percentiles
+	Percentiles map[float64]int64
+	Status      map[string]int64
+	// Requests of each negotiated protocol
+	Protocols map[string]int64
+	Groups    map[string]Snapshot
+}
+
+// Take snapshot of stats and its groups
+func (s *Stats) Snapshot() Snapshot {
+	snapshot := Snapshot{
+		Total:          atomic.LoadInt64(&s.totalReqs),
+		Success:        atomic.LoadInt64(&s.success),
+		Failure:        atomic.LoadInt64(&s.failure),
+		RecvBytes:      atomic.LoadInt64(&s.totalRecvBytes),
+		Duration:       s.Duration(),
+		RequestsPerSec: s.RequestsPerSec(),
+		TransferPerSec: s.TransferPerSec(),
+		Percentiles:    make(map[float64]int64, len(Percentiles)),
+		Status:         make(map[string]int64),
+		Protocols:      make(map[string]int64),
+	}
+
+	if snapshot.Total > 0 {
+		snapshot.AvgTime = atomic.LoadInt64(&s.totalTimes) / snapshot.Total
+	}
+
+	snapshot.Connects = atomic.LoadInt64(&s.connects)
+	snapshot.MaxConnectTime = atomic.LoadInt64(&s.maxConnect)
+	snapshot.Disconnects = atomic.LoadInt64(&s.disconnects)
+	snapshot.DroppedMessages = atomic.LoadInt64(&s.droppedMsgs)
+
+	if snapshot.Connects > 0 {
+		snapshot.AvgConnectTime = atomic.LoadInt64(&s.connectTimes) / snapshot.Connects
+	}
+
+	snapshot.Streams = atomic.LoadInt64(&s.streams)
+	snapshot.MaxFirstByteTime = atomic.LoadInt64(&s.maxFirstByte)
+	snapshot.Events = atomic.LoadInt64(&s.events)
+	snapshot.MaxEventGap = atomic.LoadInt64(&s.maxEventGap)
+
+	if snapshot.Streams > 0 {
+		snapshot.AvgFirstByteTime = atomic.LoadInt64(&s.firstByteTimes) / snapshot.Streams
+	}
+
+	if gaps := atomic.LoadInt64(&s.eventGaps); gaps > 0 {
+		snapshot.AvgEventGap = atomic.LoadInt64(&s.gapTimes) / gaps
+	}
+
+	s.elapsedMutex.Lock()
+	snapshot.MinTime = s.minReqElapsed
+	snapshot.MaxTime = s.maxReqElapsed
+	s.elapsedMutex.Unlock()
+
+	for i, elapsed := range s.Percentiles(Percentiles...) {
+		snapshot.Percentiles[Percentiles[i]] = elapsed
+	}
+
+	s.statusMutex.Lock()
+	for code, count := range s.statusStats {
+		snapshot.Status[code] = count
+	}
+	for protocol, count := range s.protocolStats {
+		snapshot.Protocols[protocol] = count
+	}
+	s.statusMutex.Unlock()
+
+	names := s.GroupNames()
+	if len(names) > 0 {
+		snapshot.Groups = make(map[string]Snapshot, len(names))
+		for _, name := range names {
+			snapshot.Groups[name] = s.Group(name).Snapshot()
+		}
+	}
+
+	return snapshot
+}
+
+// Get group names of snapshot in sorted order
+func (s *Snapshot) GroupNames() []string {
+	var names []string
+
+	for name := range s.Groups {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
 }
@@ -0,0 +1,154 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStatsConcurrent(t *testing.T) {
+	stats := NewStats()
+
+	const (
+		workers  = 16
+		requests = 500
+	)
+
+	var group sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		group.Add(1)
+		go func(worker int) {
+			defer group.Done()
+			for j := 0; j < requests; j++ {
+				s := stats.Group([]string{"a", "b"}[worker%2])
+				s.AddTotalReqs()
+				s.AddTotalTime(2)
+				s.AddTotalRecvBytes(10)
+				s.AddStatusCount("200")
+				s.UpdateReqElapsed(int64(j%10 + 1))
+				s.AddSuccess()
+			}
+		}(i)
+	}
+
+	group.Wait()
+
+	if stats.totalReqs != workers*requests || stats.success != workers*requests {
+		t.Errorf("total = %d, success = %d, want %d", stats.totalReqs, stats.success, workers*requests)
+	}
+
+	if stats.totalRecvBytes != workers*requests*10 {
+		t.Errorf("received bytes = %d, want %d", stats.totalRecvBytes, workers*requests*10)
+	}
+
+	if stats.statusStats["200"] != workers*requests {
+		t.Errorf("status 200 = %d, want %d", stats.statusStats["200"], workers*requests)
+	}
+
+	if stats.minReqElapsed != 1 || stats.maxReqElapsed != 10 {
+		t.Errorf("min/max = %d/%d, want 1/10", stats.minReqElapsed, stats.maxReqElapsed)
+	}
+
+	if names := stats.GroupNames(); !reflect.DeepEqual(names, []string{"a", "b"}) {
+		t.Errorf("groups = %v, want [a b]", names)
+	}
+
+	if a := stats.Group("a"); a.totalReqs != workers*requests/2 {
+		t.Errorf("group a total = %d, want %d", a.totalReqs, workers*requests/2)
+	}
+}
+
+func TestStatsPercentiles(t *testing.T) {
+	stats := NewStats()
+
+	if got := stats.Percentiles(50, 99); !reflect.DeepEqual(got, []int64{0, 0}) {
+		t.Errorf("percentiles of empty stats = %v, want [0 0]", got)
+	}
+
+	for i := 100; i >= 1; i-- {
+		stats.UpdateReqElapsed(int64(i))
+	}
+
+	if got := stats.Percentiles(50, 90, 99, 100); !reflect.DeepEqual(got, []int64{50, 90, 99, 100}) {
+		t.Errorf("percentiles = %v, want [50 90 99 100]", got)
+	}
+
+	// Large values are counted in log buckets with bounded error
+	stats = NewStats()
+
+	for i := 1; i <= 200000; i++ {
+		stats.UpdateReqElapsed(int64(i))
+	}
+
+	for i, got := range stats.Percentiles(50, 90, 99, 100) {
+		want := []int64{100000, 180000, 198000, 200000}[i]
+		if diff := got - want; diff < 0 || diff > want/64 {
+			t.Errorf("percentile %d = %d, want about %d", i, got, want)
+		}
+	}
+
+	if buckets := len(stats.elapsedCounts.counts); buckets > 1024 {
+		t.Errorf("buckets = %d, want bounded", buckets)
+	}
+
+	for _, value := range []int64{0, 127, 128, 255, 256, 1000, 1 << 40} {
+		index := histogramIndex(value)
+		if upper := histogramValue(index); upper < value || (index > 0 && histogramValue(index-1) >= value) {
+			t.Errorf("bucket of %d = %d, upper = %d", value, index, upper)
+		}
+	}
+}
+
+func TestStatsPerSecond(t *testing.T) {
+	stats := NewStats()
+	group := stats.Group("api")
+
+	for i := 0; i < 10; i++ {
+		group.AddTotalReqs()
+		group.AddTotalRecvBytes(1024)
+	}
+
+	if stats.RequestsPerSec() != 0 || stats.TransferPerSec() != 0 {
+		t.Error("rate should be zero before duration is set")
+	}
+
+	stats.SetDuration(2 * time.Second)
+
+	if got := stats.RequestsPerSec(); got != 5 {
+		t.Errorf("requests/sec = %v, want 5", got)
+	}
+
+	if got := group.RequestsPerSec(); got != 5 {
+		t.Errorf("group requests/sec = %v, want 5", got)
+	}
+
+	if got := stats.TransferPerSec(); got != 5120 {
+		t.Errorf("transfer/sec = %v, want 5120", got)
+	}
+}
+
+func TestStatsConnections(t *testing.T) {
+	stats := NewStats()
+	group := stats.Group("ws")
+
+	group.AddConnect(10)
+	group.AddConnect(30)
+	group.AddDisconnect()
+
+	snapshot := stats.Snapshot()
+
+	if snapshot.Connects != 2 || snapshot.AvgConnectTime != 20 || snapshot.MaxConnectTime != 30 || snapshot.Disconnects != 1 {
+		t.Errorf("connects = %d, avg = %v, max = %d, disconnects = %d",
+			snapshot.Connects, snapshot.AvgConnectTime, snapshot.MaxConnectTime, snapshot.Disconnects)
+	}
+
+	if ws := snapshot.Groups["ws"]; ws.Connects != 2 || ws.Disconnects != 1 {
+		t.Errorf("group connects = %d, disconnects = %d", ws.Connects, ws.Disconnects)
+	}
+}
@@ -0,0 +1,237 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// Buffer size of reading chunks of streaming response
+	streamChunkSize = 32 * 1024
+)
+
+// Options of reading HTTP responses as stream, events of SSE
+// (text/event-stream) or chunks of other responses are measured
+type StreamOptions struct {
+	Enabled bool
+	// Stream is closed as success when duration reached, the request
+	// timeout is added to it, zero means reading until server closes
+	// stream or request timeout
+	Duration time.Duration
+	// Stream is closed as success after number of events, zero means no limit
+	MaxEvents int
+}
+
+// Event of streaming response
+type StreamEvent struct {
+	// Sequence of event in stream, starting from 1
+	Index int
+	// Event type and id of SSE, name is "message" if not set
+	// by server, both are empty for chunks
+	Name string
+	ID   string
+	Data []byte
+	// Time since request sent
+	Elapsed time.Duration
+	// Time since previous event, zero for the first event
+	Gap time.Duration
+}
+
+type httpStream struct {
+	request *http.Request
+	onEvent func(event *StreamEvent) bool
+}
+
+type streamReader struct {
+	body    io.Reader
+	start   time.Time
+	options StreamOptions
+	onEvent func(event *StreamEvent) bool
+	rsp     *Response
+	// Elapsed time of previous event
+	last time.Duration
+}
+
+// Send request and read response as stream until it is closed
+func (e *httpExecutor) readStream(ctx context.Context, call *httpStream) *Response {
+	limitCtx := ctx
+
+	if e.stream.Duration > 0 {
+		var cancel context.CancelFunc
+		limitCtx, cancel = context.WithTimeout(ctx, e.stream.Duration)
+		defer cancel()
+	}
+
+	client, release, err := e.acquire(call.request)
+	if err != nil {
+		return &Response{Err: err}
+	}
+	defer release()
+
+	request := call.request.WithContext(limitCtx)
+
+	start := time.Now()
+
+	rsp, err := client.Do(request)
+	if err != nil {
+		return &Response{Err: err}
+	}
+
+	defer func() {
+		_ = rsp.Body.Close()
+	}()
+
+	reader := &streamReader{
+		body:    rsp.Body,
+		start:   start,
+		options: e.stream,
+		onEvent: call.onEvent,
+		rsp: &Response{
+			Status:   strconv.Itoa(rsp.StatusCode),
+			OK:       rsp.StatusCode == http.StatusOK,
+			Protocol: rsp.Proto,
+		},
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(rsp.Header.Get("Content-Type"))
+	if mediaType == "text/event-stream" {
+		err = reader.readEvents()
+	} else {
+		err = reader.readChunks()
+	}
+
+	result := reader.rsp
+	result.Elapsed = time.Since(start)
+
+	// Stream closed by server or limits is finished
+	if err == io.EOF || (ctx.Err() == nil && limitCtx.Err() != nil) {
+		err = nil
+	}
+
+	result.Err = err
+
+	return result
+}
+
+func (s *streamReader) Read(p []byte) (int, error) {
+	n, err := s.body.Read(p)
+	if n > 0 {
+		if s.rsp.FirstByte <= 0 {
+			s.rsp.FirstByte = time.Since(s.start)
+		}
+		s.rsp.Bytes += int64(n)
+	}
+	return n, err
+}
+
+// Handle event of stream, it returns error if stream should be closed
+func (s *streamReader) emit(event *StreamEvent) error {
+	rsp := s.rsp
+
+	rsp.Events++
+
+	event.Index = int(rsp.Events)
+	event.Elapsed = time.Since(s.start)
+
+	if rsp.Events > 1 {
+		event.Gap = event.Elapsed - s.last
+		rsp.EventGaps = append(rsp.EventGaps, event.Gap)
+	}
+
+	s.last = event.Elapsed
+
+	if s.onEvent != nil && !s.onEvent(event) {
+		return fmt.Errorf("check event %d false", event.Index)
+	}
+
+	if s.options.MaxEvents > 0 && event.Index >= s.options.MaxEvents {
+		return io.EOF
+	}
+
+	return nil
+}
+
+// Each read of response body is an event
+func (s *streamReader) readChunks() error {
+	buffer := make([]byte, streamChunkSize)
+
+	for {
+		n, err := s.Read(buffer)
+		if n > 0 {
+			data := make([]byte, n)
+			copy(data, buffer[:n])
+
+			if emitErr := s.emit(&StreamEvent{Data: data}); emitErr != nil {
+				return emitErr
+			}
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Parse events of text/event-stream, event is dispatched by empty line
+func (s *streamReader) readEvents() error {
+	var (
+		reader = bufio.NewReader(s)
+		event  = &StreamEvent{}
+		data   [][]byte
+	)
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			return err
+		}
+
+		line = bytes.TrimRight(line, "\r\n")
+
+		if len(line) == 0 {
+			if len(data) > 0 {
+				event.Data = bytes.Join(data, []byte("\n"))
+				if len(event.Name) == 0 {
+					event.Name = "message"
+				}
+
+				if err := s.emit(event); err != nil {
+					return err
+				}
+			}
+
+			event, data = &StreamEvent{}, nil
+			continue
+		}
+
+		// Comment line
+		if line[0] == ':' {
+			continue
+		}
+
+		field, value := line, []byte{}
+		if index := bytes.IndexByte(line, ':'); index >= 0 {
+			field, value = line[:index], bytes.TrimPrefix(line[index+1:], []byte(" "))
+		}
+
+		switch string(field) {
+		case "data":
+			data = append(data, append([]byte{}, value...))
+		case "event":
+			event.Name = string(value)
+		case "id":
+			event.ID = string(value)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Start server which sends count events (infinite if count <= 0)
+// every delay, the response is chunked if query has chunks=1
+func newTestStreamServer(t *testing.T, delay time.Duration) string {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count, _ := strconv.Atoi(r.URL.Query().Get("count"))
+		chunks := r.URL.Query().Get("chunks") == "1"
+
+		if chunks {
+			w.Header().Set("Content-Type", "application/octet-stream")
+		} else {
+			w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+		}
+
+		w.WriteHeader(http.StatusOK)
+
+		flusher := w.(http.Flusher)
+
+		for i := 1; count <= 0 || i <= count; i++ {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(delay):
+			}
+
+			if chunks {
+				fmt.Fprintf(w, "chunk-%d", i)
+			} else if i%2 == 0 {
+				fmt.Fprintf(w, ": keepalive\r\nevent: tick\r\nid: %d\r\ndata: line-%d\r\ndata: end\r\n\r\n", i, i)
+			} else {
+				fmt.Fprintf(w, "id: %d\ndata:line-%d\n\n", i, i)
+			}
+
+			flusher.Flush()
+		}
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server.URL
+}
+
+func TestStreamEvents(t *testing.T) {
+	link := newTestStreamServer(t, 20*time.Millisecond)
+
+	var (
+		lock   sync.Mutex
+		events []StreamEvent
+	)
+
+	config := NewConfig()
+	config.Connections = 2
+	config.Requests = 4
+	config.Items = []*BenchmarkItem{{URL: link + "/?count=3"}}
+	config.Stream.Enabled = true
+	config.Hooks.OnEvent = func(req *Request, event *StreamEvent) bool {
+		lock.Lock()
+		events = append(events, *event)
+		lock.Unlock()
+		return true
+	}
+
+	snapshot := runBenchmark(t, config).Stats.Snapshot()
+
+	if snapshot.Success != 4 || snapshot.Streams != 4 || snapshot.Events != 12 {
+		t.Errorf("success = %d, streams = %d, events = %d", snapshot.Success, snapshot.Streams, snapshot.Events)
+	}
+
+	if snapshot.AvgFirstByteTime < 15 || snapshot.AvgEventGap < 15 || snapshot.MaxEventGap < snapshot.AvgEventGap {
+		t.Errorf("first byte = %d, gap = %d/%d", snapshot.AvgFirstByteTime, snapshot.AvgEventGap, snapshot.MaxEventGap)
+	}
+
+	if snapshot.AvgTime < 50 {
+		t.Errorf("average time = %d, want duration of stream", snapshot.AvgTime)
+	}
+
+	if len(events) != 12 {
+		t.Fatalf("events = %d, want 12", len(events))
+	}
+
+	for _, event := range events {
+		switch event.Index {
+		case 1, 3:
+			if event.Name != "message" || string(event.Data) != "line-"+event.ID {
+				t.Errorf("event %d = %+v", event.Index, event)
+			}
+		case 2:
+			if event.Name != "tick" || event.ID != "2" || string(event.Data) != "line-2\nend" {
+				t.Errorf("event %d = %+v", event.Index, event)
+			}
+		default:
+			t.Errorf("unexpected event %+v", event)
+		}
+
+		if (event.Index == 1) != (event.Gap == 0) {
+			t.Errorf("gap of event %d = %v", event.Index, event.Gap)
+		}
+	}
+}
+
+func TestStreamChunks(t *testing.T) {
+	link := newTestStreamServer(t, 10*time.Millisecond)
+
+	config := NewConfig()
+	config.Connections = 1
+	config.Requests = 2
+	config.Items = []*BenchmarkItem{{URL: link + "/?count=5&chunks=1"}}
+	config.Stream.Enabled = true
+
+	snapshot := runBenchmark(t, config).Stats.Snapshot()
+
+	if snapshot.Success != 2 || snapshot.Events != 10 || snapshot.RecvBytes != int64(2*5*len("chunk-1")) {
+		t.Errorf("success = %d, events = %d, bytes = %d", snapshot.Success, snapshot.Events, snapshot.RecvBytes)
+	}
+}
+
+func TestStreamLimits(t *testing.T) {
+	link := newTestStreamServer(t, 10*time.Millisecond)
+
+	tests := []struct {
+		name    string
+		options StreamOptions
+	}{
+		{"duration", StreamOptions{Enabled: true, Duration: 100 * time.Millisecond}},
+		{"events", StreamOptions{Enabled: true, MaxEvents: 4}},
+	}
+
+	for _, test := range tests {
+		config := NewConfig()
+		config.Connections = 1
+		config.Requests = 1
+		config.Items = []*BenchmarkItem{{URL: link}}
+		config.Stream = test.options
+
+		start := time.Now()
+
+		snapshot := runBenchmark(t, config).Stats.Snapshot()
+
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("%s: stream is not closed in %v", test.name, elapsed)
+		}
+
+		if snapshot.Success != 1 || snapshot.Events == 0 {
+			t.Errorf("%s: success = %d, events = %d", test.name, snapshot.Success, snapshot.Events)
+		}
+
+		if test.options.MaxEvents > 0 && snapshot.Events != int64(test.options.MaxEvents) {
+			t.Errorf("%s: events = %d, want %d", test.name, snapshot.Events, test.options.MaxEvents)
+		}
+	}
+
+	// Stream is not finished before request timeout
+	config := NewConfig()
+	config.Connections = 1
+	config.Requests = 1
+	config.Timeout = 50 * time.Millisecond
+	config.Items = []*BenchmarkItem{{URL: link}}
+	config.Stream.Enabled = true
+
+	if snapshot := runBenchmark(t, config).Stats.Snapshot(); snapshot.Failure != 1 {
+		t.Errorf("timeout: failure = %d, want 1", snapshot.Failure)
+	}
+
+	// Duration longer than request timeout is reached
+	config.Stream.Duration = 200 * time.Millisecond
+
+	if snapshot := runBenchmark(t, config).Stats.Snapshot(); snapshot.Success != 1 || snapshot.Duration < 200*time.Millisecond {
+		t.Errorf("duration: success = %d, duration = %v", snapshot.Success, snapshot.Duration)
+	}
+}
+
+func TestStreamScript(t *testing.T) {
+	link := newTestStreamServer(t, 5*time.Millisecond)
+
+	path := filepath.Join(t.TempDir(), "stream.lua")
+
+	script := `
+function init()
+    return true
+end
+
+function request(req, row)
+    return true
+end
+
+function event(data, name, index)
+    return index < 3 and string.find(data, "line-" .. index, 1, true) == 1
+end
+
+function check(rsp, status)
+    return status == "200"
+end
+`
+	if err := ioutil.WriteFile(path, []byte(script), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := NewConfig()
+	config.Connections = 1
+	config.Script = path
+	config.Sequential = true
+	config.Items = []*BenchmarkItem{
+		{Name: "short", URL: link + "/?count=1"},
+		{Name: "long", URL: link + "/?count=5"},
+	}
+	config.Stream.Enabled = true
+
+	snapshot := runBenchmark(t, config).Stats.Snapshot()
+
+	// Stream is closed when event() returns false for the 3rd event
+	short, long := snapshot.Groups["short"], snapshot.Groups["long"]
+
+	if short.Success != 1 || short.Events != 1 {
+		t.Errorf("short: success = %d, events = %d", short.Success, short.Events)
+	}
+
+	if long.Failure != 1 || long.Events != 3 {
+		t.Errorf("long: failure = %d, events = %d", long.Failure, long.Events)
+	}
+}
@@ -0,0 +1,266 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	mathrand "math/rand"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+)
+
+type itemTemplate struct {
+	url     *template.Template
+	headers map[string]*template.Template
+	params  map[string]*template.Template
+	body    *template.Template
+}
+
+const (
+	// Skew of zipfian distribution, it must be greater than 1
+	zipfSkew = 1.1
+)
+
+var (
+	templateSeq int64
+
+	// Zipfian generators of ranges, they are not thread safe
+	zipfLock sync.Mutex
+	zipfs    = make(map[[2]int64]*mathrand.Zipf)
+
+	templateFuncs = template.FuncMap{
+		"randInt":     templateRandInt,
+		"zipfInt":     templateZipfInt,
+		"randValue":   templateRandValue,
+		"uuid":        newUUID,
+		"seq":         templateNextSeq,
+		"timestamp":   templateTimestamp,
+		"timestampMs": getTimestampMs,
+		"env":         templateEnv,
+	}
+)
+
+const (
+	// Prefix of environment variables which can be read by templates
+	templateEnvPrefix = "GOBENCHMARK_"
+)
+
+// Helper functions for templates:
+// Get random integer in [min, max]
+// Example: {{randInt 1 10000}}
+func templateRandInt(min, max int64) int64 {
+	if max <= min {
+		return min
+	}
+	return min + mathrand.Int63n(max-min+1)
+}
+
+// Get integer in [min, max] of zipfian distribution, min is the most frequent
+// Example: {{zipfInt 1 10000}}
+func templateZipfInt(min, max int64) int64 {
+	if max <= min {
+		return min
+	}
+
+	zipfLock.Lock()
+	defer zipfLock.Unlock()
+
+	zipf, exists := zipfs[[2]int64{min, max}]
+	if !exists {
+		source := mathrand.New(mathrand.NewSource(time.Now().UnixNano()))
+		zipf = mathrand.NewZipf(source, zipfSkew, 1, uint64(max-min))
+		zipfs[[2]int64{min, max}] = zipf
+	}
+
+	return min + int64(zipf.Uint64())
+}
+
+// Get value of random length in [min, max]
+// Example: {{randValue 100 1000}}
+func templateRandValue(min, max int) string {
+	return strings.Repeat("x", int(templateRandInt(int64(min), int64(max))))
+}
+
+// Get sequence number which increases on each call
+// Example: {{seq}}
+func templateNextSeq() int64 {
+	return atomic.AddInt64(&templateSeq, 1)
+}
+
+// Get environment variable, only variables of prefix GOBENCHMARK_
+// can be read, so other secrets are not sent to target
+// Example: {{env "GOBENCHMARK_TOKEN"}}
+func templateEnv(name string) (string, error) {
+	if !strings.HasPrefix(name, templateEnvPrefix) {
+		return "", fmt.Errorf("environment variable %s can't be read, its name must start with %s", name, templateEnvPrefix)
+	}
+	return os.Getenv(name), nil
+}
+
+// Get current unix timestamp in seconds
+// Example: {{timestamp}}
+func templateTimestamp() int64 {
+	return time.Now().Unix()
+}
+
+// Generate random UUID (version 4)
+func newUUID() string {
+	var buf [16]byte
+
+	_, _ = rand.Read(buf[:])
+
+	buf[6] = (buf[6] & 0x0f) | 0x40
+	buf[8] = (buf[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", buf[0:4], buf[4:6], buf[6:8], buf[8:10], buf[10:16])
+}
+
+func isTemplate(text string) bool {
+	return strings.Contains(text, "{{")
+}
+
+func parseTemplate(name, text string) (*template.Template, error) {
+	if !isTemplate(text) {
+		return nil, nil
+	}
+
+	tmpl, err := template.New(name).Option("missingkey=error").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template in %s: %s", name, err.Error())
+	}
+
+	return tmpl, nil
+}
+
+func executeTemplate(tmpl *template.Template, data interface{}) (string, error) {
+	var buf bytes.Buffer
+
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// Compile placeholders of URL, headers, params and body,
+// item without any placeholder or raw item would not be rendered
+func (item *BenchmarkItem) Compile() error {
+	if item.Raw {
+		item.template = nil
+		return nil
+	}
+
+	var (
+		tmpl = &itemTemplate{
+			headers: make(map[string]*template.Template),
+			params:  make(map[string]*template.Template),
+		}
+		found = false
+		err   error
+	)
+
+	if tmpl.url, err = parseTemplate("URL", item.URL); err != nil {
+		return err
+	} else if tmpl.url != nil {
+		found = true
+	}
+
+	for field, value := range item.Headers {
+		t, err := parseTemplate("header "+field, value)
+		if err != nil {
+			return err
+		} else if t != nil {
+			tmpl.headers[field] = t
+			found = true
+		}
+	}
+
+	for field, value := range item.Params {
+		t, err := parseTemplate("param "+field, value)
+		if err != nil {
+			return err
+		} else if t != nil {
+			tmpl.params[field] = t
+			found = true
+		}
+	}
+
+	if tmpl.body, err = parseTemplate("body", string(item.Body)); err != nil {
+		return err
+	} else if tmpl.body != nil {
+		found = true
+	}
+
+	if found {
+		item.template = tmpl
+	}
+
+	return nil
+}
+
+// Render placeholders with data row, the item itself
+// would be returned if it has no placeholder
+// @param row: data row from feeder, can be nil
+func (item *BenchmarkItem) Render(row map[string]string) (*BenchmarkItem, error) {
+	tmpl := item.template
+	if tmpl == nil {
+		return item, nil
+	}
+
+	if row == nil {
+		row = make(map[string]string)
+	}
+
+	rendered := *item
+	rendered.template = nil
+
+	var err error
+
+	if tmpl.url != nil {
+		if rendered.URL, err = executeTemplate(tmpl.url, row); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(tmpl.headers) > 0 {
+		rendered.Headers = make(map[string]string, len(item.Headers))
+		for field, value := range item.Headers {
+			if t, exists := tmpl.headers[field]; exists {
+				if value, err = executeTemplate(t, row); err != nil {
+					return nil, err
+				}
+			}
+			rendered.Headers[field] = value
+		}
+	}
+
+	if len(tmpl.params) > 0 {
+		rendered.Params = make(map[string]string, len(item.Params))
+		for field, value := range item.Params {
+			if t, exists := tmpl.params[field]; exists {
+				if value, err = executeTemplate(t, row); err != nil {
+					return nil, err
+				}
+			}
+			rendered.Params[field] = value
+		}
+	}
+
+	if tmpl.body != nil {
+		body, err := executeTemplate(tmpl.body, row)
+		if err != nil {
+			return nil, err
+		}
+		rendered.Body = []byte(body)
+	}
+
+	return &rendered, nil
+}
@@ -0,0 +1,168 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTemplateRender(t *testing.T) {
+	t.Setenv("GOBENCHMARK_TOKEN", "secret")
+
+	item := &BenchmarkItem{
+		URL:     "http://127.0.0.1/users/{{.id}}",
+		Headers: map[string]string{"Authorization": `Bearer {{env "GOBENCHMARK_TOKEN"}}`, "Accept": "text/plain"},
+		Params:  map[string]string{"name": "{{.name}}", "page": "1"},
+		Body:    []byte(`{"id": {{.id}}, "seq": {{seq}}}`),
+	}
+
+	if err := item.Compile(); err != nil {
+		t.Fatalf("Compile() failed: %v", err)
+	}
+
+	rendered, err := item.Render(map[string]string{"id": "7", "name": "alice"})
+	if err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+
+	if rendered.URL != "http://127.0.0.1/users/7" {
+		t.Errorf("URL = %q", rendered.URL)
+	}
+
+	if rendered.Headers["Authorization"] != "Bearer secret" || rendered.Headers["Accept"] != "text/plain" {
+		t.Errorf("headers = %v", rendered.Headers)
+	}
+
+	if rendered.Params["name"] != "alice" || rendered.Params["page"] != "1" {
+		t.Errorf("params = %v", rendered.Params)
+	}
+
+	if !regexp.MustCompile(`^\{"id": 7, "seq": \d+\}$`).Match(rendered.Body) {
+		t.Errorf("body = %q", rendered.Body)
+	}
+
+	// Template item is not changed by rendering
+	if item.URL != "http://127.0.0.1/users/{{.id}}" || item.Params["name"] != "{{.name}}" {
+		t.Errorf("item is changed: %+v", item)
+	}
+
+	// Field of data row is required
+	if _, err := item.Render(map[string]string{"id": "7"}); err == nil {
+		t.Error("Render() without name should fail")
+	}
+
+	if _, err := item.Render(nil); err == nil {
+		t.Error("Render(nil) should fail")
+	}
+}
+
+func TestTemplateCompile(t *testing.T) {
+	plain := &BenchmarkItem{URL: "http://127.0.0.1/", Body: []byte("{}")}
+
+	if err := plain.Compile(); err != nil {
+		t.Fatalf("Compile() failed: %v", err)
+	}
+
+	// Item without placeholder is not copied
+	if rendered, err := plain.Render(nil); err != nil || rendered != plain {
+		t.Errorf("Render() = %p, %v, want item itself", rendered, err)
+	}
+
+	for _, item := range []*BenchmarkItem{
+		{URL: "http://127.0.0.1/{{.id"},
+		{URL: "http://127.0.0.1/", Headers: map[string]string{"X-Id": "{{unknown}}"}},
+		{URL: "http://127.0.0.1/", Params: map[string]string{"id": "{{randInt 1}"}},
+		{URL: "http://127.0.0.1/", Body: []byte("{{end}}")},
+	} {
+		if err := item.Compile(); err == nil {
+			t.Errorf("Compile(%+v) should fail", item)
+		}
+	}
+}
+
+func TestTemplateRaw(t *testing.T) {
+	t.Setenv("GOBENCHMARK_TOKEN", "secret")
+	t.Setenv("SECRET_KEY", "key")
+
+	// Placeholders of raw item are sent as they are
+	item := &BenchmarkItem{URL: "http://127.0.0.1/{{.id", Body: []byte(`{{env "SECRET_KEY"}}`), Raw: true}
+
+	if err := item.Compile(); err != nil {
+		t.Fatalf("Compile() of raw item failed: %v", err)
+	}
+
+	if rendered, err := item.Render(nil); err != nil || rendered != item {
+		t.Errorf("Render() of raw item = %+v, %v", rendered, err)
+	}
+
+	// Only variables of prefix GOBENCHMARK_ can be read
+	if value, err := templateEnv("GOBENCHMARK_TOKEN"); err != nil || value != "secret" {
+		t.Errorf("env GOBENCHMARK_TOKEN = %q, %v", value, err)
+	}
+
+	item = &BenchmarkItem{URL: "http://127.0.0.1/", Body: []byte(`{{env "SECRET_KEY"}}`)}
+
+	if err := item.Compile(); err != nil {
+		t.Fatalf("Compile() failed: %v", err)
+	}
+
+	if rendered, err := item.Render(nil); err == nil {
+		t.Errorf("Render() of env SECRET_KEY = %q, should fail", rendered.Body)
+	}
+}
+
+func TestTemplateFuncs(t *testing.T) {
+	for i := 0; i < 1000; i++ {
+		if value := templateRandInt(5, 10); value < 5 || value > 10 {
+			t.Fatalf("randInt(5, 10) = %d", value)
+		}
+	}
+
+	if value := templateRandInt(5, 5); value != 5 {
+		t.Errorf("randInt(5, 5) = %d", value)
+	}
+
+	if value := templateRandInt(5, 1); value != 5 {
+		t.Errorf("randInt(5, 1) = %d", value)
+	}
+
+	first := templateNextSeq()
+	if second := templateNextSeq(); second != first+1 {
+		t.Errorf("seq = %d after %d", second, first)
+	}
+
+	pattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	if first, second := newUUID(), newUUID(); !pattern.MatchString(first) || first == second {
+		t.Errorf("uuid = %q, %q", first, second)
+	}
+
+	now := time.Now().Unix()
+	if timestamp := templateTimestamp(); timestamp < now || timestamp > now+1 {
+		t.Errorf("timestamp = %d, now = %d", timestamp, now)
+	}
+
+	// Functions are called by templates
+	item := &BenchmarkItem{URL: "http://127.0.0.1/{{randInt 3 3}}/{{timestamp}}/{{uuid}}"}
+	if err := item.Compile(); err != nil {
+		t.Fatalf("Compile() failed: %v", err)
+	}
+
+	rendered, err := item.Render(nil)
+	if err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+
+	matches := regexp.MustCompile(`^http://127\.0\.0\.1/3/(\d+)/[0-9a-f-]{36}$`).FindStringSubmatch(rendered.URL)
+	if matches == nil {
+		t.Fatalf("URL = %q", rendered.URL)
+	}
+
+	if timestamp, _ := strconv.ParseInt(matches[1], 10, 64); timestamp < now {
+		t.Errorf("timestamp of URL = %d, now = %d", timestamp, now)
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Host of HTTP requests sent to Unix socket
+const unixHost = "localhost"
+
+// Context key of socket path of unix:// request
+type unixSocketKey struct{}
+
+var (
+	// Transports of Request.Do for each socket path
+	unixTransportLock sync.Mutex
+	unixTransports    = make(map[string]*http.Transport)
+)
+
+// Get dial function which dials Unix socket instead of address
+func unixDialer(socket string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+
+	return func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return dialer.DialContext(ctx, "unix", socket)
+	}
+}
+
+// Rewrite request of unix:///path/to/socket:/path?query to HTTP request
+// of localhost, the socket path is returned. Path of request is "/" if
+// there is no colon after socket path
+func rewriteUnixRequest(request *http.Request) (string, error) {
+	target := request.URL.Host + request.URL.Path
+
+	socket, path := target, "/"
+	if index := strings.IndexByte(target, ':'); index >= 0 {
+		socket, path = target[:index], target[index+1:]
+	}
+
+	if len(socket) == 0 {
+		return "", fmt.Errorf("socket path of %s is required", request.URL.String())
+	}
+
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	request.URL.Scheme = "http"
+	request.URL.Host = unixHost
+	request.URL.Path = path
+	request.URL.RawPath = ""
+	request.Host = unixHost
+
+	return socket, nil
+}
+
+// Get transport of Request.Do for socket, it is created if not exists
+func unixTransport(socket string) *http.Transport {
+	unixTransportLock.Lock()
+	defer unixTransportLock.Unlock()
+
+	transport, exists := unixTransports[socket]
+	if !exists {
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+		transport.DialContext = unixDialer(socket)
+		unixTransports[socket] = transport
+	}
+
+	return transport
+}
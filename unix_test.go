@@ -0,0 +1,230 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Start HTTP server of HTTP/1.1 and h2c on Unix socket, it replies
+// host and URI of request
+func newTestUnixServer(t *testing.T) string {
+	t.Helper()
+
+	socket := filepath.Join(t.TempDir(), "app.sock")
+
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(r.Host + r.URL.RequestURI()))
+		}),
+		Protocols: &http.Protocols{},
+	}
+
+	server.Protocols.SetHTTP1(true)
+	server.Protocols.SetUnencryptedHTTP2(true)
+
+	go func() {
+		_ = server.Serve(listener)
+	}()
+
+	t.Cleanup(func() {
+		_ = server.Close()
+	})
+
+	return socket
+}
+
+func TestUnixHTTP(t *testing.T) {
+	socket := newTestUnixServer(t)
+
+	tests := []struct {
+		link     string
+		protocol string
+		unix     string
+		want     string
+	}{
+		{"unix://" + socket + ":/api/items?id=1", "", "", "localhost/api/items?id=1"},
+		{"unix://" + socket, "", "", "localhost/"},
+		{"unix://" + socket + ":/h2c", ProtocolH2C, "", "localhost/h2c"},
+		{"http://example.com/users?id=2", "", socket, "example.com/users?id=2"},
+		{"http://example.com/h2c", ProtocolH2C, socket, "example.com/h2c"},
+	}
+
+	for _, test := range tests {
+		config := NewConfig()
+		config.Connections = 2
+		config.Requests = 10
+		config.Items = []*BenchmarkItem{{URL: test.link, ExpectBody: []byte(test.want)}}
+		config.HTTP.Protocol = test.protocol
+		config.UnixSocket = test.unix
+
+		snapshot := runBenchmark(t, config).Stats.Snapshot()
+
+		if snapshot.Success != 10 {
+			t.Errorf("%s: success = %d, status = %v", test.link, snapshot.Success, snapshot.Status)
+		}
+
+		if test.protocol == ProtocolH2C && snapshot.Protocols["HTTP/2.0"] != 10 {
+			t.Errorf("%s: protocols = %v", test.link, snapshot.Protocols)
+		}
+	}
+
+	// Request of library API
+	req := NewRequest(URLOption("unix://"+socket+":/do"), TimeoutOption(5*time.Second))
+
+	if body, err := req.Do(); err != nil || string(body) != "localhost/do" || req.GetLastStatus() != http.StatusOK {
+		t.Errorf("Do() = %q, %v", body, err)
+	}
+
+	for _, link := range []string{"unix://", "unix://:/path"} {
+		if _, err := NewRequest(URLOption(link)).Do(); err == nil {
+			t.Errorf("Do(%s) should fail without socket path", link)
+		}
+	}
+}
+
+func TestUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "echo.sock")
+
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+
+				reader := bufio.NewReader(conn)
+				for {
+					line, err := reader.ReadBytes('\n')
+					if err != nil {
+						return
+					}
+					_, _ = conn.Write(line)
+				}
+			}()
+		}
+	}()
+
+	// Port is not required when Unix socket is dialed
+	config := NewConfig()
+	config.Connections = 2
+	config.Requests = 20
+	config.Timeout = 5 * time.Second
+	config.Items = []*BenchmarkItem{{URL: "tcp://localhost", Body: []byte("ping\n")}}
+	config.Socket.Delimiter = `\n`
+	config.Socket.Expect = "ping"
+	config.UnixSocket = socket
+
+	if snapshot := runBenchmark(t, config).Stats.Snapshot(); snapshot.Success != 20 || snapshot.Connects != 2 {
+		t.Errorf("success = %d, connects = %d", snapshot.Success, snapshot.Connects)
+	}
+
+	config.HTTP.Protocol = ProtocolH3
+
+	if _, err := NewUDPExecutor(config); err == nil {
+		t.Error("NewUDPExecutor() with Unix socket should fail")
+	}
+
+	if _, err := NewHTTPExecutor(config); err == nil {
+		t.Error("NewHTTPExecutor() of h3 with Unix socket should fail")
+	}
+}
+
+func TestUnixMaxStreams(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "h2c.sock")
+
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type connKey struct{}
+
+	var (
+		lock     sync.Mutex
+		conns    int
+		inflight = make(map[int]int)
+		peak     = make(map[int]int)
+	)
+
+	// Remote addresses of Unix socket are the same, connections are numbered
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Context().Value(connKey{}).(int)
+
+			lock.Lock()
+			inflight[id]++
+			if inflight[id] > peak[id] {
+				peak[id] = inflight[id]
+			}
+			lock.Unlock()
+
+			time.Sleep(10 * time.Millisecond)
+
+			lock.Lock()
+			inflight[id]--
+			lock.Unlock()
+		}),
+		ConnContext: func(ctx context.Context, conn net.Conn) context.Context {
+			lock.Lock()
+			defer lock.Unlock()
+
+			conns++
+			return context.WithValue(ctx, connKey{}, conns)
+		},
+		Protocols: &http.Protocols{},
+	}
+
+	server.Protocols.SetUnencryptedHTTP2(true)
+
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	defer server.Close()
+
+	config := NewConfig()
+	config.Connections = 6
+	config.Requests = 60
+	config.Items = []*BenchmarkItem{{URL: "unix://" + socket + ":/"}}
+	config.HTTP = HTTPOptions{Protocol: ProtocolH2C, MaxStreams: 2}
+
+	if snapshot := runBenchmark(t, config).Stats.Snapshot(); snapshot.Success != 60 {
+		t.Errorf("success = %d, status = %v", snapshot.Success, snapshot.Status)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if len(peak) < 3 {
+		t.Errorf("connections = %d, want at least 3 for 6 workers", len(peak))
+	}
+
+	for id, streams := range peak {
+		if streams > 2 {
+			t.Errorf("concurrent streams of connection %d = %d, want at most 2", id, streams)
+		}
+	}
+}
@@ -0,0 +1,329 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsHandshakeTimeout = 10 * time.Second
+)
+
+// Options of WebSocket executor, each request sends a message
+// on one of connections and waits for reply
+type WebSocketOptions struct {
+	// JSON field of correlation id in sent and received messages
+	// (etc: id), the next received message is the reply if empty
+	Match string
+	// Minimum interval between messages of each connection
+	Interval time.Duration
+	// Don't wait for reply, latency is the time of sending message
+	NoReply bool
+}
+
+type wsConn struct {
+	conn  *websocket.Conn
+	link  string
+	match string
+	// Calls waiting for replies by correlation id, the id is
+	// empty if the next received message is the reply
+	lock    sync.Mutex
+	waiters map[string]chan []byte
+	// Received messages which no call waited for
+	dropped int64
+	// Closed when reader exits, err is set before closed
+	done   chan struct{}
+	err    error
+	closed int32
+	// Time of next message can be sent
+	next time.Time
+}
+
+type wsCall struct {
+	conn    *wsConn
+	message []byte
+	id      string
+	connect time.Duration
+}
+
+type wsExecutor struct {
+	options WebSocketOptions
+	dialer  websocket.Dialer
+	pool    *connPool
+}
+
+func init() {
+	RegisterExecutor("ws", NewWebSocketExecutor)
+	RegisterExecutor("wss", NewWebSocketExecutor)
+}
+
+// Create executor of WebSocket, at most connections sockets are opened
+func NewWebSocketExecutor(config *Config) (Executor, error) {
+	if config.Connections <= 0 {
+		return nil, fmt.Errorf("connections must be greater than 0")
+	}
+
+	executor := &wsExecutor{
+		options: config.WebSocket,
+		dialer: websocket.Dialer{
+			Proxy:            http.ProxyFromEnvironment,
+			HandshakeTimeout: wsHandshakeTimeout,
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: true,
+			},
+		},
+		pool: newConnPool(config.Connections),
+	}
+
+	if len(config.UnixSocket) > 0 {
+		executor.dialer.Proxy = nil
+		executor.dialer.NetDialContext = unixDialer(config.UnixSocket)
+	}
+
+	return executor, nil
+}
+
+// Get correlation id of JSON message, empty if not found
+func wsMessageID(message []byte, field string) string {
+	var fields map[string]interface{}
+
+	if err := json.Unmarshal(message, &fields); err != nil {
+		return ""
+	}
+
+	value, exists := fields[field]
+	if !exists || value == nil {
+		return ""
+	}
+
+	return fmt.Sprint(value)
+}
+
+// Take idle connection or open new one
+func (e *wsExecutor) Prepare(req *Request) (interface{}, error) {
+	call := &wsCall{message: req.GetBody()}
+
+	if len(e.options.Match) > 0 {
+		call.id = wsMessageID(call.message, e.options.Match)
+		if len(call.id) == 0 {
+			return nil, fmt.Errorf("correlation id %q not found in message", e.options.Match)
+		}
+	}
+
+	link := req.GetURL()
+	if params := req.GetParams(); len(params) > 0 {
+		separator := "?"
+		if strings.Contains(link, "?") {
+			separator = "&"
+		}
+		link += separator + req.encodeURI()
+	}
+
+	conn, connect, err := e.pool.open(link, func() (pooledConn, error) {
+		return e.dial(link, req)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	call.conn = conn.(*wsConn)
+	call.connect = connect
+
+	return call, nil
+}
+
+func (e *wsExecutor) dial(link string, req *Request) (*wsConn, error) {
+	header := http.Header{}
+	for field, value := range req.GetHeaders() {
+		header.Set(field, value)
+	}
+
+	dialer := e.dialer
+	if timeout := req.GetTimeout(); timeout > 0 {
+		dialer.HandshakeTimeout = timeout
+	}
+
+	conn, _, err := dialer.Dial(link, header)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &wsConn{
+		conn:    conn,
+		link:    link,
+		match:   e.options.Match,
+		waiters: make(map[string]chan []byte),
+		done:    make(chan struct{}),
+	}
+
+	go c.read()
+
+	return c, nil
+}
+
+// Read messages until connection closed, each message is delivered
+// to the call waiting for it, or dropped if no call waits for it
+func (c *wsConn) read() {
+	defer close(c.done)
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			c.err = err
+			atomic.StoreInt32(&c.closed, 1)
+			return
+		}
+
+		var id string
+		if len(c.match) > 0 {
+			if id = wsMessageID(data, c.match); len(id) == 0 {
+				atomic.AddInt64(&c.dropped, 1)
+				continue
+			}
+		}
+
+		c.lock.Lock()
+		reply, exists := c.waiters[id]
+		delete(c.waiters, id)
+		c.lock.Unlock()
+
+		if !exists {
+			atomic.AddInt64(&c.dropped, 1)
+			continue
+		}
+
+		// Channel of reply is buffered, reader is never blocked
+		reply <- data
+	}
+}
+
+// Wait for reply of id, the waiter must be registered before the
+// message is sent, so a fast reply is not dropped
+func (c *wsConn) wait(id string) chan []byte {
+	reply := make(chan []byte, 1)
+
+	c.lock.Lock()
+	c.waiters[id] = reply
+	c.lock.Unlock()
+
+	return reply
+}
+
+// Stop waiting for reply of id, late reply is dropped
+func (c *wsConn) cancel(id string) {
+	c.lock.Lock()
+	delete(c.waiters, id)
+	c.lock.Unlock()
+}
+
+// Closed connection is not reused
+func (c *wsConn) reusable(link string) bool {
+	return atomic.LoadInt32(&c.closed) == 0 && c.link == link
+}
+
+func (c *wsConn) close() {
+	_ = c.conn.Close()
+}
+
+func (e *wsExecutor) Execute(ctx context.Context, prepared interface{}) *Response {
+	call := prepared.(*wsCall)
+	c := call.conn
+
+	rsp := &Response{Connect: call.connect}
+
+	defer func() {
+		e.pool.put(c, !rsp.Disconnected)
+	}()
+
+	// Message is sent when interval of connection elapsed, the
+	// wait is neither timed nor counted in request timeout
+	if wait := time.Until(c.next); wait > 0 {
+		time.Sleep(wait)
+
+		if deadline, ok := ctx.Deadline(); ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithDeadline(context.WithoutCancel(ctx), deadline.Add(wait))
+			defer cancel()
+		}
+	}
+
+	deadline, _ := ctx.Deadline()
+	_ = c.conn.SetWriteDeadline(deadline)
+
+	var reply chan []byte
+	if !e.options.NoReply {
+		reply = c.wait(call.id)
+		defer c.cancel(call.id)
+	}
+
+	start := time.Now()
+
+	err := c.conn.WriteMessage(websocket.TextMessage, call.message)
+
+	c.next = start.Add(e.options.Interval)
+
+	// Messages are not replies if replies are not waited for
+	if dropped := atomic.SwapInt64(&c.dropped, 0); !e.options.NoReply {
+		rsp.Dropped = dropped
+	}
+
+	if err != nil {
+		rsp.Err = err
+		rsp.Elapsed = time.Since(start)
+		rsp.Disconnected = true
+		return rsp
+	}
+
+	if e.options.NoReply {
+		rsp.Status = "Sent"
+		rsp.OK = true
+		rsp.Elapsed = time.Since(start)
+		return rsp
+	}
+
+	var data []byte
+
+	select {
+	case data = <-reply:
+	case <-c.done:
+		// Reply may be received just before connection closed
+		select {
+		case data = <-reply:
+		default:
+			rsp.Status = "Closed"
+			rsp.Err = c.err
+			rsp.Elapsed = time.Since(start)
+			rsp.Disconnected = true
+			return rsp
+		}
+	case <-ctx.Done():
+		rsp.Err = ctx.Err()
+		rsp.Elapsed = time.Since(start)
+		return rsp
+	}
+
+	rsp.Elapsed = time.Since(start)
+	rsp.Status = "OK"
+	rsp.OK = true
+	rsp.Body = data
+
+	return rsp
+}
+
+func (e *wsExecutor) Close() error {
+	e.pool.close()
+	return nil
+}
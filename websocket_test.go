@@ -0,0 +1,264 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Start WebSocket server which pushes a notification before each
+// reply, connections are closed after limit messages if limit > 0
+func newTestWebSocketServer(t *testing.T, limit int, handshakes *int64) string {
+	t.Helper()
+
+	upgrader := websocket.Upgrader{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Token") != "t1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		atomic.AddInt64(handshakes, 1)
+
+		for count := 1; ; count++ {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+
+			if limit > 0 && count > limit {
+				return
+			}
+
+			var message map[string]interface{}
+			if err := json.Unmarshal(data, &message); err != nil {
+				_ = conn.WriteMessage(websocket.TextMessage, data)
+				continue
+			}
+
+			message["reply"] = true
+
+			reply, _ := json.Marshal(message)
+
+			_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"push"}`))
+			_ = conn.WriteMessage(websocket.TextMessage, reply)
+		}
+	}))
+
+	t.Cleanup(server.Close)
+
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func TestWebSocketMatch(t *testing.T) {
+	var handshakes int64
+
+	link := newTestWebSocketServer(t, 0, &handshakes)
+
+	var replies int64
+
+	config := NewConfig()
+	config.Connections = 4
+	config.Requests = 100
+	config.Items = []*BenchmarkItem{{URL: link, Body: []byte(`{"id": {{seq}}, "text": "hi"}`)}}
+	config.Headers["X-Token"] = "t1"
+	config.WebSocket.Match = "id"
+	config.Hooks.AfterResponse = func(req *Request, rsp *Response) bool {
+		if strings.Contains(string(rsp.Body), `"reply":true`) {
+			atomic.AddInt64(&replies, 1)
+		}
+		return true
+	}
+
+	snapshot := runBenchmark(t, config).Stats.Snapshot()
+
+	if snapshot.Total != 100 || snapshot.Success != 100 || snapshot.Status["OK"] != 100 {
+		t.Errorf("total = %d, success = %d, status = %v", snapshot.Total, snapshot.Success, snapshot.Status)
+	}
+
+	if replies != 100 {
+		t.Errorf("replies = %d, want 100", replies)
+	}
+
+	if snapshot.Connects == 0 || snapshot.Connects > 4 || snapshot.Connects != handshakes {
+		t.Errorf("connects = %d, handshakes = %d, want at most 4", snapshot.Connects, handshakes)
+	}
+
+	if snapshot.Disconnects != 0 {
+		t.Errorf("disconnects = %d, want 0", snapshot.Disconnects)
+	}
+
+	// Pushes are counted when the next message of connection is sent
+	if snapshot.DroppedMessages < 100-snapshot.Connects || snapshot.DroppedMessages > 100 {
+		t.Errorf("dropped messages = %d, want pushes", snapshot.DroppedMessages)
+	}
+}
+
+func TestWebSocketPushes(t *testing.T) {
+	const pushes = 500
+
+	upgrader := websocket.Upgrader{}
+
+	// Replies are sent after bursts of pushes
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+
+			for i := 0; i < pushes; i++ {
+				_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"id": "push"}`))
+			}
+
+			_ = conn.WriteMessage(websocket.TextMessage, data)
+		}
+	}))
+	defer server.Close()
+
+	config := NewConfig()
+	config.Connections = 1
+	config.Requests = 20
+	config.Items = []*BenchmarkItem{{URL: "ws" + strings.TrimPrefix(server.URL, "http"), Body: []byte(`{"id": {{seq}}}`)}}
+	config.WebSocket.Match = "id"
+
+	snapshot := runBenchmark(t, config).Stats.Snapshot()
+
+	if snapshot.Success != 20 {
+		t.Errorf("success = %d, failure = %d, want 20", snapshot.Success, snapshot.Failure)
+	}
+
+	if snapshot.DroppedMessages != 19*pushes {
+		t.Errorf("dropped messages = %d, want %d", snapshot.DroppedMessages, 19*pushes)
+	}
+}
+
+func TestWebSocketDisconnect(t *testing.T) {
+	var handshakes int64
+
+	link := newTestWebSocketServer(t, 5, &handshakes)
+
+	config := NewConfig()
+	config.Connections = 1
+	config.Requests = 12
+	config.Items = []*BenchmarkItem{{URL: link, Body: []byte("ping")}}
+	config.Headers["X-Token"] = "t1"
+
+	snapshot := runBenchmark(t, config).Stats.Snapshot()
+
+	// The 6th message of each connection is not replied, then
+	// connection is reopened for the next message
+	if snapshot.Success != 10 || snapshot.Failure != 2 || snapshot.Status["Closed"] != 2 {
+		t.Errorf("success = %d, failure = %d, status = %v", snapshot.Success, snapshot.Failure, snapshot.Status)
+	}
+
+	if snapshot.Disconnects != 2 || snapshot.Connects != 2 {
+		t.Errorf("disconnects = %d, connects = %d, want 2/2", snapshot.Disconnects, snapshot.Connects)
+	}
+}
+
+func TestWebSocketInterval(t *testing.T) {
+	var handshakes int64
+
+	link := newTestWebSocketServer(t, 0, &handshakes)
+
+	config := NewConfig()
+	config.Connections = 1
+	config.Requests = 5
+	config.Items = []*BenchmarkItem{{URL: link, Body: []byte(`{"id": "{{seq}}"}`)}}
+	config.Headers["X-Token"] = "t1"
+	config.WebSocket.Interval = 30 * time.Millisecond
+	config.WebSocket.NoReply = true
+
+	start := time.Now()
+
+	snapshot := runBenchmark(t, config).Stats.Snapshot()
+
+	if elapsed := time.Since(start); elapsed < 120*time.Millisecond {
+		t.Errorf("5 messages sent in %v, want at least 120ms", elapsed)
+	}
+
+	if snapshot.Success != 5 || snapshot.Status["Sent"] != 5 {
+		t.Errorf("success = %d, status = %v", snapshot.Success, snapshot.Status)
+	}
+}
+
+func TestWebSocketIntervalTimeout(t *testing.T) {
+	var handshakes int64
+
+	link := newTestWebSocketServer(t, 0, &handshakes)
+
+	config := NewConfig()
+	config.Connections = 1
+	config.Requests = 3
+	config.Timeout = 50 * time.Millisecond
+	config.Items = []*BenchmarkItem{{URL: link, Body: []byte(`{"id": "{{seq}}"}`)}}
+	config.Headers["X-Token"] = "t1"
+	config.WebSocket.Match = "id"
+	config.WebSocket.Interval = 100 * time.Millisecond
+
+	start := time.Now()
+
+	snapshot := runBenchmark(t, config).Stats.Snapshot()
+
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("3 messages sent in %v, want at least 200ms", elapsed)
+	}
+
+	// Wait for interval is neither timed nor failed by timeout
+	if snapshot.Success != 3 || snapshot.MaxTime >= 50 {
+		t.Errorf("success = %d, max time = %d", snapshot.Success, snapshot.MaxTime)
+	}
+}
+
+func TestWebSocketErrors(t *testing.T) {
+	var handshakes int64
+
+	link := newTestWebSocketServer(t, 0, &handshakes)
+
+	config := NewConfig()
+	config.Connections = 1
+	config.WebSocket.Match = "id"
+
+	executor, err := NewWebSocketExecutor(config)
+	if err != nil {
+		t.Fatalf("NewWebSocketExecutor() failed: %v", err)
+	}
+	defer executor.Close()
+
+	// Correlation id is required when matching
+	if _, err := executor.Prepare(NewRequest(URLOption(link), BodyOption([]byte("{}")))); err == nil {
+		t.Error("Prepare() should fail without correlation id")
+	}
+
+	// Handshake is rejected without token, connection slot is released
+	for i := 0; i < 2; i++ {
+		req := NewRequest(URLOption(link), BodyOption([]byte(`{"id": 1}`)))
+		if _, err := executor.Prepare(req); err == nil {
+			t.Error("Prepare() should fail when handshake rejected")
+		}
+	}
+}
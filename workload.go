@@ -0,0 +1,75 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Distributions of keys of generated workloads (etc: Redis, Memcached)
+const (
+	KeyUniform = "uniform"
+	KeyZipfian = "zipfian"
+)
+
+// Defaults of generated workloads, workloads of different servers
+// have the same defaults to make their results comparable
+const (
+	defaultKeys      = 100000
+	defaultValueSize = 3
+)
+
+// Get template of random key in [1, keys] of distribution
+func keyTemplate(keys int64, distribution string) (string, error) {
+	switch strings.ToLower(distribution) {
+	case "", KeyUniform:
+		return "{{randInt 1 " + strconv.FormatInt(keys, 10) + "}}", nil
+	case KeyZipfian:
+		return "{{zipfInt 1 " + strconv.FormatInt(keys, 10) + "}}", nil
+	}
+	return "", fmt.Errorf("unsupported key distribution: %s", distribution)
+}
+
+// Get template of value whose size is in [min, max], value
+// is fixed if max is not greater than min
+func valueTemplate(min, max int) string {
+	if max <= min {
+		return strings.Repeat("x", min)
+	}
+	return "{{randValue " + strconv.Itoa(min) + " " + strconv.Itoa(max) + "}}"
+}
+
+type weightedName struct {
+	name   string
+	weight int
+}
+
+// Parse comma separated names with optional weights (etc: get:80,set:20),
+// default weight is 1
+func parseWeightedList(text string) ([]weightedName, error) {
+	var names []weightedName
+
+	for _, entry := range strings.Split(text, ",") {
+		if entry = strings.TrimSpace(entry); len(entry) == 0 {
+			continue
+		}
+
+		name, weight := entry, 1
+
+		if index := strings.LastIndex(entry, ":"); index >= 0 {
+			value, err := strconv.Atoi(strings.TrimSpace(entry[index+1:]))
+			if err != nil || value <= 0 {
+				return nil, fmt.Errorf("invalid weight: %s", entry)
+			}
+			name, weight = strings.TrimSpace(entry[:index]), value
+		}
+
+		names = append(names, weightedName{name: name, weight: weight})
+	}
+
+	return names, nil
+}
@@ -0,0 +1,57 @@
+// Copyright 2020 Jayden Lie. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gobenchmark
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWorkloadTemplates(t *testing.T) {
+	tests := []struct {
+		distribution string
+		want         string
+	}{
+		{"", "{{randInt 1 100}}"},
+		{KeyUniform, "{{randInt 1 100}}"},
+		{"Zipfian", "{{zipfInt 1 100}}"},
+	}
+
+	for _, test := range tests {
+		if key, err := keyTemplate(100, test.distribution); err != nil || key != test.want {
+			t.Errorf("keyTemplate(%s) = %s, %v, want %s", test.distribution, key, err, test.want)
+		}
+	}
+
+	if _, err := keyTemplate(100, "normal"); err == nil {
+		t.Error("keyTemplate(normal) should fail")
+	}
+
+	if value := valueTemplate(5, 8); value != "{{randValue 5 8}}" {
+		t.Errorf("valueTemplate(5, 8) = %s", value)
+	}
+
+	if value := valueTemplate(3, 0); value != "xxx" {
+		t.Errorf("valueTemplate(3, 0) = %s", value)
+	}
+}
+
+func TestWorkloadWeightedList(t *testing.T) {
+	names, err := parseWeightedList(" get:80, set ,, del:5")
+	if err != nil {
+		t.Fatalf("parseWeightedList() failed: %v", err)
+	}
+
+	want := []weightedName{{"get", 80}, {"set", 1}, {"del", 5}}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("names = %+v, want %+v", names, want)
+	}
+
+	for _, text := range []string{"get:0", "get:x"} {
+		if _, err := parseWeightedList(text); err == nil {
+			t.Errorf("parseWeightedList(%s) should fail", text)
+		}
+	}
+}